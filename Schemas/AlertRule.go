@@ -0,0 +1,182 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AlertRuleSchema struct {
+	RuleId          int64     `json:"ruleId"`
+	UserId          string    `json:"userId"`
+	Categories      []string  `json:"categories"` // -- matches articles with any of these categories
+	Entities        []string  `json:"entities"`   // -- matches articles mentioning any of these entities
+	Publishers      []string  `json:"publishers"` // -- matches articles from any of these publishers
+	MinImportance   int       `json:"minImportance"`
+	Channels        []string  `json:"channels"` // -- e.g., ["webhook", "fcm", "email"]
+	WebhookUrl      string    `json:"webhookUrl"`
+	FcmToken        string    `json:"fcmToken"`
+	Email           string    `json:"email"`
+	QuietHoursStart *int      `json:"quietHoursStart"` // -- hour of day (0-23) from which nothing is sent, nil for none
+	QuietHoursEnd   *int      `json:"quietHoursEnd"`   // -- hour of day (0-23) at which sending resumes
+	Timezone        string    `json:"timezone"`        // -- IANA name the quiet hours are expressed in
+	Enabled         bool      `json:"enabled"`
+	CreatedAt       time.Time `json:"createdAt"` // TIMESTAMP
+}
+
+const alertRuleColumns = `rule_id, user_id, categories, entities, publishers, min_importance, channels, webhook_url,
+	fcm_token, email, quiet_hours_start, quiet_hours_end, timezone, enabled, created_at`
+
+// CreateAlertRulesTable creates the alert rules table in the database
+func CreateAlertRulesTable(ctx context.Context, pool *pgxpool.Pool) error {
+	alertRuleTableName := config.GetEnvironmentVariable("ALERT_RULE_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		rule_id BIGSERIAL PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		categories TEXT[] NOT NULL DEFAULT '{}',
+		entities TEXT[] NOT NULL DEFAULT '{}',
+		publishers TEXT[] NOT NULL DEFAULT '{}',
+		min_importance INTEGER NOT NULL DEFAULT 0,
+		channels TEXT[] NOT NULL DEFAULT '{}',
+		webhook_url TEXT NOT NULL DEFAULT '',
+		fcm_token TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		quiet_hours_start INTEGER,
+		quiet_hours_end INTEGER,
+		timezone TEXT NOT NULL DEFAULT 'UTC',
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_user_id_idx ON %[1]s (user_id);`, alertRuleTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating alert rules table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", alertRuleTableName)
+	return nil
+}
+
+// InsertAlertRule stores a new alert rule and returns it with its id
+func InsertAlertRule(ctx context.Context, pool *pgxpool.Pool, rule AlertRuleSchema) (*AlertRuleSchema, error) {
+	alertRuleTableName := config.GetEnvironmentVariable("ALERT_RULE_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (user_id, categories, entities, publishers, min_importance, channels, webhook_url, fcm_token, email, quiet_hours_start, quiet_hours_end, timezone, enabled)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+    RETURNING %s;`, alertRuleTableName, alertRuleColumns)
+
+	var storedRule AlertRuleSchema
+	err := scanAlertRule(pool.QueryRow(ctx, insertSQL,
+		rule.UserId,
+		nonNilStrings(rule.Categories),
+		nonNilStrings(rule.Entities),
+		nonNilStrings(rule.Publishers),
+		rule.MinImportance,
+		nonNilStrings(rule.Channels),
+		rule.WebhookUrl,
+		rule.FcmToken,
+		rule.Email,
+		rule.QuietHoursStart,
+		rule.QuietHoursEnd,
+		rule.Timezone,
+		rule.Enabled), &storedRule)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting alert rule: %v", err)
+	}
+
+	return &storedRule, nil
+}
+
+// GetAlertRulesByUser lists the alert rules of a user
+func GetAlertRulesByUser(ctx context.Context, pool *pgxpool.Pool, userId string) ([]AlertRuleSchema, error) {
+	alertRuleTableName := config.GetEnvironmentVariable("ALERT_RULE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE user_id = $1
+	ORDER BY rule_id;`, alertRuleColumns, alertRuleTableName)
+
+	return queryAlertRules(ctx, pool, query, userId)
+}
+
+// DeleteAlertRule removes an alert rule of a user, it returns false when the user has no such rule
+func DeleteAlertRule(ctx context.Context, pool *pgxpool.Pool, userId string, ruleId int64) (bool, error) {
+	alertRuleTableName := config.GetEnvironmentVariable("ALERT_RULE_TABLE_NAME")
+
+	commandTag, err := pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 AND rule_id = $2;`, alertRuleTableName), userId, ruleId)
+	if err != nil {
+		return false, err
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+// GetAlertRulesMatchingArticle returns the enabled rules the article satisfies.
+// A rule without any category, entity or publisher filter matches on importance alone.
+func GetAlertRulesMatchingArticle(ctx context.Context, pool *pgxpool.Pool, article ArticleSchema) ([]AlertRuleSchema, error) {
+	alertRuleTableName := config.GetEnvironmentVariable("ALERT_RULE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE enabled AND min_importance <= $1
+	  AND ((cardinality(categories) = 0 AND cardinality(entities) = 0 AND cardinality(publishers) = 0)
+	    OR categories && $2 OR entities && $3 OR $4 = ANY(publishers));`, alertRuleColumns, alertRuleTableName)
+
+	return queryAlertRules(ctx, pool, query,
+		article.ImportanceScore,
+		nonNilStrings(article.Categories),
+		nonNilStrings(article.EntityNames()),
+		article.Publisher)
+}
+
+func queryAlertRules(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]AlertRuleSchema, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching alert rules: %v", err)
+	}
+	defer rows.Close()
+
+	rules := []AlertRuleSchema{}
+	for rows.Next() {
+		var rule AlertRuleSchema
+		if err := scanAlertRule(rows, &rule); err != nil {
+			return nil, fmt.Errorf("error scanning alert rule: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func scanAlertRule(row pgx.Row, rule *AlertRuleSchema) error {
+	return row.Scan(
+		&rule.RuleId,
+		&rule.UserId,
+		&rule.Categories,
+		&rule.Entities,
+		&rule.Publishers,
+		&rule.MinImportance,
+		&rule.Channels,
+		&rule.WebhookUrl,
+		&rule.FcmToken,
+		&rule.Email,
+		&rule.QuietHoursStart,
+		&rule.QuietHoursEnd,
+		&rule.Timezone,
+		&rule.Enabled,
+		&rule.CreatedAt,
+	)
+}
@@ -3,14 +3,18 @@ package schemas
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
 )
 
 type ArticleSchema struct {
@@ -29,12 +33,170 @@ type ArticleSchema struct {
 	Status          string         `json:"status"`         // -- e.g., "published" or "unpublished"
 	CreatedAt       time.Time      `json:"createdAt"`      // TIMESTAMP
 	UpdatedAt       time.Time      `json:"updatedAt"`      // TIMESTAMP
+	Version         int            `json:"version"`        // -- incremented on every write, used for optimistic concurrency
+
+	EditedAfterPublication bool              `json:"editedAfterPublication"`    // -- set once a substantive edit lands on a published article
+	DeletedAt              *time.Time        `json:"deletedAt,omitempty"`       // TIMESTAMP -- set when the article is soft deleted
+	ContentPurgedAt        *time.Time        `json:"contentPurgedAt,omitempty"` // TIMESTAMP -- set when retention removed the full content
+	ImportanceScore        int               `json:"importanceScore"`           // -- 0 to 100, LLM assessed news importance
+	StoryId                *string           `json:"storyId,omitempty"`         // -- id of the first article of the story this article covers
+	Language               string            `json:"language"`                  // -- ISO 639-1 code, e.g., "en" or "hi"
+	EnrichmentConfidence   int               `json:"enrichmentConfidence"`      // -- 0 to 100, how sure the LLM was of the generated metadata
+	LockedFields           pq.StringArray    `json:"lockedFields"`              // -- e.g., ["summary", "categories"], overridden by an editor and kept on re-enrichment
+	ReviewStatus           string            `json:"reviewStatus"`              // -- "" (not needed), "pending" or "reviewed"
+	PromptVersions         map[string]string `json:"promptVersions"`            // -- e.g., {"metadata": "v1", "summary": "v2"}, prompt versions of the last enrichment
+
+	Embedding []float32            `json:"-"` // -- vector(1536), only loaded by the queries that rank articles
+	Chunks    []ArticleChunkSchema `json:"-"` // -- passages with their embeddings, set by enrichment and stored with ReplaceArticleChunks
+}
+
+// ErrArticleVersionConflict is returned when the expected version of an article does not match the stored one
+var ErrArticleVersionConflict = errors.New("article version conflict")
+
+// ErrArticleDeleted is returned when writing to an article that has been soft deleted
+var ErrArticleDeleted = errors.New("article has been deleted")
+
+// columns selected for an ArticleSchema, in the order expected by scanArticle
+const articleColumns = `article_id, title, publisher, publication_date, url, content, summary, tags, entities,
+	sentiment_score, categories, content_s3_path, status, created_at, updated_at, version, edited_after_publication,
+	deleted_at, content_purged_at, importance_score, story_id::TEXT, language,
+	enrichment_confidence, locked_fields, review_status, prompt_versions`
+
+// same as articleColumns without the full content, for listings
+const articleListColumns = `article_id, title, publisher, publication_date, url, '' AS content, summary, tags, entities,
+	sentiment_score, categories, content_s3_path, status, created_at, updated_at, version, edited_after_publication,
+	deleted_at, content_purged_at, importance_score, story_id::TEXT, language,
+	enrichment_confidence, locked_fields, review_status, prompt_versions`
+
+// columns added after the table was first created, applied on startup
+var articleTableMigrations = []string{
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS edited_after_publication BOOLEAN NOT NULL DEFAULT FALSE;`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS content_purged_at TIMESTAMP;`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS embedding vector(1536);`,
+	`CREATE INDEX IF NOT EXISTS %[1]s_embedding_idx ON %[1]s USING hnsw (embedding vector_cosine_ops);`,
+	`CREATE INDEX IF NOT EXISTS %[1]s_publication_date_idx ON %[1]s (publication_date DESC);`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS importance_score INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS story_id UUID;`,
+	`CREATE INDEX IF NOT EXISTS %[1]s_story_id_idx ON %[1]s (story_id);`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(summary, '')), 'B') ||
+		setweight(to_tsvector('english', left(coalesce(content, ''), 100000)), 'C')) STORED;`,
+	`CREATE INDEX IF NOT EXISTS %[1]s_search_vector_idx ON %[1]s USING gin (search_vector);`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS enrichment_confidence INTEGER NOT NULL DEFAULT 100;`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS locked_fields TEXT[] NOT NULL DEFAULT '{}';`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS review_status TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS prompt_versions JSONB NOT NULL DEFAULT '{}';`,
+	`CREATE INDEX IF NOT EXISTS %[1]s_review_queue_idx ON %[1]s (enrichment_confidence, publication_date DESC) WHERE review_status = 'pending';`,
+}
+
+// embeddingParam converts an embedding to a query argument, nil (NULL) when there is none
+func embeddingParam(embedding []float32) *pgvector.Vector {
+	if len(embedding) == 0 {
+		return nil
+	}
+	vector := pgvector.NewVector(embedding)
+	return &vector
+}
+
+// promptVersionsParam converts the prompt versions to a query argument, an empty object when there are none
+func promptVersionsParam(promptVersions map[string]string) map[string]string {
+	if promptVersions == nil {
+		return map[string]string{}
+	}
+	return promptVersions
+}
+
+func scanArticle(row pgx.Row, article *ArticleSchema, extra ...any) error {
+	return row.Scan(append([]any{
+		&article.ArticleId,
+		&article.Title,
+		&article.Publisher,
+		&article.PublicationDate,
+		&article.Url,
+		&article.Content,
+		&article.Summary,
+		&article.Tags, // Scan tags as an array of strings
+		&article.Entities,
+		&article.SentimentScore,
+		&article.Categories, // Scan categories as an array of strings
+		&article.ContentS3Path,
+		&article.Status,
+		&article.CreatedAt,
+		&article.UpdatedAt,
+		&article.Version,
+		&article.EditedAfterPublication,
+		&article.DeletedAt,
+		&article.ContentPurgedAt,
+		&article.ImportanceScore,
+		&article.StoryId,
+		&article.Language,
+		&article.EnrichmentConfidence,
+		&article.LockedFields,
+		&article.ReviewStatus,
+		&article.PromptVersions,
+	}, extra...)...)
+}
+
+// lockArticle loads the current row of the article inside tx and locks it until the transaction ends.
+// Concurrent writers of an article id that does not exist yet are serialized by an advisory lock.
+func lockArticle(ctx context.Context, tx pgx.Tx, articleId string) (*ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1));`, articleId)
+	if err != nil {
+		return nil, fmt.Errorf("error locking article: %v", err)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE article_id = $1
+	FOR UPDATE;`, articleColumns, articleTableName)
+
+	var article ArticleSchema
+	err = scanArticle(tx.QueryRow(ctx, query, articleId), &article)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching article: %v", err)
+	}
+
+	return &article, nil
+}
+
+// isSubstantiveEdit reports whether replacing previous with updated changes the headline
+// or more than SUBSTANTIVE_EDIT_PERCENT of the words of a published article
+func isSubstantiveEdit(previous ArticleSchema, updated ArticleSchema) bool {
+	if previous.Status != "published" {
+		return false
+	}
+
+	if utils.DiffChangeRatio(utils.DiffWords(previous.Title, updated.Title)) > 0 {
+		return true
+	}
+
+	thresholdPercent := config.GetIntEnvironmentVariable("SUBSTANTIVE_EDIT_PERCENT", 5)
+	contentChangeRatio := utils.DiffChangeRatio(utils.DiffWords(previous.Content, updated.Content))
+
+	return contentChangeRatio*100 >= float64(thresholdPercent)
 }
 
 // CreateArticlesTable creates the articles table in the database
 func CreateArticlesTable(ctx context.Context, pool *pgxpool.Pool) error {
 	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
 
+	// pgvector is needed for the embedding column
+	_, err := pool.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS vector;`)
+	if err != nil {
+		fmt.Println("Error creating vector extension: ", err)
+		return err
+	}
+
 	createTableSQL := fmt.Sprintf(`
 	CREATE TABLE IF NOT EXISTS %s (
 		article_id UUID PRIMARY KEY,
@@ -51,16 +213,26 @@ func CreateArticlesTable(ctx context.Context, pool *pgxpool.Pool) error {
 		content_s3_path TEXT,     
 		status TEXT NOT NULL,      
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		version INTEGER NOT NULL DEFAULT 1
 	);`, articleTableName)
 
 	// Execute the SQL command to create the table
-	_, err := pool.Exec(ctx, createTableSQL)
+	_, err = pool.Exec(ctx, createTableSQL)
 	if err != nil {
 		fmt.Println("Error creating articles table: ", err)
 		return err
 	}
 
+	// Bring tables created by older versions up to date
+	for _, migrationSQL := range articleTableMigrations {
+		_, err = pool.Exec(ctx, fmt.Sprintf(migrationSQL, articleTableName))
+		if err != nil {
+			fmt.Println("Error migrating articles table: ", err)
+			return err
+		}
+	}
+
 	log.Printf("%s table created successfully or already exists\n", articleTableName)
 	return nil
 }
@@ -105,27 +277,11 @@ func GetArticleByID(ctx context.Context, pool *pgxpool.Pool, articleId string) (
 	var article ArticleSchema
 
 	query := fmt.Sprintf(`
-	SELECT *
+	SELECT %s
 	FROM %s
-	WHERE article_id = $1;`, articleTableName)
+	WHERE article_id = $1 AND deleted_at IS NULL;`, articleColumns, articleTableName)
 
-	err := pool.QueryRow(ctx, query, articleId).Scan(
-		&article.ArticleId,
-		&article.Title,
-		&article.Publisher,
-		&article.PublicationDate,
-		&article.Url,
-		&article.Content,
-		&article.Summary,
-		&article.Tags, // Scan tags as an array of strings
-		&article.Entities,
-		&article.SentimentScore,
-		&article.Categories, // Scan categories as an array of strings
-		&article.ContentS3Path,
-		&article.Status,
-		&article.CreatedAt,
-		&article.UpdatedAt,
-	)
+	err := scanArticle(pool.QueryRow(ctx, query, articleId), &article)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -136,22 +292,79 @@ func GetArticleByID(ctx context.Context, pool *pgxpool.Pool, articleId string) (
 
 	return &article, nil
 }
-func UpdateArticleByID(ctx context.Context, pool *pgxpool.Pool, article ArticleSchema) error {
 
+// GetArticleContentS3Path returns the S3 path of the stored content of the article, soft deleted ones included.
+// found is false when the article does not exist.
+func GetArticleContentS3Path(ctx context.Context, pool *pgxpool.Pool, articleId string) (path string, found bool, err error) {
 	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
 
-	// Marshal tags to JSONB
-	tagsJSON, err := json.Marshal(article.Tags)
+	query := fmt.Sprintf(`SELECT COALESCE(content_s3_path, '') FROM %s WHERE article_id = $1;`, articleTableName)
+
+	err = pool.QueryRow(ctx, query, articleId).Scan(&path)
 	if err != nil {
-		return fmt.Errorf("error marshaling tags: %v", err)
+		if err == pgx.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("error fetching article content path: %v", err)
 	}
 
+	return path, true, nil
+}
+
+// GetArticleVersion returns the stored version of the article, nil when it does not exist. It returns
+// ErrArticleDeleted for soft deleted articles.
+func GetArticleVersion(ctx context.Context, pool *pgxpool.Pool, articleId string) (*int, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`SELECT version, deleted_at IS NOT NULL FROM %s WHERE article_id = $1;`, articleTableName)
+
+	var version int
+	var deleted bool
+	err := pool.QueryRow(ctx, query, articleId).Scan(&version, &deleted)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching article version: %v", err)
+	}
+	if deleted {
+		return nil, ErrArticleDeleted
+	}
+
+	return &version, nil
+}
+
+// UpdateArticleByID overwrites the article, keeping the previous version as a revision.
+// It returns ErrArticleDeleted when the article was deleted or purged in the meantime.
+func UpdateArticleByID(ctx context.Context, pool *pgxpool.Pool, article ArticleSchema) error {
+
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
 	// Marshal entities to JSONB
 	entitiesJSON, err := json.Marshal(article.Entities)
 	if err != nil {
 		return fmt.Errorf("error marshaling entities: %v", err)
 	}
 
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	previous, err := lockArticle(ctx, tx, article.ArticleId)
+	if err != nil {
+		return err
+	}
+	if previous == nil || previous.DeletedAt != nil {
+		return ErrArticleDeleted
+	}
+
+	err = insertArticleRevision(ctx, tx, *previous)
+	if err != nil {
+		return err
+	}
+
 	updateSQL := fmt.Sprintf(`
     UPDATE %s
     SET title = $1,
@@ -159,30 +372,933 @@ func UpdateArticleByID(ctx context.Context, pool *pgxpool.Pool, article ArticleS
         publication_date = $3,
         url = $4,
         content = $5,
-        summary = $6,
+        summary = CASE WHEN 'summary' = ANY(locked_fields) THEN summary ELSE $6 END,
         tags = $7,
-        entities = $8,
-        sentiment_score = $9,
-        categories = $10,
+        entities = CASE WHEN 'entities' = ANY(locked_fields) THEN entities ELSE $8 END,
+        sentiment_score = CASE WHEN 'sentimentScore' = ANY(locked_fields) THEN sentiment_score ELSE $9 END,
+        categories = CASE WHEN 'categories' = ANY(locked_fields) THEN categories ELSE $10 END,
         content_s3_path = $11,
+        content_purged_at = CASE WHEN $5 <> '' OR $11 <> '' THEN NULL ELSE content_purged_at END, -- new content falls under retention again
         status = $12,
+        edited_after_publication = edited_after_publication OR $13,
+        embedding = COALESCE($15, embedding),
+        importance_score = $16,
+        story_id = COALESCE($17, story_id),
+        language = $18,
+        enrichment_confidence = $19,
+        review_status = CASE WHEN $20 = '' AND review_status = 'reviewed' THEN review_status ELSE $20 END,
+        prompt_versions = $21,
+        version = version + 1,
         updated_at = CURRENT_TIMESTAMP  -- Automatically set updated_at to current time
-    WHERE article_id = $13;`, articleTableName)
+    WHERE article_id = $14;`, articleTableName)
+
+	_, err = tx.Exec(ctx, updateSQL,
+		article.Title,
+		article.Publisher,
+		article.PublicationDate,
+		article.Url,
+		article.Content,
+		article.Summary,
+		[]string(article.Tags),                      // Insert tags as TEXT[]
+		entitiesJSON,                                // Insert entities as JSONB
+		article.SentimentScore,                      // Insert sentiment score as VARCHAR
+		[]string(article.Categories),                // Insert categories as TEXT[]
+		article.ContentS3Path,                       // Insert content S3 path
+		article.Status,                              // Insert status
+		isSubstantiveEdit(*previous, article),       // Flag stealth edits of published articles
+		article.ArticleId,                           // Article ID to identify the row to update
+		embeddingParam(article.Embedding),           // Keep the stored embedding when none was generated
+		article.ImportanceScore,                     // Insert importance score as INTEGER
+		article.StoryId,                             // Keep the stored story when none was assigned
+		article.Language,                            // Insert language as TEXT
+		article.EnrichmentConfidence,                // Insert enrichment confidence as INTEGER
+		article.ReviewStatus,                        // Insert review status as TEXT
+		promptVersionsParam(article.PromptVersions)) // Insert prompt versions as JSONB
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UpsertArticle inserts the article or updates the existing row with the same id, keeping the
+// previous version as a revision. When expectedVersion is set the write only succeeds if the
+// stored version matches it, otherwise ErrArticleVersionConflict is returned.
+// It reports whether the row was created and returns the row as stored.
+func UpsertArticle(ctx context.Context, pool *pgxpool.Pool, article ArticleSchema, expectedVersion *int) (bool, *ArticleSchema, error) {
+
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	entitiesDataJSON, err := json.Marshal(article.Entities)
+	if err != nil {
+		return false, nil, fmt.Errorf("error marshaling entities: %v", err)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	previous, err := lockArticle(ctx, tx, article.ArticleId)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if previous != nil && previous.DeletedAt != nil {
+		return false, nil, ErrArticleDeleted
+	}
+
+	if expectedVersion != nil && (previous == nil || previous.Version != *expectedVersion) {
+		return false, nil, ErrArticleVersionConflict
+	}
+
+	editedAfterPublication := false
+	if previous != nil {
+		err = insertArticleRevision(ctx, tx, *previous)
+		if err != nil {
+			return false, nil, err
+		}
+		editedAfterPublication = isSubstantiveEdit(*previous, article)
+	}
 
-	_, err = pool.Exec(ctx, updateSQL,
+	// xmax is only set on rows touched by the DO UPDATE branch
+	upsertSQL := fmt.Sprintf(`
+    INSERT INTO %[1]s (article_id, title, publisher, publication_date, url, content, summary, tags, entities, sentiment_score, categories, content_s3_path, status, edited_after_publication, embedding, importance_score, story_id, language, enrichment_confidence, review_status, prompt_versions)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+    ON CONFLICT (article_id) DO UPDATE
+    SET title = EXCLUDED.title,
+        publisher = EXCLUDED.publisher,
+        publication_date = EXCLUDED.publication_date,
+        url = EXCLUDED.url,
+        content = EXCLUDED.content,
+        summary = CASE WHEN 'summary' = ANY(%[1]s.locked_fields) THEN %[1]s.summary ELSE EXCLUDED.summary END,
+        tags = EXCLUDED.tags,
+        entities = CASE WHEN 'entities' = ANY(%[1]s.locked_fields) THEN %[1]s.entities ELSE EXCLUDED.entities END,
+        sentiment_score = CASE WHEN 'sentimentScore' = ANY(%[1]s.locked_fields) THEN %[1]s.sentiment_score ELSE EXCLUDED.sentiment_score END,
+        categories = CASE WHEN 'categories' = ANY(%[1]s.locked_fields) THEN %[1]s.categories ELSE EXCLUDED.categories END,
+        content_s3_path = EXCLUDED.content_s3_path,
+        content_purged_at = CASE WHEN EXCLUDED.content <> '' OR EXCLUDED.content_s3_path <> '' THEN NULL ELSE %[1]s.content_purged_at END,
+        status = EXCLUDED.status,
+        edited_after_publication = %[1]s.edited_after_publication OR EXCLUDED.edited_after_publication,
+        embedding = COALESCE(EXCLUDED.embedding, %[1]s.embedding),
+        importance_score = EXCLUDED.importance_score,
+        story_id = COALESCE(EXCLUDED.story_id, %[1]s.story_id),
+        language = EXCLUDED.language,
+        enrichment_confidence = EXCLUDED.enrichment_confidence,
+        review_status = CASE WHEN EXCLUDED.review_status = '' AND %[1]s.review_status = 'reviewed' THEN %[1]s.review_status ELSE EXCLUDED.review_status END,
+        prompt_versions = EXCLUDED.prompt_versions,
+        version = %[1]s.version + 1,
+        updated_at = CURRENT_TIMESTAMP
+    RETURNING %[2]s, (xmax = 0) AS created;`, articleTableName, articleColumns)
+
+	var storedArticle ArticleSchema
+	var created bool
+
+	err = scanArticle(tx.QueryRow(ctx, upsertSQL,
+		article.ArticleId,
 		article.Title,
 		article.Publisher,
 		article.PublicationDate,
 		article.Url,
 		article.Content,
 		article.Summary,
-		tagsJSON,                     // Insert tags as JSONB
-		entitiesJSON,                 // Insert entities as JSONB
-		article.SentimentScore,       // Insert sentiment score as VARCHAR
-		pq.Array(article.Categories), // Insert categories as an array
-		article.ContentS3Path,        // Insert content S3 path
-		article.Status,               // Insert status
-		article.ArticleId)            // Article ID to identify the row to update
+		[]string(article.Tags),
+		entitiesDataJSON,
+		article.SentimentScore,
+		[]string(article.Categories),
+		article.ContentS3Path,
+		article.Status,
+		editedAfterPublication,
+		embeddingParam(article.Embedding),
+		article.ImportanceScore,
+		article.StoryId,
+		article.Language,
+		article.EnrichmentConfidence,
+		article.ReviewStatus,
+		promptVersionsParam(article.PromptVersions)), &storedArticle, &created)
+	if err != nil {
+		return false, nil, fmt.Errorf("error upserting article: %v", err)
+	}
 
-	return err
+	if err := tx.Commit(ctx); err != nil {
+		return false, nil, err
+	}
+
+	return created, &storedArticle, nil
+}
+
+// ArticleMetadataOverride holds the metadata set by an editor, nil fields are left as they are.
+// Overridden fields get locked, Unlock releases locks so the next enrichment regenerates those fields.
+type ArticleMetadataOverride struct {
+	Summary        *string
+	Categories     *[]string
+	Entities       any
+	SentimentScore *string
+	Unlock         []string
+}
+
+// metadataSet returns the overridable metadata of the article
+func (article ArticleSchema) metadataSet() ArticleMetadataSet {
+	return ArticleMetadataSet{
+		Summary:        article.Summary,
+		Categories:     nonNilStrings(article.Categories),
+		Entities:       article.Entities,
+		SentimentScore: article.SentimentScore,
+	}
+}
+
+// sameJSON reports whether a and b have the same JSON representation, regardless of key order
+func sameJSON(a any, b any) bool {
+	normalize := func(value any) string {
+		encoded, _ := json.Marshal(value)
+		var decoded any
+		json.Unmarshal(encoded, &decoded)
+		normalized, _ := json.Marshal(decoded)
+		return string(normalized)
+	}
+	return normalize(a) == normalize(b)
+}
+
+// OverrideArticleMetadata applies an editor's override to the article, locks the overridden fields and marks the
+// article as reviewed. The previous version is kept as a revision and the verdict is stored as a metadata
+// correction: an override when fields changed, a confirmation when the editor approved the metadata as is.
+// It returns nil when there is no live article with the id, and ErrArticleVersionConflict when expectedVersion is
+// set and does not match the stored version.
+func OverrideArticleMetadata(ctx context.Context, pool *pgxpool.Pool, articleId string, editorId string, override ArticleMetadataOverride, expectedVersion *int) (*ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	previous, err := lockArticle(ctx, tx, articleId)
+	if err != nil {
+		return nil, err
+	}
+	if previous == nil || previous.DeletedAt != nil {
+		return nil, nil
+	}
+	if expectedVersion != nil && previous.Version != *expectedVersion {
+		return nil, ErrArticleVersionConflict
+	}
+
+	original := previous.metadataSet()
+	corrected := original
+	overridden := []string{}
+
+	if override.Summary != nil {
+		corrected.Summary = *override.Summary
+		overridden = append(overridden, "summary")
+	}
+	if override.Categories != nil {
+		corrected.Categories = nonNilStrings(*override.Categories)
+		overridden = append(overridden, "categories")
+	}
+	if override.Entities != nil {
+		corrected.Entities = override.Entities
+		overridden = append(overridden, "entities")
+	}
+	if override.SentimentScore != nil {
+		corrected.SentimentScore = *override.SentimentScore
+		overridden = append(overridden, "sentimentScore")
+	}
+
+	changed := []string{}
+	if corrected.Summary != original.Summary {
+		changed = append(changed, "summary")
+	}
+	if !sameJSON(corrected.Categories, original.Categories) {
+		changed = append(changed, "categories")
+	}
+	if !sameJSON(corrected.Entities, original.Entities) {
+		changed = append(changed, "entities")
+	}
+	if corrected.SentimentScore != original.SentimentScore {
+		changed = append(changed, "sentimentScore")
+	}
+
+	lockedFields := []string{}
+	for _, field := range previous.LockedFields {
+		if !utils.Includes(override.Unlock, field) {
+			lockedFields = append(lockedFields, field)
+		}
+	}
+	lockedFields = utils.ConvertDuplicatesArrtoUniqueArr(append(lockedFields, overridden...))
+
+	err = insertArticleRevision(ctx, tx, *previous)
+	if err != nil {
+		return nil, err
+	}
+
+	entitiesJSON, err := json.Marshal(corrected.Entities)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling entities: %v", err)
+	}
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET summary = $2,
+        categories = $3,
+        entities = $4,
+        sentiment_score = $5,
+        locked_fields = $6,
+        review_status = 'reviewed',
+        version = version + 1,
+        updated_at = CURRENT_TIMESTAMP
+    WHERE article_id = $1
+    RETURNING %s;`, articleTableName, articleColumns)
+
+	var article ArticleSchema
+	err = scanArticle(tx.QueryRow(ctx, updateSQL,
+		articleId,
+		corrected.Summary,
+		corrected.Categories,
+		entitiesJSON,
+		corrected.SentimentScore,
+		nonNilStrings(lockedFields)), &article)
+	if err != nil {
+		return nil, fmt.Errorf("error overriding article metadata: %v", err)
+	}
+
+	// releasing locks alone says nothing about the quality of the metadata
+	kind := "override"
+	if len(changed) == 0 {
+		kind = "confirmation"
+	}
+	if len(changed) > 0 || len(override.Unlock) == 0 {
+		err = insertMetadataCorrection(ctx, tx, MetadataCorrectionSchema{
+			ArticleId:      articleId,
+			EditorId:       editorId,
+			Kind:           kind,
+			Fields:         changed,
+			Original:       original,
+			Corrected:      corrected,
+			Confidence:     previous.EnrichmentConfidence,
+			PromptVersions: previous.PromptVersions,
+			Title:          previous.Title,
+			Content:        previous.Content,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &article, nil
+}
+
+// GetReviewQueue lists the live articles whose enrichment waits for an editor, least confident first,
+// without their full content
+func GetReviewQueue(ctx context.Context, pool *pgxpool.Pool, limit int, offset int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE review_status = 'pending' AND deleted_at IS NULL
+	ORDER BY enrichment_confidence, publication_date DESC
+	LIMIT $1 OFFSET $2;`, articleListColumns, articleTableName)
+
+	rows, err := pool.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching review queue: %v", err)
+	}
+	defer rows.Close()
+
+	articles := []ArticleSchema{}
+	for rows.Next() {
+		var article ArticleSchema
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, fmt.Errorf("error scanning article: %v", err)
+		}
+		articles = append(articles, article)
+	}
+
+	return articles, rows.Err()
+}
+
+// BulkInsertArticles inserts the articles in a single batch round-trip.
+// The returned slice reports, per article, whether a new row was created;
+// false means an article with the same id already exists.
+func BulkInsertArticles(ctx context.Context, pool *pgxpool.Pool, articles []ArticleSchema) ([]bool, error) {
+
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (article_id, title, publisher, publication_date, url, content, summary, tags, entities, sentiment_score, categories, content_s3_path, status, language)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+    ON CONFLICT (article_id) DO NOTHING;`, articleTableName)
+
+	batch := &pgx.Batch{}
+	for _, article := range articles {
+		entitiesDataJSON, err := json.Marshal(article.Entities)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling entities: %v", err)
+		}
+
+		batch.Queue(insertSQL,
+			article.ArticleId,
+			article.Title,
+			article.Publisher,
+			article.PublicationDate,
+			article.Url,
+			article.Content,
+			article.Summary,
+			article.Tags,
+			entitiesDataJSON,
+			article.SentimentScore,
+			article.Categories,
+			article.ContentS3Path,
+			article.Status,
+			article.Language)
+	}
+
+	results := pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	created := make([]bool, len(articles))
+	for i := range articles {
+		commandTag, err := results.Exec()
+		if err != nil {
+			return nil, fmt.Errorf("error inserting article %s: %v", articles[i].ArticleId, err)
+		}
+		created[i] = commandTag.RowsAffected() == 1
+	}
+
+	return created, nil
+}
+
+// GetArticleIDsByStatus returns the ids of all articles with the given status
+func GetArticleIDsByStatus(ctx context.Context, pool *pgxpool.Pool, status string) ([]string, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT article_id
+	FROM %s
+	WHERE status = $1
+	ORDER BY created_at;`, articleTableName)
+
+	rows, err := pool.Query(ctx, query, status)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching articles: %v", err)
+	}
+	defer rows.Close()
+
+	articleIds := []string{}
+	for rows.Next() {
+		var articleId string
+		if err := rows.Scan(&articleId); err != nil {
+			return nil, fmt.Errorf("error scanning article id: %v", err)
+		}
+		articleIds = append(articleIds, articleId)
+	}
+
+	return articleIds, rows.Err()
+}
+
+// SoftDeleteArticle marks the article as deleted and returns it, nil when there was no live article with the id
+func SoftDeleteArticle(ctx context.Context, pool *pgxpool.Pool, articleId string) (*ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	deleteSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET deleted_at = CURRENT_TIMESTAMP,
+        status = 'deleted',
+        version = version + 1,
+        updated_at = CURRENT_TIMESTAMP
+    WHERE article_id = $1 AND deleted_at IS NULL
+    RETURNING %s;`, articleTableName, articleColumns)
+
+	var article ArticleSchema
+	err := scanArticle(pool.QueryRow(ctx, deleteSQL, articleId), &article)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error deleting article: %v", err)
+	}
+
+	return &article, nil
+}
+
+// HardDeleteArticle removes the article, including soft deleted ones, with everything stored about it in the
+// same transaction: revisions, metadata corrections, chunks, saved search matches, user states and events,
+// collection items, notifications and webhook deliveries. Stored digests are rendered again without it.
+// The purge is recorded with audit (its publisher taken from the row) in the transaction as well.
+// It returns the removed row, or nil when the article did not exist.
+func HardDeleteArticle(ctx context.Context, pool *pgxpool.Pool, articleId string, audit PurgeAuditSchema, renderDigest DigestRenderer) (*ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+	articleRevisionTableName := config.GetEnvironmentVariable("ARTICLE_REVISION_TABLE_NAME")
+	metadataCorrectionTableName := config.GetEnvironmentVariable("METADATA_CORRECTION_TABLE_NAME")
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE article_id = $1;`, articleRevisionTableName), articleId)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting article revisions: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE article_id = $1;`, metadataCorrectionTableName), articleId)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting metadata corrections: %v", err)
+	}
+
+	if err := deleteArticleChunks(ctx, tx, articleId); err != nil {
+		return nil, err
+	}
+	if err := deleteSavedSearchMatchesByArticle(ctx, tx, articleId); err != nil {
+		return nil, err
+	}
+	if err := deleteUserArticleStatesByArticle(ctx, tx, articleId); err != nil {
+		return nil, err
+	}
+	if err := deleteUserEventsByArticle(ctx, tx, articleId); err != nil {
+		return nil, err
+	}
+	if err := deleteCollectionItemsByArticle(ctx, tx, articleId); err != nil {
+		return nil, err
+	}
+	if err := deleteNotificationsByArticle(ctx, tx, articleId); err != nil {
+		return nil, err
+	}
+	if err := redactWebhookDeliveriesByArticle(ctx, tx, articleId); err != nil {
+		return nil, err
+	}
+	if err := redactDigestsByArticle(ctx, tx, articleId, renderDigest); err != nil {
+		return nil, err
+	}
+
+	deleteSQL := fmt.Sprintf(`
+    DELETE FROM %s
+    WHERE article_id = $1
+    RETURNING %s;`, articleTableName, articleColumns)
+
+	var article ArticleSchema
+	err = scanArticle(tx.QueryRow(ctx, deleteSQL, articleId), &article)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error deleting article: %v", err)
+	}
+
+	audit.ArticleId, audit.Publisher = article.ArticleId, article.Publisher
+	if err := insertPurgeAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &article, nil
+}
+
+// PurgeArticleContent drops the full content of the article, of its revisions and of its metadata corrections,
+// and its chunks, keeping title, summary and metadata. The S3 path is cleared, the object must be deleted
+// beforehand. The purge is recorded with audit in the same transaction.
+func PurgeArticleContent(ctx context.Context, pool *pgxpool.Pool, articleId string, audit PurgeAuditSchema) error {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+	articleRevisionTableName := config.GetEnvironmentVariable("ARTICLE_REVISION_TABLE_NAME")
+	metadataCorrectionTableName := config.GetEnvironmentVariable("METADATA_CORRECTION_TABLE_NAME")
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`UPDATE %s SET content = '' WHERE article_id = $1;`, articleRevisionTableName), articleId)
+	if err != nil {
+		return fmt.Errorf("error purging revision content: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`UPDATE %s SET content = '' WHERE article_id = $1;`, metadataCorrectionTableName), articleId)
+	if err != nil {
+		return fmt.Errorf("error purging correction content: %v", err)
+	}
+
+	// the chunks are the content
+	if err := deleteArticleChunks(ctx, tx, articleId); err != nil {
+		return err
+	}
+
+	purgeSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET content = '',
+        content_s3_path = '',
+        content_purged_at = CURRENT_TIMESTAMP
+    WHERE article_id = $1;`, articleTableName)
+
+	_, err = tx.Exec(ctx, purgeSQL, articleId)
+	if err != nil {
+		return fmt.Errorf("error purging article content: %v", err)
+	}
+
+	audit.ArticleId = articleId
+	if err := insertPurgeAudit(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RetentionCandidateFilter selects the articles a retention rule applies to
+type RetentionCandidateFilter struct {
+	Publisher         string    // -- publisher the rule belongs to, empty for the default rule
+	ExcludePublishers []string  // -- publishers with their own rule, skipped by the default rule
+	OlderThan         time.Time // -- publication (or deletion) cutoff
+	ContentOnly       bool      // -- only articles whose content has not been purged yet
+	DeletedOnly       bool      // -- only soft deleted articles, compared on deleted_at
+	Limit             int
+}
+
+// GetRetentionCandidates returns the articles matching a retention rule
+func GetRetentionCandidates(ctx context.Context, pool *pgxpool.Pool, filter RetentionCandidateFilter) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	conditions := []string{}
+	args := []any{}
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.DeletedOnly {
+		addCondition("deleted_at < $%d", filter.OlderThan)
+	} else {
+		addCondition("publication_date < $%d", filter.OlderThan)
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if filter.ContentOnly {
+		conditions = append(conditions, "content_purged_at IS NULL")
+	}
+	if filter.Publisher != "" {
+		addCondition("publisher = $%d", filter.Publisher)
+	}
+	if len(filter.ExcludePublishers) > 0 {
+		addCondition("publisher <> ALL($%d)", filter.ExcludePublishers)
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE %s
+	LIMIT $%d;`, articleColumns, articleTableName, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching retention candidates: %v", err)
+	}
+	defer rows.Close()
+
+	articles := []ArticleSchema{}
+	for rows.Next() {
+		var article ArticleSchema
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, fmt.Errorf("error scanning article: %v", err)
+		}
+		articles = append(articles, article)
+	}
+
+	return articles, rows.Err()
+}
+
+// GetRecentArticles returns live published articles newer than since, newest first, with their
+// embeddings but without their full content
+func GetRecentArticles(ctx context.Context, pool *pgxpool.Pool, since time.Time, limit int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s, embedding
+	FROM %s
+	WHERE publication_date >= $1 AND status = 'published' AND deleted_at IS NULL
+	ORDER BY publication_date DESC
+	LIMIT $2;`, articleListColumns, articleTableName)
+
+	return queryArticlesWithEmbedding(ctx, pool, query, since, limit)
+}
+
+// GetArticlesByIDs returns the live articles with the given ids, with their embeddings but without their full content
+func GetArticlesByIDs(ctx context.Context, pool *pgxpool.Pool, articleIds []string) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s, embedding
+	FROM %s
+	WHERE article_id::TEXT = ANY($1) AND deleted_at IS NULL;`, articleListColumns, articleTableName)
+
+	return queryArticlesWithEmbedding(ctx, pool, query, articleIds)
+}
+
+// GetLabeledArticles returns the most recent live articles with categories and content, left out of the review
+// queue, with their full content but without embeddings. They are the labeled data of the category classifier.
+func GetLabeledArticles(ctx context.Context, pool *pgxpool.Pool, limit int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s, NULL::vector AS embedding
+	FROM %s
+	WHERE status = 'published' AND deleted_at IS NULL AND review_status <> 'pending'
+		AND cardinality(categories) > 0 AND content <> ''
+	ORDER BY publication_date DESC
+	LIMIT $1;`, articleColumns, articleTableName)
+
+	return queryArticlesWithEmbedding(ctx, pool, query, limit)
+}
+
+func queryArticlesWithEmbedding(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]ArticleSchema, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching articles: %v", err)
+	}
+	defer rows.Close()
+
+	articles := []ArticleSchema{}
+	for rows.Next() {
+		var article ArticleSchema
+		var embedding *pgvector.Vector
+
+		if err := scanArticle(rows, &article, &embedding); err != nil {
+			return nil, fmt.Errorf("error scanning article: %v", err)
+		}
+		if embedding != nil {
+			article.Embedding = embedding.Slice()
+		}
+		articles = append(articles, article)
+	}
+
+	return articles, rows.Err()
+}
+
+// EntityNames flattens the extracted entities (organizations, locations and individuals) of the article
+func (article ArticleSchema) EntityNames() []string {
+	entitiesJSON, err := json.Marshal(article.Entities)
+	if err != nil {
+		return nil
+	}
+
+	var entities map[string][]string
+	if err := json.Unmarshal(entitiesJSON, &entities); err != nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, group := range []string{"organizations", "locations", "individuals"} {
+		names = append(names, entities[group]...)
+	}
+	return names
+}
+
+// GetNearestArticles returns the live published articles closest to the embedding (cosine distance), closest first
+func GetNearestArticles(ctx context.Context, pool *pgxpool.Pool, embedding []float32, excludeArticleId string, limit int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s, embedding
+	FROM %s
+	WHERE article_id::TEXT <> $2 AND status = 'published' AND deleted_at IS NULL AND embedding IS NOT NULL
+	ORDER BY embedding <=> $1
+	LIMIT $3;`, articleListColumns, articleTableName)
+
+	return queryArticlesWithEmbedding(ctx, pool, query, embeddingParam(embedding), excludeArticleId, limit)
+}
+
+// GetArticlesSharingTopics returns recent live published articles sharing a category or an entity, newest first
+func GetArticlesSharingTopics(ctx context.Context, pool *pgxpool.Pool, categories []string, entities []string, excludeArticleId string, since time.Time, limit int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s, embedding
+	FROM %s
+	WHERE article_id::TEXT <> $3 AND status = 'published' AND deleted_at IS NULL AND publication_date >= $4
+	  AND (categories && $1 OR EXISTS (
+		SELECT 1
+		FROM jsonb_each(CASE WHEN jsonb_typeof(entities) = 'object' THEN entities ELSE '{}'::JSONB END) AS entity_group,
+		     jsonb_array_elements_text(CASE WHEN jsonb_typeof(entity_group.value) = 'array' THEN entity_group.value ELSE '[]'::JSONB END) AS entity
+		WHERE entity = ANY($2)))
+	ORDER BY publication_date DESC
+	LIMIT $5;`, articleListColumns, articleTableName)
+
+	return queryArticlesWithEmbedding(ctx, pool, query, nonNilStrings(categories), nonNilStrings(entities), excludeArticleId, since, limit)
+}
+
+// FeedKinds are the article selections a syndication feed can be built from
+var FeedKinds = []string{"category", "publisher", "entity", "story"}
+
+// GetFeedArticles returns the latest live published articles of a category, publisher, entity or story,
+// newest first, without their full content. Names are matched case-insensitively.
+func GetFeedArticles(ctx context.Context, pool *pgxpool.Pool, kind string, value string, limit int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	var condition string
+	switch kind {
+	case "category":
+		condition = `EXISTS (SELECT 1 FROM unnest(categories) AS category WHERE lower(category) = lower($1))`
+	case "publisher":
+		condition = `lower(publisher) = lower($1)`
+	case "entity":
+		condition = `EXISTS (
+		SELECT 1
+		FROM jsonb_each(CASE WHEN jsonb_typeof(entities) = 'object' THEN entities ELSE '{}'::JSONB END) AS entity_group,
+		     jsonb_array_elements_text(CASE WHEN jsonb_typeof(entity_group.value) = 'array' THEN entity_group.value ELSE '[]'::JSONB END) AS entity
+		WHERE lower(entity) = lower($1))`
+	case "story":
+		condition = `(story_id::TEXT = $1 OR article_id::TEXT = $1)`
+	default:
+		return nil, fmt.Errorf("unknown feed kind %q", kind)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE %s AND status = 'published' AND deleted_at IS NULL
+	ORDER BY publication_date DESC
+	LIMIT $2;`, articleListColumns, articleTableName, condition)
+
+	rows, err := pool.Query(ctx, query, value, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feed articles: %v", err)
+	}
+	defer rows.Close()
+
+	articles := []ArticleSchema{}
+	for rows.Next() {
+		var article ArticleSchema
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, fmt.Errorf("error scanning article: %v", err)
+		}
+		articles = append(articles, article)
+	}
+
+	return articles, rows.Err()
+}
+
+// GetTopArticlesInPeriod returns the most important live published articles of a period, without their full content
+func GetTopArticlesInPeriod(ctx context.Context, pool *pgxpool.Pool, start time.Time, end time.Time, limit int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE publication_date >= $1 AND publication_date < $2 AND status = 'published' AND deleted_at IS NULL
+	ORDER BY importance_score DESC, publication_date DESC
+	LIMIT $3;`, articleListColumns, articleTableName)
+
+	rows, err := pool.Query(ctx, query, start, end, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching top articles: %v", err)
+	}
+	defer rows.Close()
+
+	articles := []ArticleSchema{}
+	for rows.Next() {
+		var article ArticleSchema
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, fmt.Errorf("error scanning article: %v", err)
+		}
+		articles = append(articles, article)
+	}
+
+	return articles, rows.Err()
+}
+
+// ArticleSearchFilter narrows searches down, empty fields don't filter. Names match case-insensitively.
+type ArticleSearchFilter struct {
+	Publishers []string
+	Categories []string
+	Sentiments []string
+	Languages  []string
+	From       *time.Time // -- published at or after
+	To         *time.Time // -- published before
+	ArticleIds []string   // -- only these articles
+}
+
+// conditions renders the filter as SQL conditions on live published articles, numbering its
+// parameters from firstParam on
+func (filter ArticleSearchFilter) conditions(firstParam int) (string, []any) {
+	conditions := []string{"status = 'published'", "deleted_at IS NULL"}
+	args := []any{}
+
+	addCondition := func(condition string, arg any) {
+		conditions = append(conditions, fmt.Sprintf(condition, firstParam+len(args)))
+		args = append(args, arg)
+	}
+
+	if len(filter.Publishers) > 0 {
+		addCondition("lower(publisher) = ANY($%d)", lowercaseStrings(filter.Publishers))
+	}
+	if len(filter.Categories) > 0 {
+		addCondition("EXISTS (SELECT 1 FROM unnest(categories) AS category WHERE lower(category) = ANY($%d))", lowercaseStrings(filter.Categories))
+	}
+	if len(filter.Sentiments) > 0 {
+		addCondition("lower(sentiment_score) = ANY($%d)", lowercaseStrings(filter.Sentiments))
+	}
+	if len(filter.Languages) > 0 {
+		addCondition("language = ANY($%d)", lowercaseStrings(filter.Languages))
+	}
+	if filter.From != nil {
+		addCondition("publication_date >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("publication_date < $%d", *filter.To)
+	}
+	if len(filter.ArticleIds) > 0 {
+		addCondition("article_id::TEXT = ANY($%d)", filter.ArticleIds)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+func lowercaseStrings(values []string) []string {
+	lowercased := make([]string, len(values))
+	for i, value := range values {
+		lowercased[i] = strings.ToLower(strings.TrimSpace(value))
+	}
+	return lowercased
+}
+
+// SearchArticlesText runs a full-text search (web search syntax: quotes, OR, -word) over the title,
+// summary and content of the articles passing the filter, best match first
+func SearchArticlesText(ctx context.Context, pool *pgxpool.Pool, query string, filter ArticleSearchFilter, limit int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	conditions, args := filter.conditions(3)
+
+	searchSQL := fmt.Sprintf(`
+	SELECT %s, embedding
+	FROM %s
+	WHERE search_vector @@ websearch_to_tsquery('english', $1) AND %s
+	ORDER BY ts_rank_cd(search_vector, websearch_to_tsquery('english', $1)) DESC
+	LIMIT $2;`, articleListColumns, articleTableName, conditions)
+
+	return queryArticlesWithEmbedding(ctx, pool, searchSQL, append([]any{query, limit}, args...)...)
+}
+
+// GetNearestArticlesFiltered returns the articles passing the filter closest to the embedding, closest first
+func GetNearestArticlesFiltered(ctx context.Context, pool *pgxpool.Pool, embedding []float32, filter ArticleSearchFilter, limit int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	conditions, args := filter.conditions(3)
+
+	query := fmt.Sprintf(`
+	SELECT %s, embedding
+	FROM %s
+	WHERE embedding IS NOT NULL AND %s
+	ORDER BY embedding <=> $1
+	LIMIT $2;`, articleListColumns, articleTableName, conditions)
+
+	return queryArticlesWithEmbedding(ctx, pool, query, append([]any{embeddingParam(embedding), limit}, args...)...)
 }
@@ -0,0 +1,215 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgvector/pgvector-go"
+)
+
+// ArticleChunkSchema is a passage of an article with its own embedding, for passage-level search
+type ArticleChunkSchema struct {
+	ArticleId   string    `json:"articleId"`
+	ChunkIndex  int       `json:"chunkIndex"`  // -- position of the passage in the article, from 0
+	Content     string    `json:"content"`     // -- text of the passage
+	StartOffset int       `json:"startOffset"` // -- byte offset of the passage in the article content
+	EndOffset   int       `json:"endOffset"`   // -- byte offset just past the passage
+	TokenCount  int       `json:"tokenCount"`
+	Embedding   []float32 `json:"-"`         // -- vector(1536)
+	CreatedAt   time.Time `json:"createdAt"` // TIMESTAMP
+}
+
+// PassageMatch is a passage found by passage search, with the article it comes from
+type PassageMatch struct {
+	ArticleChunkSchema
+	Title           string    `json:"title"`
+	Publisher       string    `json:"publisher"`
+	Url             string    `json:"url"`
+	PublicationDate time.Time `json:"publicationDate"`
+}
+
+// indexes added after the table was first created, applied on startup
+var articleChunkTableMigrations = []string{
+	`CREATE INDEX IF NOT EXISTS %[1]s_embedding_idx ON %[1]s USING hnsw (embedding vector_cosine_ops);`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (to_tsvector('english', content)) STORED;`,
+	`CREATE INDEX IF NOT EXISTS %[1]s_search_vector_idx ON %[1]s USING gin (search_vector);`,
+}
+
+// CreateArticleChunksTable creates the article chunks table in the database
+func CreateArticleChunksTable(ctx context.Context, pool *pgxpool.Pool) error {
+	articleChunkTableName := config.GetEnvironmentVariable("ARTICLE_CHUNK_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		article_id UUID NOT NULL,
+		chunk_index INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		start_offset INTEGER NOT NULL,
+		end_offset INTEGER NOT NULL,
+		token_count INTEGER NOT NULL,
+		embedding vector(1536),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (article_id, chunk_index)
+	);`, articleChunkTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating article chunks table: ", err)
+		return err
+	}
+
+	for _, migrationSQL := range articleChunkTableMigrations {
+		_, err = pool.Exec(ctx, fmt.Sprintf(migrationSQL, articleChunkTableName))
+		if err != nil {
+			fmt.Println("Error migrating article chunks table: ", err)
+			return err
+		}
+	}
+
+	log.Printf("%s table created successfully or already exists\n", articleChunkTableName)
+	return nil
+}
+
+// ReplaceArticleChunks stores the passages of an article in place of the previous ones
+func ReplaceArticleChunks(ctx context.Context, pool *pgxpool.Pool, articleId string, chunks []ArticleChunkSchema) error {
+	articleChunkTableName := config.GetEnvironmentVariable("ARTICLE_CHUNK_TABLE_NAME")
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := deleteArticleChunks(ctx, tx, articleId); err != nil {
+		return err
+	}
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (article_id, chunk_index, content, start_offset, end_offset, token_count, embedding)
+    VALUES ($1, $2, $3, $4, $5, $6, $7);`, articleChunkTableName)
+
+	batch := &pgx.Batch{}
+	for _, chunk := range chunks {
+		batch.Queue(insertSQL,
+			articleId,
+			chunk.ChunkIndex,
+			chunk.Content,
+			chunk.StartOffset,
+			chunk.EndOffset,
+			chunk.TokenCount,
+			embeddingParam(chunk.Embedding))
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for range chunks {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return fmt.Errorf("error inserting article chunks: %v", err)
+		}
+	}
+	if err := results.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// deleteArticleChunks removes the passages of an article, within the transaction of the article write
+func deleteArticleChunks(ctx context.Context, tx pgx.Tx, articleId string) error {
+	articleChunkTableName := config.GetEnvironmentVariable("ARTICLE_CHUNK_TABLE_NAME")
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE article_id = $1;`, articleChunkTableName), articleId)
+	if err != nil {
+		return fmt.Errorf("error deleting article chunks: %v", err)
+	}
+	return nil
+}
+
+// passageColumns are the columns scanned by queryPassages, from the chunks (c) and the matching articles (m)
+const passageColumns = `c.article_id, c.chunk_index, c.content, c.start_offset, c.end_offset, c.token_count, c.embedding,
+	c.created_at, m.title, m.publisher, m.url, m.publication_date`
+
+// SearchPassagesText runs a full-text search (web search syntax) over the passages of the articles passing
+// the filter, best match first
+func SearchPassagesText(ctx context.Context, pool *pgxpool.Pool, query string, filter ArticleSearchFilter, limit int) ([]PassageMatch, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+	articleChunkTableName := config.GetEnvironmentVariable("ARTICLE_CHUNK_TABLE_NAME")
+
+	conditions, args := filter.conditions(3)
+
+	searchSQL := fmt.Sprintf(`
+	WITH m AS (
+		SELECT article_id, title, publisher, url, publication_date FROM %s WHERE %s
+	)
+	SELECT %s
+	FROM %s c JOIN m ON m.article_id = c.article_id
+	WHERE c.search_vector @@ websearch_to_tsquery('english', $1)
+	ORDER BY ts_rank_cd(c.search_vector, websearch_to_tsquery('english', $1)) DESC
+	LIMIT $2;`, articleTableName, conditions, passageColumns, articleChunkTableName)
+
+	return queryPassages(ctx, pool, searchSQL, append([]any{query, limit}, args...)...)
+}
+
+// GetNearestPassages returns the passages of the articles passing the filter closest to the embedding, closest first
+func GetNearestPassages(ctx context.Context, pool *pgxpool.Pool, embedding []float32, filter ArticleSearchFilter, limit int) ([]PassageMatch, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+	articleChunkTableName := config.GetEnvironmentVariable("ARTICLE_CHUNK_TABLE_NAME")
+
+	conditions, args := filter.conditions(3)
+
+	query := fmt.Sprintf(`
+	WITH m AS (
+		SELECT article_id, title, publisher, url, publication_date FROM %s WHERE %s
+	)
+	SELECT %s
+	FROM %s c JOIN m ON m.article_id = c.article_id
+	WHERE c.embedding IS NOT NULL
+	ORDER BY c.embedding <=> $1
+	LIMIT $2;`, articleTableName, conditions, passageColumns, articleChunkTableName)
+
+	return queryPassages(ctx, pool, query, append([]any{embeddingParam(embedding), limit}, args...)...)
+}
+
+func queryPassages(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]PassageMatch, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching passages: %v", err)
+	}
+	defer rows.Close()
+
+	passages := []PassageMatch{}
+	for rows.Next() {
+		var passage PassageMatch
+		var embedding *pgvector.Vector
+
+		err := rows.Scan(
+			&passage.ArticleId,
+			&passage.ChunkIndex,
+			&passage.Content,
+			&passage.StartOffset,
+			&passage.EndOffset,
+			&passage.TokenCount,
+			&embedding,
+			&passage.CreatedAt,
+			&passage.Title,
+			&passage.Publisher,
+			&passage.Url,
+			&passage.PublicationDate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning passage: %v", err)
+		}
+		if embedding != nil {
+			passage.Embedding = embedding.Slice()
+		}
+		passages = append(passages, passage)
+	}
+
+	return passages, rows.Err()
+}
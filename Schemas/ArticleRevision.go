@@ -0,0 +1,183 @@
+package schemas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ArticleRevisionSchema struct {
+	ArticleId      string                  `json:"articleId"`
+	RevisionNumber int                     `json:"revisionNumber"` // -- version of the article this revision was captured from
+	Title          string                  `json:"title"`
+	Content        string                  `json:"content,omitempty"`
+	Summary        string                  `json:"summary"`
+	Metadata       ArticleRevisionMetadata `json:"metadata"`
+	RevisedAt      time.Time               `json:"revisedAt"` // TIMESTAMP -- when this version was replaced
+}
+
+// ArticleRevisionMetadata holds the remaining fields of the article at the time of the revision
+type ArticleRevisionMetadata struct {
+	Publisher       string    `json:"publisher"`
+	PublicationDate time.Time `json:"publicationDate"`
+	Url             string    `json:"url"`
+	Tags            []string  `json:"tags"`
+	Entities        any       `json:"entities"`
+	SentimentScore  string    `json:"sentimentScore"`
+	Categories      []string  `json:"categories"`
+	ContentS3Path   string    `json:"contentS3Path"`
+	Status          string    `json:"status"`
+}
+
+// CreateArticleRevisionsTable creates the article revisions table in the database
+func CreateArticleRevisionsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	articleRevisionTableName := config.GetEnvironmentVariable("ARTICLE_REVISION_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		article_id UUID NOT NULL,
+		revision_number INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		summary TEXT,
+		metadata JSONB,
+		revised_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (article_id, revision_number)
+	);`, articleRevisionTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating article revisions table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", articleRevisionTableName)
+	return nil
+}
+
+// insertArticleRevision stores the given (about to be overwritten) version of an article
+func insertArticleRevision(ctx context.Context, tx pgx.Tx, article ArticleSchema) error {
+	articleRevisionTableName := config.GetEnvironmentVariable("ARTICLE_REVISION_TABLE_NAME")
+
+	metadataJSON, err := json.Marshal(ArticleRevisionMetadata{
+		Publisher:       article.Publisher,
+		PublicationDate: article.PublicationDate,
+		Url:             article.Url,
+		Tags:            article.Tags,
+		Entities:        article.Entities,
+		SentimentScore:  article.SentimentScore,
+		Categories:      article.Categories,
+		ContentS3Path:   article.ContentS3Path,
+		Status:          article.Status,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling revision metadata: %v", err)
+	}
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (article_id, revision_number, title, content, summary, metadata)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    ON CONFLICT (article_id, revision_number) DO NOTHING;`, articleRevisionTableName)
+
+	_, err = tx.Exec(ctx, insertSQL,
+		article.ArticleId,
+		article.Version,
+		article.Title,
+		article.Content,
+		article.Summary,
+		metadataJSON)
+	if err != nil {
+		return fmt.Errorf("error inserting article revision: %v", err)
+	}
+
+	return nil
+}
+
+// GetArticleRevisions lists the revisions of an article, newest first, without their content
+func GetArticleRevisions(ctx context.Context, pool *pgxpool.Pool, articleId string) ([]ArticleRevisionSchema, error) {
+	articleRevisionTableName := config.GetEnvironmentVariable("ARTICLE_REVISION_TABLE_NAME")
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	// revisions of deleted articles are taken down with them
+	query := fmt.Sprintf(`
+	SELECT r.article_id, r.revision_number, r.title, '', r.summary, r.metadata, r.revised_at
+	FROM %s r
+	JOIN %s a ON a.article_id = r.article_id AND a.deleted_at IS NULL
+	WHERE r.article_id = $1
+	ORDER BY r.revision_number DESC;`, articleRevisionTableName, articleTableName)
+
+	rows, err := pool.Query(ctx, query, articleId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching article revisions: %v", err)
+	}
+	defer rows.Close()
+
+	revisions := []ArticleRevisionSchema{}
+	for rows.Next() {
+		var revision ArticleRevisionSchema
+		if err := scanArticleRevision(rows, &revision); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetArticleRevision retrieves a single revision of an article, nil if it or the article does not exist
+func GetArticleRevision(ctx context.Context, pool *pgxpool.Pool, articleId string, revisionNumber int) (*ArticleRevisionSchema, error) {
+	articleRevisionTableName := config.GetEnvironmentVariable("ARTICLE_REVISION_TABLE_NAME")
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT r.article_id, r.revision_number, r.title, r.content, r.summary, r.metadata, r.revised_at
+	FROM %s r
+	JOIN %s a ON a.article_id = r.article_id AND a.deleted_at IS NULL
+	WHERE r.article_id = $1 AND r.revision_number = $2;`, articleRevisionTableName, articleTableName)
+
+	var revision ArticleRevisionSchema
+	err := scanArticleRevision(pool.QueryRow(ctx, query, articleId, revisionNumber), &revision)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+func scanArticleRevision(row pgx.Row, revision *ArticleRevisionSchema) error {
+	var metadataJSON []byte
+
+	err := row.Scan(
+		&revision.ArticleId,
+		&revision.RevisionNumber,
+		&revision.Title,
+		&revision.Content,
+		&revision.Summary,
+		&metadataJSON,
+		&revision.RevisedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return err
+		}
+		return fmt.Errorf("error fetching article revision: %v", err)
+	}
+
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &revision.Metadata); err != nil {
+			return fmt.Errorf("error unmarshaling revision metadata: %v", err)
+		}
+	}
+
+	return nil
+}
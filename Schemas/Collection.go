@@ -0,0 +1,172 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CollectionSchema struct {
+	CollectionId int64      `json:"collectionId"`
+	UserId       string     `json:"userId"`
+	Name         string     `json:"name"`
+	CreatedAt    time.Time  `json:"createdAt"` // TIMESTAMP
+	UpdatedAt    time.Time  `json:"updatedAt"` // TIMESTAMP -- cursor for incremental sync
+	DeletedAt    *time.Time `json:"deletedAt"` // TIMESTAMP -- kept as a tombstone so clients can sync deletions
+}
+
+const collectionColumns = `collection_id, user_id, name, created_at, updated_at, deleted_at`
+
+// CreateCollectionsTable creates the user collections table in the database
+func CreateCollectionsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	collectionTableName := config.GetEnvironmentVariable("COLLECTION_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		collection_id BIGSERIAL PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		deleted_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_updated_at_idx ON %[1]s (user_id, updated_at);`, collectionTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating collections table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", collectionTableName)
+	return nil
+}
+
+// InsertCollection creates a collection for a user
+func InsertCollection(ctx context.Context, pool *pgxpool.Pool, userId string, name string) (*CollectionSchema, error) {
+	collectionTableName := config.GetEnvironmentVariable("COLLECTION_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (user_id, name)
+    VALUES ($1, $2)
+    RETURNING %s;`, collectionTableName, collectionColumns)
+
+	var collection CollectionSchema
+	if err := scanCollection(pool.QueryRow(ctx, insertSQL, userId, name), &collection); err != nil {
+		return nil, fmt.Errorf("error inserting collection: %v", err)
+	}
+
+	return &collection, nil
+}
+
+// RenameCollection renames a live collection of a user, it returns nil when the user has no such collection
+func RenameCollection(ctx context.Context, pool *pgxpool.Pool, userId string, collectionId int64, name string) (*CollectionSchema, error) {
+	collectionTableName := config.GetEnvironmentVariable("COLLECTION_TABLE_NAME")
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET name = $3, updated_at = CURRENT_TIMESTAMP
+    WHERE user_id = $1 AND collection_id = $2 AND deleted_at IS NULL
+    RETURNING %s;`, collectionTableName, collectionColumns)
+
+	return queryCollection(ctx, pool, updateSQL, userId, collectionId, name)
+}
+
+// DeleteCollection tombstones a collection of a user and its items, it returns nil when the user has no such collection
+func DeleteCollection(ctx context.Context, pool *pgxpool.Pool, userId string, collectionId int64) (*CollectionSchema, error) {
+	collectionTableName := config.GetEnvironmentVariable("COLLECTION_TABLE_NAME")
+	collectionItemTableName := config.GetEnvironmentVariable("COLLECTION_ITEM_TABLE_NAME")
+
+	deleteSQL := fmt.Sprintf(`
+    WITH deleted_items AS (
+        UPDATE %[2]s
+        SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND collection_id = $2 AND deleted_at IS NULL
+    )
+    UPDATE %[1]s
+    SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+    WHERE user_id = $1 AND collection_id = $2 AND deleted_at IS NULL
+    RETURNING %[3]s;`, collectionTableName, collectionItemTableName, collectionColumns)
+
+	return queryCollection(ctx, pool, deleteSQL, userId, collectionId)
+}
+
+// GetLiveCollection fetches a collection of a user that isn't deleted, nil when there is none
+func GetLiveCollection(ctx context.Context, pool *pgxpool.Pool, userId string, collectionId int64) (*CollectionSchema, error) {
+	collectionTableName := config.GetEnvironmentVariable("COLLECTION_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE user_id = $1 AND collection_id = $2 AND deleted_at IS NULL;`, collectionColumns, collectionTableName)
+
+	return queryCollection(ctx, pool, query, userId, collectionId)
+}
+
+// SyncCursor is the position of the collection in the library changes
+func (collection CollectionSchema) SyncCursor() SyncCursor {
+	return SyncCursor{UpdatedAt: collection.UpdatedAt, Key: strconv.FormatInt(collection.CollectionId, 10)}
+}
+
+// GetCollections lists the collections of a user, oldest change first. Without a cursor only live
+// collections are returned, with it the deleted ones come along as tombstones.
+func GetCollections(ctx context.Context, pool *pgxpool.Pool, userId string, after *SyncCursor) ([]CollectionSchema, error) {
+	collectionTableName := config.GetEnvironmentVariable("COLLECTION_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE user_id = $1
+	  AND (($2::TIMESTAMP IS NULL AND deleted_at IS NULL)
+	    OR (updated_at, collection_id::TEXT COLLATE "C") > ($2, $3::TEXT COLLATE "C"))
+	ORDER BY updated_at, collection_id::TEXT COLLATE "C";`, collectionColumns, collectionTableName)
+
+	afterUpdatedAt, afterKey := syncCursorArgs(after)
+	rows, err := pool.Query(ctx, query, userId, afterUpdatedAt, afterKey)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching collections: %v", err)
+	}
+	defer rows.Close()
+
+	collections := []CollectionSchema{}
+	for rows.Next() {
+		var collection CollectionSchema
+		if err := scanCollection(rows, &collection); err != nil {
+			return nil, fmt.Errorf("error scanning collection: %v", err)
+		}
+		collections = append(collections, collection)
+	}
+
+	return collections, rows.Err()
+}
+
+func queryCollection(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) (*CollectionSchema, error) {
+	var collection CollectionSchema
+	err := scanCollection(pool.QueryRow(ctx, query, args...), &collection)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching collection: %v", err)
+	}
+
+	return &collection, nil
+}
+
+func scanCollection(row pgx.Row, collection *CollectionSchema) error {
+	return row.Scan(
+		&collection.CollectionId,
+		&collection.UserId,
+		&collection.Name,
+		&collection.CreatedAt,
+		&collection.UpdatedAt,
+		&collection.DeletedAt,
+	)
+}
@@ -0,0 +1,154 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CollectionItemSchema struct {
+	CollectionId int64      `json:"collectionId"`
+	UserId       string     `json:"userId"`
+	ArticleId    string     `json:"articleId"`
+	AddedAt      time.Time  `json:"addedAt"`   // TIMESTAMP
+	UpdatedAt    time.Time  `json:"updatedAt"` // TIMESTAMP -- cursor for incremental sync
+	DeletedAt    *time.Time `json:"deletedAt"` // TIMESTAMP -- set when the article is removed from the collection
+}
+
+const collectionItemColumns = `collection_id, user_id, article_id::TEXT, added_at, updated_at, deleted_at`
+
+// CreateCollectionItemsTable creates the table of articles saved in collections in the database
+func CreateCollectionItemsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	collectionItemTableName := config.GetEnvironmentVariable("COLLECTION_ITEM_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		collection_id BIGINT NOT NULL,
+		user_id TEXT NOT NULL,
+		article_id UUID NOT NULL,
+		added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		deleted_at TIMESTAMP,
+		PRIMARY KEY (collection_id, article_id)
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_updated_at_idx ON %[1]s (user_id, updated_at);`, collectionItemTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating collection items table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", collectionItemTableName)
+	return nil
+}
+
+// AddCollectionItem puts an article in a collection of the user, re-adding a removed article revives it
+func AddCollectionItem(ctx context.Context, pool *pgxpool.Pool, userId string, collectionId int64, articleId string) (*CollectionItemSchema, error) {
+	collectionItemTableName := config.GetEnvironmentVariable("COLLECTION_ITEM_TABLE_NAME")
+
+	upsertSQL := fmt.Sprintf(`
+    INSERT INTO %[1]s AS item (collection_id, user_id, article_id)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (collection_id, article_id) DO UPDATE
+    SET added_at = CASE WHEN item.deleted_at IS NULL THEN item.added_at ELSE CURRENT_TIMESTAMP END,
+        updated_at = CURRENT_TIMESTAMP,
+        deleted_at = NULL
+    RETURNING %[2]s;`, collectionItemTableName, collectionItemColumns)
+
+	var item CollectionItemSchema
+	if err := scanCollectionItem(pool.QueryRow(ctx, upsertSQL, collectionId, userId, articleId), &item); err != nil {
+		return nil, fmt.Errorf("error adding collection item: %v", err)
+	}
+
+	return &item, nil
+}
+
+// RemoveCollectionItem tombstones an article of a collection of the user, it returns nil when it wasn't in it
+func RemoveCollectionItem(ctx context.Context, pool *pgxpool.Pool, userId string, collectionId int64, articleId string) (*CollectionItemSchema, error) {
+	collectionItemTableName := config.GetEnvironmentVariable("COLLECTION_ITEM_TABLE_NAME")
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+    WHERE user_id = $1 AND collection_id = $2 AND article_id = $3 AND deleted_at IS NULL
+    RETURNING %s;`, collectionItemTableName, collectionItemColumns)
+
+	var item CollectionItemSchema
+	err := scanCollectionItem(pool.QueryRow(ctx, updateSQL, userId, collectionId, articleId), &item)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error removing collection item: %v", err)
+	}
+
+	return &item, nil
+}
+
+// SyncCursor is the position of the item in the library changes
+func (item CollectionItemSchema) SyncCursor() SyncCursor {
+	return SyncCursor{UpdatedAt: item.UpdatedAt, Key: fmt.Sprintf("%d:%s", item.CollectionId, item.ArticleId)}
+}
+
+// GetCollectionItems lists the items of a user's collections (one collection when collectionId is set), oldest
+// change first. Without a cursor only live items are returned, with it removals come along as tombstones.
+func GetCollectionItems(ctx context.Context, pool *pgxpool.Pool, userId string, collectionId *int64, after *SyncCursor, limit int, offset int) ([]CollectionItemSchema, error) {
+	collectionItemTableName := config.GetEnvironmentVariable("COLLECTION_ITEM_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE user_id = $1
+	  AND ($2::BIGINT IS NULL OR collection_id = $2)
+	  AND (($3::TIMESTAMP IS NULL AND deleted_at IS NULL)
+	    OR (updated_at, (collection_id::TEXT || ':' || article_id::TEXT) COLLATE "C") > ($3, $4::TEXT COLLATE "C"))
+	ORDER BY updated_at, (collection_id::TEXT || ':' || article_id::TEXT) COLLATE "C"
+	LIMIT $5 OFFSET $6;`, collectionItemColumns, collectionItemTableName)
+
+	afterUpdatedAt, afterKey := syncCursorArgs(after)
+	rows, err := pool.Query(ctx, query, userId, collectionId, afterUpdatedAt, afterKey, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching collection items: %v", err)
+	}
+	defer rows.Close()
+
+	items := []CollectionItemSchema{}
+	for rows.Next() {
+		var item CollectionItemSchema
+		if err := scanCollectionItem(rows, &item); err != nil {
+			return nil, fmt.Errorf("error scanning collection item: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// deleteCollectionItemsByArticle removes a purged article from every collection, within the purge transaction
+func deleteCollectionItemsByArticle(ctx context.Context, tx pgx.Tx, articleId string) error {
+	collectionItemTableName := config.GetEnvironmentVariable("COLLECTION_ITEM_TABLE_NAME")
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE article_id = $1;`, collectionItemTableName), articleId)
+	if err != nil {
+		return fmt.Errorf("error deleting collection items: %v", err)
+	}
+	return nil
+}
+
+func scanCollectionItem(row pgx.Row, item *CollectionItemSchema) error {
+	return row.Scan(
+		&item.CollectionId,
+		&item.UserId,
+		&item.ArticleId,
+		&item.AddedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+	)
+}
@@ -0,0 +1,207 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DigestStorySchema struct {
+	ArticleId       string    `json:"articleId"`
+	StoryId         string    `json:"storyId"`
+	Title           string    `json:"title"`
+	Url             string    `json:"url"`
+	Publisher       string    `json:"publisher"`
+	PublicationDate time.Time `json:"publicationDate"` // TIMESTAMP
+	ImportanceScore int       `json:"importanceScore"`
+	Blurb           string    `json:"blurb"` // -- LLM written from the article summary
+}
+
+type DigestSectionSchema struct {
+	Category string              `json:"category"`
+	Stories  []DigestStorySchema `json:"stories"`
+}
+
+type DigestSchema struct {
+	DigestId    int64                 `json:"digestId"`
+	Edition     string                `json:"edition"` // -- e.g., "daily"
+	Title       string                `json:"title"`
+	PeriodStart time.Time             `json:"periodStart"` // TIMESTAMP
+	PeriodEnd   time.Time             `json:"periodEnd"`   // TIMESTAMP
+	Intro       string                `json:"intro"`
+	Sections    []DigestSectionSchema `json:"sections"`
+	Html        string                `json:"html"`
+	Text        string                `json:"text"`
+	CreatedAt   time.Time             `json:"createdAt"` // TIMESTAMP
+}
+
+// DigestRenderer renders the HTML and plain-text versions of a digest from its sections
+type DigestRenderer func(digest DigestSchema) (html string, text string, err error)
+
+const digestColumns = `digest_id, edition, title, period_start, period_end, intro, sections, html, text, created_at`
+
+// CreateDigestsTable creates the digests table in the database
+func CreateDigestsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	digestTableName := config.GetEnvironmentVariable("DIGEST_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		digest_id BIGSERIAL PRIMARY KEY,
+		edition TEXT NOT NULL,
+		title TEXT NOT NULL,
+		period_start TIMESTAMP NOT NULL,
+		period_end TIMESTAMP NOT NULL,
+		intro TEXT NOT NULL DEFAULT '',
+		sections JSONB NOT NULL DEFAULT '[]',
+		html TEXT NOT NULL DEFAULT '',
+		text TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_edition_idx ON %[1]s (edition, created_at DESC);`, digestTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating digests table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", digestTableName)
+	return nil
+}
+
+// InsertDigest stores a generated digest and returns it with its id
+func InsertDigest(ctx context.Context, pool *pgxpool.Pool, digest DigestSchema) (*DigestSchema, error) {
+	digestTableName := config.GetEnvironmentVariable("DIGEST_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (edition, title, period_start, period_end, intro, sections, html, text)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    RETURNING %s;`, digestTableName, digestColumns)
+
+	if digest.Sections == nil {
+		digest.Sections = []DigestSectionSchema{}
+	}
+
+	var storedDigest DigestSchema
+	err := scanDigest(pool.QueryRow(ctx, insertSQL,
+		digest.Edition,
+		digest.Title,
+		digest.PeriodStart,
+		digest.PeriodEnd,
+		digest.Intro,
+		digest.Sections,
+		digest.Html,
+		digest.Text), &storedDigest)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting digest: %v", err)
+	}
+
+	return &storedDigest, nil
+}
+
+// GetLatestDigest returns the most recent digest of an edition, nil when none was generated yet
+func GetLatestDigest(ctx context.Context, pool *pgxpool.Pool, edition string) (*DigestSchema, error) {
+	digestTableName := config.GetEnvironmentVariable("DIGEST_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE edition = $1
+	ORDER BY created_at DESC
+	LIMIT 1;`, digestColumns, digestTableName)
+
+	var digest DigestSchema
+	err := scanDigest(pool.QueryRow(ctx, query, edition), &digest)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching digest: %v", err)
+	}
+
+	return &digest, nil
+}
+
+// redactDigestsByArticle removes a purged article from the stored digests and renders them again,
+// within the purge transaction
+func redactDigestsByArticle(ctx context.Context, tx pgx.Tx, articleId string, render DigestRenderer) error {
+	digestTableName := config.GetEnvironmentVariable("DIGEST_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE sections @> jsonb_build_array(jsonb_build_object('stories', jsonb_build_array(jsonb_build_object('articleId', $1::TEXT))))
+	FOR UPDATE;`, digestColumns, digestTableName)
+
+	rows, err := tx.Query(ctx, query, articleId)
+	if err != nil {
+		return fmt.Errorf("error fetching digests of article: %v", err)
+	}
+
+	digests := []DigestSchema{}
+	for rows.Next() {
+		var digest DigestSchema
+		if err := scanDigest(rows, &digest); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning digest: %v", err)
+		}
+		digests = append(digests, digest)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET sections = $2, html = $3, text = $4
+    WHERE digest_id = $1;`, digestTableName)
+
+	for _, digest := range digests {
+		sections := []DigestSectionSchema{}
+		for _, section := range digest.Sections {
+			stories := []DigestStorySchema{}
+			for _, story := range section.Stories {
+				if story.ArticleId != articleId {
+					stories = append(stories, story)
+				}
+			}
+			if len(stories) > 0 {
+				section.Stories = stories
+				sections = append(sections, section)
+			}
+		}
+		digest.Sections = sections
+
+		html, text, err := render(digest)
+		if err != nil {
+			return fmt.Errorf("error rendering digest %d: %v", digest.DigestId, err)
+		}
+
+		if _, err := tx.Exec(ctx, updateSQL, digest.DigestId, digest.Sections, html, text); err != nil {
+			return fmt.Errorf("error redacting digest %d: %v", digest.DigestId, err)
+		}
+	}
+	return nil
+}
+
+func scanDigest(row pgx.Row, digest *DigestSchema) error {
+	return row.Scan(
+		&digest.DigestId,
+		&digest.Edition,
+		&digest.Title,
+		&digest.PeriodStart,
+		&digest.PeriodEnd,
+		&digest.Intro,
+		&digest.Sections,
+		&digest.Html,
+		&digest.Text,
+		&digest.CreatedAt,
+	)
+}
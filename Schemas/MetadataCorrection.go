@@ -0,0 +1,199 @@
+package schemas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lib/pq"
+)
+
+// MetadataFields are the generated fields of an article an editor can override and lock
+var MetadataFields = []string{"summary", "categories", "entities", "sentimentScore"}
+
+// MetadataCorrectionSchema is an editor's verdict on the generated metadata of an article, kept as labeled data
+// to evaluate prompts against
+type MetadataCorrectionSchema struct {
+	CorrectionId   int64              `json:"correctionId"`
+	ArticleId      string             `json:"articleId"`
+	EditorId       string             `json:"editorId"`
+	Kind           string             `json:"kind"`           // -- "override" (fields were changed) or "confirmation" (output approved as is)
+	Fields         pq.StringArray     `json:"fields"`         // -- e.g., ["summary", "categories"], the fields that were changed
+	Original       ArticleMetadataSet `json:"original"`       // -- metadata as generated, before the correction
+	Corrected      ArticleMetadataSet `json:"corrected"`      // -- metadata as approved by the editor
+	Confidence     int                `json:"confidence"`     // -- enrichment confidence of the original metadata
+	PromptVersions map[string]string  `json:"promptVersions"` // -- prompt versions that generated the original metadata
+	Title          string             `json:"title"`
+	Content        string             `json:"content,omitempty"` // -- article content the metadata was generated from, emptied by retention
+	CreatedAt      time.Time          `json:"createdAt"`         // TIMESTAMP
+}
+
+// ArticleMetadataSet holds the overridable metadata of an article
+type ArticleMetadataSet struct {
+	Summary        string   `json:"summary"`
+	Categories     []string `json:"categories"`
+	Entities       any      `json:"entities"`
+	SentimentScore string   `json:"sentimentScore"`
+}
+
+// MetadataCorrectionFilter narrows down the exported corrections, empty fields don't filter
+type MetadataCorrectionFilter struct {
+	Field  string     // -- only corrections that changed this field
+	Kind   string     // -- "override" or "confirmation"
+	Since  *time.Time // -- created at or after
+	Limit  int
+	Offset int
+}
+
+const metadataCorrectionColumns = `correction_id, article_id, editor_id, kind, fields, original, corrected, confidence, title, content, created_at, prompt_versions`
+
+// CreateMetadataCorrectionsTable creates the metadata corrections table in the database
+func CreateMetadataCorrectionsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	metadataCorrectionTableName := config.GetEnvironmentVariable("METADATA_CORRECTION_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		correction_id BIGSERIAL PRIMARY KEY,
+		article_id UUID NOT NULL,
+		editor_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		fields TEXT[] NOT NULL DEFAULT '{}',
+		original JSONB NOT NULL,
+		corrected JSONB NOT NULL,
+		confidence INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_article_id_idx ON %[1]s (article_id);
+	ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS prompt_versions JSONB NOT NULL DEFAULT '{}';`, metadataCorrectionTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating metadata corrections table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", metadataCorrectionTableName)
+	return nil
+}
+
+func scanMetadataCorrection(row pgx.Row, correction *MetadataCorrectionSchema) error {
+	var originalJSON, correctedJSON []byte
+
+	err := row.Scan(
+		&correction.CorrectionId,
+		&correction.ArticleId,
+		&correction.EditorId,
+		&correction.Kind,
+		&correction.Fields,
+		&originalJSON,
+		&correctedJSON,
+		&correction.Confidence,
+		&correction.Title,
+		&correction.Content,
+		&correction.CreatedAt,
+		&correction.PromptVersions,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(originalJSON, &correction.Original); err != nil {
+		return fmt.Errorf("error unmarshaling original metadata: %v", err)
+	}
+	if err := json.Unmarshal(correctedJSON, &correction.Corrected); err != nil {
+		return fmt.Errorf("error unmarshaling corrected metadata: %v", err)
+	}
+
+	return nil
+}
+
+// insertMetadataCorrection stores a correction as part of the transaction writing it to the article
+func insertMetadataCorrection(ctx context.Context, tx pgx.Tx, correction MetadataCorrectionSchema) error {
+	metadataCorrectionTableName := config.GetEnvironmentVariable("METADATA_CORRECTION_TABLE_NAME")
+
+	originalJSON, err := json.Marshal(correction.Original)
+	if err != nil {
+		return fmt.Errorf("error marshaling original metadata: %v", err)
+	}
+	correctedJSON, err := json.Marshal(correction.Corrected)
+	if err != nil {
+		return fmt.Errorf("error marshaling corrected metadata: %v", err)
+	}
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (article_id, editor_id, kind, fields, original, corrected, confidence, title, content, prompt_versions)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`, metadataCorrectionTableName)
+
+	_, err = tx.Exec(ctx, insertSQL,
+		correction.ArticleId,
+		correction.EditorId,
+		correction.Kind,
+		nonNilStrings(correction.Fields),
+		originalJSON,
+		correctedJSON,
+		correction.Confidence,
+		correction.Title,
+		correction.Content,
+		promptVersionsParam(correction.PromptVersions))
+	if err != nil {
+		return fmt.Errorf("error inserting metadata correction: %v", err)
+	}
+
+	return nil
+}
+
+// GetMetadataCorrections lists the corrections passing the filter, oldest first
+func GetMetadataCorrections(ctx context.Context, pool *pgxpool.Pool, filter MetadataCorrectionFilter) ([]MetadataCorrectionSchema, error) {
+	metadataCorrectionTableName := config.GetEnvironmentVariable("METADATA_CORRECTION_TABLE_NAME")
+
+	conditions := []string{"TRUE"}
+	args := []any{filter.Limit, filter.Offset}
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Field != "" {
+		addCondition("$%d = ANY(fields)", filter.Field)
+	}
+	if filter.Kind != "" {
+		addCondition("kind = $%d", filter.Kind)
+	}
+	if filter.Since != nil {
+		addCondition("created_at >= $%d", *filter.Since)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE %s
+	ORDER BY correction_id
+	LIMIT $1 OFFSET $2;`, metadataCorrectionColumns, metadataCorrectionTableName, strings.Join(conditions, " AND "))
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching metadata corrections: %v", err)
+	}
+	defer rows.Close()
+
+	corrections := []MetadataCorrectionSchema{}
+	for rows.Next() {
+		var correction MetadataCorrectionSchema
+		if err := scanMetadataCorrection(rows, &correction); err != nil {
+			return nil, fmt.Errorf("error scanning metadata correction: %v", err)
+		}
+		corrections = append(corrections, correction)
+	}
+
+	return corrections, rows.Err()
+}
@@ -0,0 +1,97 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type NotificationLogSchema struct {
+	UserId    string    `json:"userId"`
+	StoryId   string    `json:"storyId"` // -- a user is notified at most once per story
+	ArticleId string    `json:"articleId"`
+	RuleId    int64     `json:"ruleId"`
+	Status    string    `json:"status"` // -- "sent", "failed" or "suppressed" (quiet hours)
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"createdAt"` // TIMESTAMP
+}
+
+// CreateNotificationLogTable creates the notification log table in the database
+func CreateNotificationLogTable(ctx context.Context, pool *pgxpool.Pool) error {
+	notificationLogTableName := config.GetEnvironmentVariable("NOTIFICATION_LOG_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		user_id TEXT NOT NULL,
+		story_id TEXT NOT NULL,
+		article_id UUID NOT NULL,
+		rule_id BIGINT NOT NULL,
+		status TEXT NOT NULL,
+		details TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, story_id)
+	);`, notificationLogTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating notification log table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", notificationLogTableName)
+	return nil
+}
+
+// ClaimStoryNotification records that the user is being notified about the story.
+// It returns false when the user was already notified about it, so every story is sent once.
+func ClaimStoryNotification(ctx context.Context, pool *pgxpool.Pool, notification NotificationLogSchema) (bool, error) {
+	notificationLogTableName := config.GetEnvironmentVariable("NOTIFICATION_LOG_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (user_id, story_id, article_id, rule_id, status, details)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    ON CONFLICT (user_id, story_id) DO NOTHING;`, notificationLogTableName)
+
+	commandTag, err := pool.Exec(ctx, insertSQL,
+		notification.UserId,
+		notification.StoryId,
+		notification.ArticleId,
+		notification.RuleId,
+		notification.Status,
+		notification.Details)
+	if err != nil {
+		return false, fmt.Errorf("error inserting notification log: %v", err)
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+// UpdateNotificationStatus records the outcome of a claimed notification
+func UpdateNotificationStatus(ctx context.Context, pool *pgxpool.Pool, userId string, storyId string, status string, details string) error {
+	notificationLogTableName := config.GetEnvironmentVariable("NOTIFICATION_LOG_TABLE_NAME")
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET status = $3, details = $4
+    WHERE user_id = $1 AND story_id = $2;`, notificationLogTableName)
+
+	_, err := pool.Exec(ctx, updateSQL, userId, storyId, status, details)
+	return err
+}
+
+// deleteNotificationsByArticle removes the notifications sent about a purged article, within the purge transaction
+func deleteNotificationsByArticle(ctx context.Context, tx pgx.Tx, articleId string) error {
+	notificationLogTableName := config.GetEnvironmentVariable("NOTIFICATION_LOG_TABLE_NAME")
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE article_id = $1;`, notificationLogTableName), articleId)
+	if err != nil {
+		return fmt.Errorf("error deleting notification log: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PromptVersionSchema is a prompt version added through the API, on top of the ones embedded in utils/prompts
+type PromptVersionSchema struct {
+	Name          string    `json:"name"`    // -- e.g., "metadata", "summary"
+	Version       string    `json:"version"` // -- e.g., "v2"
+	System        string    `json:"system"`  // -- text/template of the system message
+	User          string    `json:"user"`    // -- text/template of the user message
+	Model         string    `json:"model"`   // -- e.g., "gpt-4o-mini"
+	Temperature   float64   `json:"temperature"`
+	TrafficWeight int       `json:"trafficWeight"` // -- share of the traffic of the prompt, relative to the other versions
+	CreatedAt     time.Time `json:"createdAt"`     // TIMESTAMP
+	UpdatedAt     time.Time `json:"updatedAt"`     // TIMESTAMP
+}
+
+// PromptVersionStats compares the output of a prompt version on the articles it enriched
+type PromptVersionStats struct {
+	Version          string         `json:"version"`
+	Articles         int            `json:"articles"`
+	AvgConfidence    float64        `json:"avgConfidence"`
+	AvgImportance    float64        `json:"avgImportance"`
+	AvgCategories    float64        `json:"avgCategories"`
+	AvgSummaryLength float64        `json:"avgSummaryLength"` // -- characters
+	PendingReview    int            `json:"pendingReview"`
+	Sentiments       map[string]int `json:"sentiments"`    // -- e.g., {"Positive": 10, "Negative": 4, "Neutral": 30}
+	Overrides        int            `json:"overrides"`     // -- corrections changing the metadata
+	Confirmations    int            `json:"confirmations"` // -- metadata approved as is
+	OverrideRate     float64        `json:"overrideRate"`  // -- overrides / reviewed articles
+}
+
+const promptVersionColumns = `name, version, system_template, user_template, model, temperature, traffic_weight, created_at, updated_at`
+
+// CreatePromptVersionsTable creates the prompt versions table in the database
+func CreatePromptVersionsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	promptVersionTableName := config.GetEnvironmentVariable("PROMPT_VERSION_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		name TEXT NOT NULL,
+		version TEXT NOT NULL,
+		system_template TEXT NOT NULL,
+		user_template TEXT NOT NULL,
+		model TEXT NOT NULL,
+		temperature DOUBLE PRECISION NOT NULL,
+		traffic_weight INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (name, version)
+	);`, promptVersionTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating prompt versions table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", promptVersionTableName)
+	return nil
+}
+
+func scanPromptVersion(row pgx.Row, prompt *PromptVersionSchema) error {
+	return row.Scan(
+		&prompt.Name,
+		&prompt.Version,
+		&prompt.System,
+		&prompt.User,
+		&prompt.Model,
+		&prompt.Temperature,
+		&prompt.TrafficWeight,
+		&prompt.CreatedAt,
+		&prompt.UpdatedAt,
+	)
+}
+
+// InsertPromptVersion stores a new prompt version. Versions are immutable once used, so it returns nil
+// when the version already exists.
+func InsertPromptVersion(ctx context.Context, pool *pgxpool.Pool, prompt PromptVersionSchema) (*PromptVersionSchema, error) {
+	promptVersionTableName := config.GetEnvironmentVariable("PROMPT_VERSION_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (name, version, system_template, user_template, model, temperature, traffic_weight)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    ON CONFLICT (name, version) DO NOTHING
+    RETURNING %s;`, promptVersionTableName, promptVersionColumns)
+
+	var storedPrompt PromptVersionSchema
+	err := scanPromptVersion(pool.QueryRow(ctx, insertSQL,
+		prompt.Name,
+		prompt.Version,
+		prompt.System,
+		prompt.User,
+		prompt.Model,
+		prompt.Temperature,
+		prompt.TrafficWeight), &storedPrompt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error inserting prompt version: %v", err)
+	}
+
+	return &storedPrompt, nil
+}
+
+// SetPromptVersionTraffic changes the traffic weight of a prompt version. An embedded version gets stored
+// with its templates the first time, so prompt holds the full version.
+func SetPromptVersionTraffic(ctx context.Context, pool *pgxpool.Pool, prompt PromptVersionSchema) (*PromptVersionSchema, error) {
+	promptVersionTableName := config.GetEnvironmentVariable("PROMPT_VERSION_TABLE_NAME")
+
+	upsertSQL := fmt.Sprintf(`
+    INSERT INTO %[1]s (name, version, system_template, user_template, model, temperature, traffic_weight)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    ON CONFLICT (name, version) DO UPDATE
+    SET traffic_weight = EXCLUDED.traffic_weight,
+        updated_at = CURRENT_TIMESTAMP
+    RETURNING %[2]s;`, promptVersionTableName, promptVersionColumns)
+
+	var storedPrompt PromptVersionSchema
+	err := scanPromptVersion(pool.QueryRow(ctx, upsertSQL,
+		prompt.Name,
+		prompt.Version,
+		prompt.System,
+		prompt.User,
+		prompt.Model,
+		prompt.Temperature,
+		prompt.TrafficWeight), &storedPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("error updating prompt version traffic: %v", err)
+	}
+
+	return &storedPrompt, nil
+}
+
+// GetPromptVersions lists every stored prompt version
+func GetPromptVersions(ctx context.Context, pool *pgxpool.Pool) ([]PromptVersionSchema, error) {
+	promptVersionTableName := config.GetEnvironmentVariable("PROMPT_VERSION_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	ORDER BY name, version;`, promptVersionColumns, promptVersionTableName)
+
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching prompt versions: %v", err)
+	}
+	defer rows.Close()
+
+	prompts := []PromptVersionSchema{}
+	for rows.Next() {
+		var prompt PromptVersionSchema
+		if err := scanPromptVersion(rows, &prompt); err != nil {
+			return nil, fmt.Errorf("error scanning prompt version: %v", err)
+		}
+		prompts = append(prompts, prompt)
+	}
+
+	return prompts, rows.Err()
+}
+
+// GetPromptVersionStats compares the versions of a prompt on the articles enriched since the given time,
+// and on the editor corrections of their output
+func GetPromptVersionStats(ctx context.Context, pool *pgxpool.Pool, name string, since time.Time) ([]PromptVersionStats, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+	metadataCorrectionTableName := config.GetEnvironmentVariable("METADATA_CORRECTION_TABLE_NAME")
+
+	articleStatsSQL := fmt.Sprintf(`
+	SELECT prompt_versions->>$1 AS version,
+		COUNT(*),
+		COALESCE(AVG(enrichment_confidence), 0)::FLOAT8,
+		COALESCE(AVG(importance_score), 0)::FLOAT8,
+		COALESCE(AVG(cardinality(categories)), 0)::FLOAT8,
+		COALESCE(AVG(length(summary)), 0)::FLOAT8,
+		COUNT(*) FILTER (WHERE review_status = 'pending'),
+		COUNT(*) FILTER (WHERE sentiment_score = 'Positive'),
+		COUNT(*) FILTER (WHERE sentiment_score = 'Negative'),
+		COUNT(*) FILTER (WHERE sentiment_score = 'Neutral')
+	FROM %s
+	WHERE prompt_versions ? $1 AND updated_at >= $2 AND deleted_at IS NULL
+	GROUP BY 1
+	ORDER BY 1;`, articleTableName)
+
+	rows, err := pool.Query(ctx, articleStatsSQL, name, since)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching prompt version stats: %v", err)
+	}
+	defer rows.Close()
+
+	stats := []PromptVersionStats{}
+	statsByVersion := map[string]int{}
+	for rows.Next() {
+		var versionStats PromptVersionStats
+		var positive, negative, neutral int
+		err := rows.Scan(
+			&versionStats.Version,
+			&versionStats.Articles,
+			&versionStats.AvgConfidence,
+			&versionStats.AvgImportance,
+			&versionStats.AvgCategories,
+			&versionStats.AvgSummaryLength,
+			&versionStats.PendingReview,
+			&positive,
+			&negative,
+			&neutral,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning prompt version stats: %v", err)
+		}
+		versionStats.Sentiments = map[string]int{"Positive": positive, "Negative": negative, "Neutral": neutral}
+
+		statsByVersion[versionStats.Version] = len(stats)
+		stats = append(stats, versionStats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	correctionStatsSQL := fmt.Sprintf(`
+	SELECT prompt_versions->>$1 AS version,
+		COUNT(*) FILTER (WHERE kind = 'override'),
+		COUNT(*) FILTER (WHERE kind = 'confirmation')
+	FROM %s
+	WHERE prompt_versions ? $1 AND created_at >= $2
+	GROUP BY 1;`, metadataCorrectionTableName)
+
+	rows, err = pool.Query(ctx, correctionStatsSQL, name, since)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching prompt version corrections: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version string
+		var overrides, confirmations int
+		if err := rows.Scan(&version, &overrides, &confirmations); err != nil {
+			return nil, fmt.Errorf("error scanning prompt version corrections: %v", err)
+		}
+
+		index, ok := statsByVersion[version]
+		if !ok {
+			// the articles were re-enriched by another version since
+			statsByVersion[version] = len(stats)
+			index = len(stats)
+			stats = append(stats, PromptVersionStats{Version: version, Sentiments: map[string]int{}})
+		}
+
+		stats[index].Overrides = overrides
+		stats[index].Confirmations = confirmations
+		if overrides+confirmations > 0 {
+			stats[index].OverrideRate = float64(overrides) / float64(overrides+confirmations)
+		}
+	}
+
+	return stats, rows.Err()
+}
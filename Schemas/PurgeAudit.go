@@ -0,0 +1,110 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PurgeAuditSchema struct {
+	AuditId   int64     `json:"auditId"`
+	ArticleId string    `json:"articleId"`
+	Publisher string    `json:"publisher"`
+	Action    string    `json:"action"` // -- "soft_delete", "content_purge" or "hard_purge"
+	Actor     string    `json:"actor"`  // -- "admin" or "retention-job"
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"` // TIMESTAMP
+}
+
+// CreatePurgeAuditTable creates the purge audit table in the database
+func CreatePurgeAuditTable(ctx context.Context, pool *pgxpool.Pool) error {
+	purgeAuditTableName := config.GetEnvironmentVariable("PURGE_AUDIT_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		audit_id BIGSERIAL PRIMARY KEY,
+		article_id UUID NOT NULL,
+		publisher TEXT NOT NULL,
+		action TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_article_id_idx ON %[1]s (article_id);`, purgeAuditTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating purge audit table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", purgeAuditTableName)
+	return nil
+}
+
+// InsertPurgeAudit records a deletion or purge in the audit trail
+func InsertPurgeAudit(ctx context.Context, pool *pgxpool.Pool, audit PurgeAuditSchema) error {
+	return insertPurgeAudit(ctx, pool, audit)
+}
+
+// insertPurgeAudit records the audit with the pool or within the transaction of the purge it describes
+func insertPurgeAudit(ctx context.Context, db interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}, audit PurgeAuditSchema) error {
+	purgeAuditTableName := config.GetEnvironmentVariable("PURGE_AUDIT_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (article_id, publisher, action, actor, reason)
+    VALUES ($1, $2, $3, $4, $5);`, purgeAuditTableName)
+
+	_, err := db.Exec(ctx, insertSQL, audit.ArticleId, audit.Publisher, audit.Action, audit.Actor, audit.Reason)
+	if err != nil {
+		return fmt.Errorf("error inserting purge audit: %v", err)
+	}
+
+	return nil
+}
+
+// GetPurgeAudits lists the audit trail, newest first, optionally for a single article
+func GetPurgeAudits(ctx context.Context, pool *pgxpool.Pool, articleId string, limit int) ([]PurgeAuditSchema, error) {
+	purgeAuditTableName := config.GetEnvironmentVariable("PURGE_AUDIT_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT audit_id, article_id, publisher, action, actor, reason, created_at
+	FROM %s
+	WHERE $1 = '' OR article_id::TEXT = $1
+	ORDER BY audit_id DESC
+	LIMIT $2;`, purgeAuditTableName)
+
+	rows, err := pool.Query(ctx, query, articleId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching purge audits: %v", err)
+	}
+	defer rows.Close()
+
+	audits := []PurgeAuditSchema{}
+	for rows.Next() {
+		var audit PurgeAuditSchema
+		err := rows.Scan(
+			&audit.AuditId,
+			&audit.ArticleId,
+			&audit.Publisher,
+			&audit.Action,
+			&audit.Actor,
+			&audit.Reason,
+			&audit.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning purge audit: %v", err)
+		}
+		audits = append(audits, audit)
+	}
+
+	return audits, rows.Err()
+}
@@ -0,0 +1,116 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultRetentionPublisher is the publisher key of the policy applied to publishers without their own
+const DefaultRetentionPublisher = "*"
+
+type RetentionPolicySchema struct {
+	Publisher            string    `json:"publisher"`            // -- publisher name, "*" for the default policy
+	ContentRetentionDays *int      `json:"contentRetentionDays"` // -- purge full content (keeping summary and metadata) after this many days, nil to keep
+	ArticleRetentionDays *int      `json:"articleRetentionDays"` // -- hard purge the whole article after this many days, nil to keep
+	CreatedAt            time.Time `json:"createdAt"`            // TIMESTAMP
+	UpdatedAt            time.Time `json:"updatedAt"`            // TIMESTAMP
+}
+
+// CreateRetentionPoliciesTable creates the retention policies table in the database
+func CreateRetentionPoliciesTable(ctx context.Context, pool *pgxpool.Pool) error {
+	retentionPolicyTableName := config.GetEnvironmentVariable("RETENTION_POLICY_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		publisher TEXT PRIMARY KEY,
+		content_retention_days INTEGER,
+		article_retention_days INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`, retentionPolicyTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating retention policies table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", retentionPolicyTableName)
+	return nil
+}
+
+// UpsertRetentionPolicy creates or replaces the retention policy of a publisher
+func UpsertRetentionPolicy(ctx context.Context, pool *pgxpool.Pool, policy RetentionPolicySchema) (*RetentionPolicySchema, error) {
+	retentionPolicyTableName := config.GetEnvironmentVariable("RETENTION_POLICY_TABLE_NAME")
+
+	upsertSQL := fmt.Sprintf(`
+    INSERT INTO %s (publisher, content_retention_days, article_retention_days)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (publisher) DO UPDATE
+    SET content_retention_days = EXCLUDED.content_retention_days,
+        article_retention_days = EXCLUDED.article_retention_days,
+        updated_at = CURRENT_TIMESTAMP
+    RETURNING publisher, content_retention_days, article_retention_days, created_at, updated_at;`, retentionPolicyTableName)
+
+	var storedPolicy RetentionPolicySchema
+	err := pool.QueryRow(ctx, upsertSQL, policy.Publisher, policy.ContentRetentionDays, policy.ArticleRetentionDays).Scan(
+		&storedPolicy.Publisher,
+		&storedPolicy.ContentRetentionDays,
+		&storedPolicy.ArticleRetentionDays,
+		&storedPolicy.CreatedAt,
+		&storedPolicy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error saving retention policy: %v", err)
+	}
+
+	return &storedPolicy, nil
+}
+
+// DeleteRetentionPolicy removes the retention policy of a publisher
+func DeleteRetentionPolicy(ctx context.Context, pool *pgxpool.Pool, publisher string) error {
+	retentionPolicyTableName := config.GetEnvironmentVariable("RETENTION_POLICY_TABLE_NAME")
+
+	_, err := pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE publisher = $1;`, retentionPolicyTableName), publisher)
+	return err
+}
+
+// GetRetentionPolicies lists all retention policies
+func GetRetentionPolicies(ctx context.Context, pool *pgxpool.Pool) ([]RetentionPolicySchema, error) {
+	retentionPolicyTableName := config.GetEnvironmentVariable("RETENTION_POLICY_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT publisher, content_retention_days, article_retention_days, created_at, updated_at
+	FROM %s
+	ORDER BY publisher;`, retentionPolicyTableName)
+
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching retention policies: %v", err)
+	}
+	defer rows.Close()
+
+	policies := []RetentionPolicySchema{}
+	for rows.Next() {
+		var policy RetentionPolicySchema
+		err := rows.Scan(
+			&policy.Publisher,
+			&policy.ContentRetentionDays,
+			&policy.ArticleRetentionDays,
+			&policy.CreatedAt,
+			&policy.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning retention policy: %v", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
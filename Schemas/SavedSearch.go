@@ -0,0 +1,207 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SavedSearchSchema struct {
+	SearchId      int64      `json:"searchId"`
+	UserId        string     `json:"userId"`
+	Name          string     `json:"name"`
+	Query         string     `json:"query"`      // -- web search syntax, e.g., "interest rates" -crypto
+	Publishers    []string   `json:"publishers"` // -- lowercased filters, empty matches any
+	Categories    []string   `json:"categories"`
+	Sentiments    []string   `json:"sentiments"`
+	Languages     []string   `json:"languages"`
+	Channels      []string   `json:"channels"` // -- e.g., ["webhook", "email"], empty to only poll
+	WebhookUrl    string     `json:"webhookUrl"`
+	Email         string     `json:"email"`
+	LastCheckedAt *time.Time `json:"lastCheckedAt"` // TIMESTAMP -- match time of the last article returned by the new articles endpoint
+	LastCheckedId string     `json:"-"`             // -- id of that article, the two form the checkpoint
+	CreatedAt     time.Time  `json:"createdAt"`     // TIMESTAMP
+}
+
+const savedSearchColumns = `search_id, user_id, name, query, publishers, categories, sentiments, languages, channels,
+	webhook_url, email, last_checked_at, last_checked_article_id, created_at`
+
+// CreateSavedSearchesTable creates the saved searches table in the database
+func CreateSavedSearchesTable(ctx context.Context, pool *pgxpool.Pool) error {
+	savedSearchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		search_id BIGSERIAL PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		query TEXT NOT NULL,
+		publishers TEXT[] NOT NULL DEFAULT '{}',
+		categories TEXT[] NOT NULL DEFAULT '{}',
+		sentiments TEXT[] NOT NULL DEFAULT '{}',
+		languages TEXT[] NOT NULL DEFAULT '{}',
+		channels TEXT[] NOT NULL DEFAULT '{}',
+		webhook_url TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		last_checked_at TIMESTAMP,
+		last_checked_article_id TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS last_checked_article_id TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS %[1]s_user_id_idx ON %[1]s (user_id);`, savedSearchTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating saved searches table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", savedSearchTableName)
+	return nil
+}
+
+// InsertSavedSearch stores a new saved search and returns it with its id
+func InsertSavedSearch(ctx context.Context, pool *pgxpool.Pool, search SavedSearchSchema) (*SavedSearchSchema, error) {
+	savedSearchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (user_id, name, query, publishers, categories, sentiments, languages, channels, webhook_url, email, last_checked_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CURRENT_TIMESTAMP)
+    RETURNING %s;`, savedSearchTableName, savedSearchColumns)
+
+	var storedSearch SavedSearchSchema
+	err := scanSavedSearch(pool.QueryRow(ctx, insertSQL,
+		search.UserId,
+		search.Name,
+		search.Query,
+		nonNilStrings(search.Publishers),
+		nonNilStrings(search.Categories),
+		nonNilStrings(search.Sentiments),
+		nonNilStrings(search.Languages),
+		nonNilStrings(search.Channels),
+		search.WebhookUrl,
+		search.Email), &storedSearch)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting saved search: %v", err)
+	}
+
+	return &storedSearch, nil
+}
+
+// GetSavedSearchesByUser lists the saved searches of a user
+func GetSavedSearchesByUser(ctx context.Context, pool *pgxpool.Pool, userId string) ([]SavedSearchSchema, error) {
+	savedSearchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE user_id = $1
+	ORDER BY search_id;`, savedSearchColumns, savedSearchTableName)
+
+	return querySavedSearches(ctx, pool, query, userId)
+}
+
+// DeleteSavedSearch removes a saved search of a user with its matches, it returns false when the user has no such search
+func DeleteSavedSearch(ctx context.Context, pool *pgxpool.Pool, userId string, searchId int64) (bool, error) {
+	savedSearchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_TABLE_NAME")
+	savedSearchMatchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_MATCH_TABLE_NAME")
+
+	deleteSQL := fmt.Sprintf(`
+    WITH deleted AS (
+        DELETE FROM %s WHERE user_id = $1 AND search_id = $2 RETURNING search_id
+    ), deleted_matches AS (
+        DELETE FROM %s WHERE search_id IN (SELECT search_id FROM deleted)
+    )
+    SELECT count(*) FROM deleted;`, savedSearchTableName, savedSearchMatchTableName)
+
+	var deleted int
+	if err := pool.QueryRow(ctx, deleteSQL, userId, searchId).Scan(&deleted); err != nil {
+		return false, err
+	}
+
+	return deleted == 1, nil
+}
+
+// GetSavedSearchByID returns a saved search of a user, nil when the user has no such search
+func GetSavedSearchByID(ctx context.Context, pool *pgxpool.Pool, userId string, searchId int64) (*SavedSearchSchema, error) {
+	savedSearchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE user_id = $1 AND search_id = $2;`, savedSearchColumns, savedSearchTableName)
+
+	var search SavedSearchSchema
+	err := scanSavedSearch(pool.QueryRow(ctx, query, userId, searchId), &search)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching saved search: %v", err)
+	}
+
+	return &search, nil
+}
+
+// AdvanceSavedSearchCheckpoint moves the checkpoint of a user's saved search to the last match returned.
+// The checkpoint never moves back, so concurrent calls can't make matches come again.
+func AdvanceSavedSearchCheckpoint(ctx context.Context, pool *pgxpool.Pool, userId string, searchId int64, matchedAt time.Time, articleId string) error {
+	savedSearchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_TABLE_NAME")
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET last_checked_at = $3, last_checked_article_id = $4
+    WHERE user_id = $1 AND search_id = $2
+      AND (COALESCE(last_checked_at, created_at), last_checked_article_id) < ($3, $4);`, savedSearchTableName)
+
+	_, err := pool.Exec(ctx, updateSQL, userId, searchId, matchedAt, articleId)
+	if err != nil {
+		return fmt.Errorf("error updating saved search checkpoint: %v", err)
+	}
+
+	return nil
+}
+
+func querySavedSearches(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]SavedSearchSchema, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching saved searches: %v", err)
+	}
+	defer rows.Close()
+
+	searches := []SavedSearchSchema{}
+	for rows.Next() {
+		var search SavedSearchSchema
+		if err := scanSavedSearch(rows, &search); err != nil {
+			return nil, fmt.Errorf("error scanning saved search: %v", err)
+		}
+		searches = append(searches, search)
+	}
+
+	return searches, rows.Err()
+}
+
+func scanSavedSearch(row pgx.Row, search *SavedSearchSchema) error {
+	return row.Scan(
+		&search.SearchId,
+		&search.UserId,
+		&search.Name,
+		&search.Query,
+		&search.Publishers,
+		&search.Categories,
+		&search.Sentiments,
+		&search.Languages,
+		&search.Channels,
+		&search.WebhookUrl,
+		&search.Email,
+		&search.LastCheckedAt,
+		&search.LastCheckedId,
+		&search.CreatedAt,
+	)
+}
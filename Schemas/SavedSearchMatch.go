@@ -0,0 +1,122 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CreateSavedSearchMatchesTable creates the table recording which articles matched which saved searches
+func CreateSavedSearchMatchesTable(ctx context.Context, pool *pgxpool.Pool) error {
+	savedSearchMatchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_MATCH_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		search_id BIGINT NOT NULL,
+		article_id UUID NOT NULL,
+		matched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (search_id, article_id)
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_matched_at_idx ON %[1]s (search_id, matched_at);`, savedSearchMatchTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating saved search matches table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", savedSearchMatchTableName)
+	return nil
+}
+
+// PercolateArticle evaluates the article against every saved search, the query and the filters, records
+// the new matches and returns the saved searches that matched. An article matches a search only once.
+func PercolateArticle(ctx context.Context, pool *pgxpool.Pool, articleId string) ([]SavedSearchSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+	savedSearchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_TABLE_NAME")
+	savedSearchMatchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_MATCH_TABLE_NAME")
+
+	percolateSQL := fmt.Sprintf(`
+    WITH matched AS (
+        INSERT INTO %[3]s (search_id, article_id)
+        SELECT saved.search_id, article.article_id
+        FROM %[2]s AS saved
+        JOIN %[1]s AS article ON article.article_id = $1
+        WHERE article.status = 'published' AND article.deleted_at IS NULL
+          AND article.search_vector @@ websearch_to_tsquery('english', saved.query)
+          AND (cardinality(saved.publishers) = 0 OR lower(article.publisher) = ANY(saved.publishers))
+          AND (cardinality(saved.categories) = 0 OR EXISTS (
+                SELECT 1 FROM unnest(article.categories) AS category WHERE lower(category) = ANY(saved.categories)))
+          AND (cardinality(saved.sentiments) = 0 OR lower(article.sentiment_score) = ANY(saved.sentiments))
+          AND (cardinality(saved.languages) = 0 OR article.language = ANY(saved.languages))
+        ON CONFLICT (search_id, article_id) DO NOTHING
+        RETURNING search_id
+    )
+    SELECT %[4]s
+    FROM %[2]s
+    WHERE search_id IN (SELECT search_id FROM matched);`, articleTableName, savedSearchTableName, savedSearchMatchTableName, savedSearchColumns)
+
+	return querySavedSearches(ctx, pool, percolateSQL, articleId)
+}
+
+// SavedSearchMatch is an article that matched a saved search
+type SavedSearchMatch struct {
+	ArticleSchema
+	MatchedAt time.Time `json:"matchedAt"` // TIMESTAMP
+}
+
+// GetSavedSearchMatches returns the live articles that matched a saved search after the (afterMatchedAt, afterArticleId)
+// cursor, in match order and without their full content. Matches of the last settleSeconds are left for the next call,
+// the transactions recording them may not be committed yet.
+func GetSavedSearchMatches(ctx context.Context, pool *pgxpool.Pool, searchId int64, afterMatchedAt time.Time, afterArticleId string, settleSeconds int, limit int) ([]SavedSearchMatch, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+	savedSearchMatchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_MATCH_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s, m.matched_at
+	FROM (
+		SELECT article_id, matched_at
+		FROM %s
+		WHERE search_id = $1
+		  AND (matched_at, article_id::TEXT) > ($2, $3)
+		  AND matched_at <= CURRENT_TIMESTAMP - make_interval(secs => $4::INTEGER)
+	) AS m
+	JOIN %s USING (article_id)
+	WHERE deleted_at IS NULL
+	ORDER BY m.matched_at, article_id::TEXT
+	LIMIT $5;`, articleListColumns, savedSearchMatchTableName, articleTableName)
+
+	rows, err := pool.Query(ctx, query, searchId, afterMatchedAt, afterArticleId, settleSeconds, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching saved search matches: %v", err)
+	}
+	defer rows.Close()
+
+	matches := []SavedSearchMatch{}
+	for rows.Next() {
+		var match SavedSearchMatch
+		if err := scanArticle(rows, &match.ArticleSchema, &match.MatchedAt); err != nil {
+			return nil, fmt.Errorf("error scanning article: %v", err)
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// deleteSavedSearchMatchesByArticle removes the matches of a purged article, within the purge transaction
+func deleteSavedSearchMatchesByArticle(ctx context.Context, tx pgx.Tx, articleId string) error {
+	savedSearchMatchTableName := config.GetEnvironmentVariable("SAVED_SEARCH_MATCH_TABLE_NAME")
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE article_id = $1;`, savedSearchMatchTableName), articleId)
+	if err != nil {
+		return fmt.Errorf("error deleting saved search matches: %v", err)
+	}
+	return nil
+}
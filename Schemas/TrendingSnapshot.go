@@ -0,0 +1,215 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TrendingSnapshotSchema struct {
+	ComputedAt     time.Time `json:"computedAt"` // TIMESTAMP -- when the snapshot was taken
+	Window         string    `json:"window"`     // -- "1h", "6h" or "24h"
+	TermType       string    `json:"termType"`   // -- "entity", "category" or "tag"
+	Term           string    `json:"term"`
+	Mentions       int       `json:"mentions"`       // -- mentions inside the current window
+	BaselineMean   float64   `json:"baselineMean"`   // -- average mentions per window over the baseline period
+	BaselineStdDev float64   `json:"baselineStdDev"` // -- standard deviation of the baseline windows
+	Velocity       float64   `json:"velocity"`       // -- mentions per hour inside the current window
+	ZScore         float64   `json:"zScore"`
+	IsBurst        bool      `json:"isBurst"`
+}
+
+// TermBucketCount is the number of mentions of a term inside one window-sized bucket,
+// bucket 0 being the current window and higher buckets going back in time
+type TermBucketCount struct {
+	Term   string
+	Bucket int
+	Count  int
+}
+
+// lateral subqueries listing the terms of an article for every trending term type
+var trendingTermSQL = map[string]string{
+	"category": `SELECT unnest(categories)`,
+	"tag":      `SELECT unnest(tags)`,
+	"entity": `SELECT jsonb_array_elements_text(CASE WHEN jsonb_typeof(entity_group.value) = 'array' THEN entity_group.value ELSE '[]'::JSONB END)
+		FROM jsonb_each(CASE WHEN jsonb_typeof(entities) = 'object' THEN entities ELSE '{}'::JSONB END) AS entity_group`,
+}
+
+// CreateTrendingSnapshotsTable creates the trending snapshots table in the database
+func CreateTrendingSnapshotsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	trendingSnapshotTableName := config.GetEnvironmentVariable("TRENDING_SNAPSHOT_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		snapshot_id BIGSERIAL PRIMARY KEY,
+		computed_at TIMESTAMP NOT NULL,
+		time_window TEXT NOT NULL,
+		term_type TEXT NOT NULL,
+		term TEXT NOT NULL,
+		mentions INTEGER NOT NULL,
+		baseline_mean DOUBLE PRECISION NOT NULL,
+		baseline_stddev DOUBLE PRECISION NOT NULL,
+		velocity DOUBLE PRECISION NOT NULL,
+		z_score DOUBLE PRECISION NOT NULL,
+		is_burst BOOLEAN NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_lookup_idx ON %[1]s (time_window, term_type, computed_at DESC);
+	CREATE INDEX IF NOT EXISTS %[1]s_term_idx ON %[1]s (term_type, term, computed_at DESC);`, trendingSnapshotTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating trending snapshots table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", trendingSnapshotTableName)
+	return nil
+}
+
+// GetTermBucketCounts counts the mentions of every term of the given type per window-sized bucket,
+// going back from now until the baseline start
+func GetTermBucketCounts(ctx context.Context, pool *pgxpool.Pool, termType string, now time.Time, window time.Duration, baselineStart time.Time) ([]TermBucketCount, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+
+	termSQL, ok := trendingTermSQL[termType]
+	if !ok {
+		return nil, fmt.Errorf("unknown term type: %s", termType)
+	}
+
+	query := fmt.Sprintf(`
+	WITH mentions AS (
+		SELECT DISTINCT article.article_id, article_term.term, article.publication_date
+		FROM %[2]s AS article, LATERAL (%[1]s) AS article_term(term)
+		WHERE publication_date >= $1 AND publication_date <= $2 AND status = 'published' AND deleted_at IS NULL
+	)
+	SELECT term, FLOOR(EXTRACT(EPOCH FROM ($2 - publication_date)) / $3)::INTEGER AS bucket, COUNT(*)
+	FROM mentions
+	WHERE term IS NOT NULL AND term <> ''
+	GROUP BY term, bucket;`, termSQL, articleTableName)
+
+	rows, err := pool.Query(ctx, query, baselineStart, now, window.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error counting mentions: %v", err)
+	}
+	defer rows.Close()
+
+	counts := []TermBucketCount{}
+	for rows.Next() {
+		var count TermBucketCount
+		if err := rows.Scan(&count.Term, &count.Bucket, &count.Count); err != nil {
+			return nil, fmt.Errorf("error scanning mentions: %v", err)
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// InsertTrendingSnapshots stores a computed trending list in a single batch round-trip
+func InsertTrendingSnapshots(ctx context.Context, pool *pgxpool.Pool, snapshots []TrendingSnapshotSchema) error {
+	trendingSnapshotTableName := config.GetEnvironmentVariable("TRENDING_SNAPSHOT_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (computed_at, time_window, term_type, term, mentions, baseline_mean, baseline_stddev, velocity, z_score, is_burst)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`, trendingSnapshotTableName)
+
+	batch := &pgx.Batch{}
+	for _, snapshot := range snapshots {
+		batch.Queue(insertSQL,
+			snapshot.ComputedAt,
+			snapshot.Window,
+			snapshot.TermType,
+			snapshot.Term,
+			snapshot.Mentions,
+			snapshot.BaselineMean,
+			snapshot.BaselineStdDev,
+			snapshot.Velocity,
+			snapshot.ZScore,
+			snapshot.IsBurst)
+	}
+
+	results := pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for range snapshots {
+		if _, err := results.Exec(); err != nil {
+			return fmt.Errorf("error inserting trending snapshot: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// GetLatestTrendingSnapshot returns the most recent stored trending list for the window and term type,
+// ordered by z-score, empty when the job has not produced one yet
+func GetLatestTrendingSnapshot(ctx context.Context, pool *pgxpool.Pool, window string, termType string, limit int) ([]TrendingSnapshotSchema, error) {
+	trendingSnapshotTableName := config.GetEnvironmentVariable("TRENDING_SNAPSHOT_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT computed_at, time_window, term_type, term, mentions, baseline_mean, baseline_stddev, velocity, z_score, is_burst
+	FROM %[1]s
+	WHERE time_window = $1 AND term_type = $2
+	  AND computed_at = (SELECT MAX(computed_at) FROM %[1]s WHERE time_window = $1 AND term_type = $2)
+	ORDER BY z_score DESC
+	LIMIT $3;`, trendingSnapshotTableName)
+
+	return queryTrendingSnapshots(ctx, pool, query, window, termType, limit)
+}
+
+// GetTrendingHistory returns the stored snapshots of a term since the given time, oldest first
+func GetTrendingHistory(ctx context.Context, pool *pgxpool.Pool, window string, termType string, term string, since time.Time) ([]TrendingSnapshotSchema, error) {
+	trendingSnapshotTableName := config.GetEnvironmentVariable("TRENDING_SNAPSHOT_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT computed_at, time_window, term_type, term, mentions, baseline_mean, baseline_stddev, velocity, z_score, is_burst
+	FROM %s
+	WHERE time_window = $1 AND term_type = $2 AND term = $3 AND computed_at >= $4
+	ORDER BY computed_at;`, trendingSnapshotTableName)
+
+	return queryTrendingSnapshots(ctx, pool, query, window, termType, term, since)
+}
+
+// DeleteTrendingSnapshotsBefore removes snapshots older than the given time
+func DeleteTrendingSnapshotsBefore(ctx context.Context, pool *pgxpool.Pool, before time.Time) error {
+	trendingSnapshotTableName := config.GetEnvironmentVariable("TRENDING_SNAPSHOT_TABLE_NAME")
+
+	_, err := pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE computed_at < $1;`, trendingSnapshotTableName), before)
+	return err
+}
+
+func queryTrendingSnapshots(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]TrendingSnapshotSchema, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching trending snapshots: %v", err)
+	}
+	defer rows.Close()
+
+	snapshots := []TrendingSnapshotSchema{}
+	for rows.Next() {
+		var snapshot TrendingSnapshotSchema
+		err := rows.Scan(
+			&snapshot.ComputedAt,
+			&snapshot.Window,
+			&snapshot.TermType,
+			&snapshot.Term,
+			&snapshot.Mentions,
+			&snapshot.BaselineMean,
+			&snapshot.BaselineStdDev,
+			&snapshot.Velocity,
+			&snapshot.ZScore,
+			&snapshot.IsBurst,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning trending snapshot: %v", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
@@ -0,0 +1,228 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserArticleStateSchema struct {
+	UserId       string     `json:"userId"`
+	ArticleId    string     `json:"articleId"`
+	Bookmarked   bool       `json:"bookmarked"` // -- in the read-later list
+	BookmarkedAt *time.Time `json:"bookmarkedAt"`
+	Read         bool       `json:"read"`
+	ReadAt       *time.Time `json:"readAt"`
+	Progress     int        `json:"progress"`  // -- 0 to 100, how far the user scrolled
+	UpdatedAt    time.Time  `json:"updatedAt"` // TIMESTAMP -- cursor for incremental sync
+}
+
+// UserArticleStateChange is a partial update, nil fields are left as they are
+type UserArticleStateChange struct {
+	Bookmarked *bool
+	Read       *bool
+	Progress   *int
+}
+
+const userArticleStateColumns = `user_id, article_id::TEXT, bookmarked, bookmarked_at, read, read_at, progress, updated_at`
+
+// CreateUserArticleStatesTable creates the per user article state (bookmarks, read status, progress) table in the database
+func CreateUserArticleStatesTable(ctx context.Context, pool *pgxpool.Pool) error {
+	userArticleStateTableName := config.GetEnvironmentVariable("USER_ARTICLE_STATE_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		user_id TEXT NOT NULL,
+		article_id UUID NOT NULL,
+		bookmarked BOOLEAN NOT NULL DEFAULT FALSE,
+		bookmarked_at TIMESTAMP,
+		read BOOLEAN NOT NULL DEFAULT FALSE,
+		read_at TIMESTAMP,
+		progress INTEGER NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, article_id)
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_updated_at_idx ON %[1]s (user_id, updated_at);`, userArticleStateTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating user article states table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", userArticleStateTableName)
+	return nil
+}
+
+// UpsertUserArticleState applies a partial change to the state of an article for a user and returns the new state.
+// Reaching 100% progress marks the article as read.
+func UpsertUserArticleState(ctx context.Context, pool *pgxpool.Pool, userId string, articleId string, change UserArticleStateChange) (*UserArticleStateSchema, error) {
+	userArticleStateTableName := config.GetEnvironmentVariable("USER_ARTICLE_STATE_TABLE_NAME")
+
+	read := change.Read
+	if read == nil && change.Progress != nil && *change.Progress >= 100 {
+		markRead := true
+		read = &markRead
+	}
+
+	upsertSQL := fmt.Sprintf(`
+    INSERT INTO %[1]s AS state (user_id, article_id, bookmarked, bookmarked_at, read, read_at, progress)
+    VALUES ($1, $2, COALESCE($3, FALSE), CASE WHEN $3 THEN CURRENT_TIMESTAMP END,
+            COALESCE($4, FALSE), CASE WHEN $4 THEN CURRENT_TIMESTAMP END, COALESCE($5, 0))
+    ON CONFLICT (user_id, article_id) DO UPDATE
+    SET bookmarked = COALESCE($3, state.bookmarked),
+        bookmarked_at = CASE WHEN $3 IS NULL THEN state.bookmarked_at
+                             WHEN $3 AND NOT state.bookmarked THEN CURRENT_TIMESTAMP
+                             WHEN $3 THEN state.bookmarked_at END,
+        read = COALESCE($4, state.read),
+        read_at = CASE WHEN $4 IS NULL THEN state.read_at
+                       WHEN $4 AND NOT state.read THEN CURRENT_TIMESTAMP
+                       WHEN $4 THEN state.read_at END,
+        progress = COALESCE($5, state.progress),
+        updated_at = CURRENT_TIMESTAMP
+    RETURNING %[2]s;`, userArticleStateTableName, userArticleStateColumns)
+
+	var state UserArticleStateSchema
+	err := scanUserArticleState(pool.QueryRow(ctx, upsertSQL, userId, articleId, change.Bookmarked, read, change.Progress), &state)
+	if err != nil {
+		return nil, fmt.Errorf("error updating article state: %v", err)
+	}
+
+	return &state, nil
+}
+
+// SyncCursor is a position in the library changes, ordered by (updated_at, key). The key tells apart the rows
+// changed at the same time, it is compared bytewise (COLLATE "C") so the database and Go agree on the order.
+type SyncCursor struct {
+	UpdatedAt time.Time
+	Key       string
+}
+
+// Before reports whether the cursor comes before other
+func (cursor SyncCursor) Before(other SyncCursor) bool {
+	if !cursor.UpdatedAt.Equal(other.UpdatedAt) {
+		return cursor.UpdatedAt.Before(other.UpdatedAt)
+	}
+	return cursor.Key < other.Key
+}
+
+// syncCursorArgs returns the query arguments of an optional cursor, a NULL timestamp when there is none
+func syncCursorArgs(cursor *SyncCursor) (*time.Time, string) {
+	if cursor == nil {
+		return nil, ""
+	}
+	return &cursor.UpdatedAt, cursor.Key
+}
+
+// SyncCursor is the position of the state in the library changes
+func (state UserArticleStateSchema) SyncCursor() SyncCursor {
+	return SyncCursor{UpdatedAt: state.UpdatedAt, Key: state.ArticleId}
+}
+
+// UserArticleStateFilter selects the article states of a user, nil fields don't filter
+type UserArticleStateFilter struct {
+	After      *SyncCursor // -- changes after the cursor only
+	Bookmarked *bool
+	Read       *bool
+	Limit      int
+	Offset     int
+}
+
+// GetUserArticleStates lists the article states of a user, oldest change first so clients can sync incrementally
+func GetUserArticleStates(ctx context.Context, pool *pgxpool.Pool, userId string, filter UserArticleStateFilter) ([]UserArticleStateSchema, error) {
+	userArticleStateTableName := config.GetEnvironmentVariable("USER_ARTICLE_STATE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE user_id = $1
+	  AND ($2::TIMESTAMP IS NULL OR (updated_at, article_id::TEXT COLLATE "C") > ($2, $3::TEXT COLLATE "C"))
+	  AND ($4::BOOLEAN IS NULL OR bookmarked = $4)
+	  AND ($5::BOOLEAN IS NULL OR read = $5)
+	ORDER BY updated_at, article_id::TEXT COLLATE "C"
+	LIMIT $6 OFFSET $7;`, userArticleStateColumns, userArticleStateTableName)
+
+	afterUpdatedAt, afterKey := syncCursorArgs(filter.After)
+	rows, err := pool.Query(ctx, query, userId, afterUpdatedAt, afterKey, filter.Bookmarked, filter.Read, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching article states: %v", err)
+	}
+	defer rows.Close()
+
+	states := []UserArticleStateSchema{}
+	for rows.Next() {
+		var state UserArticleStateSchema
+		if err := scanUserArticleState(rows, &state); err != nil {
+			return nil, fmt.Errorf("error scanning article state: %v", err)
+		}
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+// GetBookmarkedArticles returns the live articles in the read-later list of a user, most recently bookmarked first,
+// without their full content
+func GetBookmarkedArticles(ctx context.Context, pool *pgxpool.Pool, userId string, unreadOnly bool, limit int, offset int) ([]ArticleSchema, error) {
+	articleTableName := config.GetEnvironmentVariable("ARTICLE_TABLE_NAME")
+	userArticleStateTableName := config.GetEnvironmentVariable("USER_ARTICLE_STATE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s AS article
+	JOIN (
+		SELECT article_id AS bookmark_article_id, bookmarked_at
+		FROM %s
+		WHERE user_id = $1 AND bookmarked AND (NOT $2 OR NOT read)
+	) AS bookmark ON bookmark.bookmark_article_id = article.article_id
+	WHERE article.deleted_at IS NULL
+	ORDER BY bookmark.bookmarked_at DESC
+	LIMIT $3 OFFSET $4;`, articleListColumns, articleTableName, userArticleStateTableName)
+
+	rows, err := pool.Query(ctx, query, userId, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching bookmarked articles: %v", err)
+	}
+	defer rows.Close()
+
+	articles := []ArticleSchema{}
+	for rows.Next() {
+		var article ArticleSchema
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, fmt.Errorf("error scanning article: %v", err)
+		}
+		articles = append(articles, article)
+	}
+
+	return articles, rows.Err()
+}
+
+// deleteUserArticleStatesByArticle removes every user's state of a purged article, within the purge transaction
+func deleteUserArticleStatesByArticle(ctx context.Context, tx pgx.Tx, articleId string) error {
+	userArticleStateTableName := config.GetEnvironmentVariable("USER_ARTICLE_STATE_TABLE_NAME")
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE article_id = $1;`, userArticleStateTableName), articleId)
+	if err != nil {
+		return fmt.Errorf("error deleting user article states: %v", err)
+	}
+	return nil
+}
+
+func scanUserArticleState(row pgx.Row, state *UserArticleStateSchema) error {
+	return row.Scan(
+		&state.UserId,
+		&state.ArticleId,
+		&state.Bookmarked,
+		&state.BookmarkedAt,
+		&state.Read,
+		&state.ReadAt,
+		&state.Progress,
+		&state.UpdatedAt,
+	)
+}
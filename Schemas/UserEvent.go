@@ -0,0 +1,141 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserEventSchema struct {
+	EventId      int64     `json:"eventId"`
+	UserId       string    `json:"userId"`
+	ArticleId    string    `json:"articleId"`
+	EventType    string    `json:"eventType"`    // -- "view", "click", "dwell" or "share"
+	DwellSeconds int       `json:"dwellSeconds"` // -- only set for "dwell" events
+	CreatedAt    time.Time `json:"createdAt"`    // TIMESTAMP
+}
+
+// weight of each event type in interest and popularity scores, dwell counts one per 30 seconds up to 5 minutes
+const userEventWeightSQL = `CASE event_type
+		WHEN 'view' THEN 1.0
+		WHEN 'click' THEN 2.0
+		WHEN 'share' THEN 5.0
+		WHEN 'dwell' THEN LEAST(dwell_seconds, 300) / 30.0
+		ELSE 0 END`
+
+// CreateUserEventsTable creates the user events table in the database
+func CreateUserEventsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	userEventTableName := config.GetEnvironmentVariable("USER_EVENT_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		event_id BIGSERIAL PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		article_id UUID NOT NULL,
+		event_type TEXT NOT NULL,
+		dwell_seconds INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_user_id_idx ON %[1]s (user_id, created_at DESC);
+	CREATE INDEX IF NOT EXISTS %[1]s_article_id_idx ON %[1]s (article_id, created_at DESC);`, userEventTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating user events table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", userEventTableName)
+	return nil
+}
+
+// InsertUserEvents stores the interaction events in a single batch round-trip
+func InsertUserEvents(ctx context.Context, pool *pgxpool.Pool, events []UserEventSchema) error {
+	userEventTableName := config.GetEnvironmentVariable("USER_EVENT_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (user_id, article_id, event_type, dwell_seconds)
+    VALUES ($1, $2, $3, $4);`, userEventTableName)
+
+	batch := &pgx.Batch{}
+	for _, event := range events {
+		batch.Queue(insertSQL, event.UserId, event.ArticleId, event.EventType, event.DwellSeconds)
+	}
+
+	results := pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for range events {
+		if _, err := results.Exec(); err != nil {
+			return fmt.Errorf("error inserting user event: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// GetUserArticleInterest returns the articles the user interacted with since the given time,
+// weighted by how strong the interactions were, strongest first
+func GetUserArticleInterest(ctx context.Context, pool *pgxpool.Pool, userId string, since time.Time, limit int) (map[string]float64, error) {
+	userEventTableName := config.GetEnvironmentVariable("USER_EVENT_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT article_id::TEXT, SUM(%s) AS weight
+	FROM %s
+	WHERE user_id = $1 AND created_at >= $2
+	GROUP BY article_id
+	ORDER BY weight DESC
+	LIMIT $3;`, userEventWeightSQL, userEventTableName)
+
+	return queryArticleWeights(ctx, pool, query, userId, since, limit)
+}
+
+// GetArticlePopularity returns the weighted interaction count of the given articles since the given time
+func GetArticlePopularity(ctx context.Context, pool *pgxpool.Pool, articleIds []string, since time.Time) (map[string]float64, error) {
+	userEventTableName := config.GetEnvironmentVariable("USER_EVENT_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT article_id::TEXT, SUM(%s) AS weight
+	FROM %s
+	WHERE article_id::TEXT = ANY($1) AND created_at >= $2
+	GROUP BY article_id;`, userEventWeightSQL, userEventTableName)
+
+	return queryArticleWeights(ctx, pool, query, articleIds, since)
+}
+
+func queryArticleWeights(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) (map[string]float64, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching user events: %v", err)
+	}
+	defer rows.Close()
+
+	weights := map[string]float64{}
+	for rows.Next() {
+		var articleId string
+		var weight float64
+		if err := rows.Scan(&articleId, &weight); err != nil {
+			return nil, fmt.Errorf("error scanning user events: %v", err)
+		}
+		weights[articleId] = weight
+	}
+
+	return weights, rows.Err()
+}
+
+// deleteUserEventsByArticle removes the interactions with a purged article, within the purge transaction
+func deleteUserEventsByArticle(ctx context.Context, tx pgx.Tx, articleId string) error {
+	userEventTableName := config.GetEnvironmentVariable("USER_EVENT_TABLE_NAME")
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE article_id = $1;`, userEventTableName), articleId)
+	if err != nil {
+		return fmt.Errorf("error deleting user events: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserPreferenceSchema struct {
+	UserId             string    `json:"userId"`
+	FollowedCategories []string  `json:"followedCategories"` // -- e.g., ["National Security", "Economy"]
+	FollowedEntities   []string  `json:"followedEntities"`   // -- organizations, locations or individuals
+	FollowedPublishers []string  `json:"followedPublishers"`
+	MutedTopics        []string  `json:"mutedTopics"` // -- categories or tags never shown in the feed
+	UpdatedAt          time.Time `json:"updatedAt"`   // TIMESTAMP
+}
+
+// CreateUserPreferencesTable creates the user preferences table in the database
+func CreateUserPreferencesTable(ctx context.Context, pool *pgxpool.Pool) error {
+	userPreferenceTableName := config.GetEnvironmentVariable("USER_PREFERENCE_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		user_id TEXT PRIMARY KEY,
+		followed_categories TEXT[] NOT NULL DEFAULT '{}',
+		followed_entities TEXT[] NOT NULL DEFAULT '{}',
+		followed_publishers TEXT[] NOT NULL DEFAULT '{}',
+		muted_topics TEXT[] NOT NULL DEFAULT '{}',
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`, userPreferenceTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating user preferences table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", userPreferenceTableName)
+	return nil
+}
+
+// GetUserPreference retrieves the preferences of a user, empty preferences when none were saved
+func GetUserPreference(ctx context.Context, pool *pgxpool.Pool, userId string) (*UserPreferenceSchema, error) {
+	userPreferenceTableName := config.GetEnvironmentVariable("USER_PREFERENCE_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT user_id, followed_categories, followed_entities, followed_publishers, muted_topics, updated_at
+	FROM %s
+	WHERE user_id = $1;`, userPreferenceTableName)
+
+	preference := UserPreferenceSchema{
+		UserId:             userId,
+		FollowedCategories: []string{},
+		FollowedEntities:   []string{},
+		FollowedPublishers: []string{},
+		MutedTopics:        []string{},
+	}
+
+	err := pool.QueryRow(ctx, query, userId).Scan(
+		&preference.UserId,
+		&preference.FollowedCategories,
+		&preference.FollowedEntities,
+		&preference.FollowedPublishers,
+		&preference.MutedTopics,
+		&preference.UpdatedAt,
+	)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("error fetching user preference: %v", err)
+	}
+
+	return &preference, nil
+}
+
+// UpsertUserPreference saves the preferences of a user
+func UpsertUserPreference(ctx context.Context, pool *pgxpool.Pool, preference UserPreferenceSchema) error {
+	userPreferenceTableName := config.GetEnvironmentVariable("USER_PREFERENCE_TABLE_NAME")
+
+	upsertSQL := fmt.Sprintf(`
+    INSERT INTO %s (user_id, followed_categories, followed_entities, followed_publishers, muted_topics)
+    VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT (user_id) DO UPDATE
+    SET followed_categories = EXCLUDED.followed_categories,
+        followed_entities = EXCLUDED.followed_entities,
+        followed_publishers = EXCLUDED.followed_publishers,
+        muted_topics = EXCLUDED.muted_topics,
+        updated_at = CURRENT_TIMESTAMP;`, userPreferenceTableName)
+
+	_, err := pool.Exec(ctx, upsertSQL,
+		preference.UserId,
+		nonNilStrings(preference.FollowedCategories),
+		nonNilStrings(preference.FollowedEntities),
+		nonNilStrings(preference.FollowedPublishers),
+		nonNilStrings(preference.MutedTopics))
+	if err != nil {
+		return fmt.Errorf("error saving user preference: %v", err)
+	}
+
+	return nil
+}
+
+// nonNilStrings avoids writing NULL into NOT NULL array columns
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,236 @@
+package schemas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WebhookEventTypes lists the article events partners can subscribe to
+var WebhookEventTypes = []string{"article.published", "article.updated", "article.deleted"}
+
+type WebhookSchema struct {
+	WebhookId           int64      `json:"webhookId"`
+	OwnerId             string     `json:"ownerId"`
+	Url                 string     `json:"url"`
+	Secret              string     `json:"secret,omitempty"` // -- only returned when the webhook is created
+	EventTypes          []string   `json:"eventTypes"`       // -- e.g., ["article.published", "article.updated"]
+	Filter              string     `json:"filter"`           // -- e.g., category == "Economy" and importance >= 60
+	Enabled             bool       `json:"enabled"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	DisabledAt          *time.Time `json:"disabledAt"` // TIMESTAMP
+	DisabledReason      string     `json:"disabledReason"`
+	CreatedAt           time.Time  `json:"createdAt"` // TIMESTAMP
+}
+
+const webhookColumns = `webhook_id, owner_id, url, secret, event_types, filter, enabled, consecutive_failures,
+	disabled_at, disabled_reason, created_at`
+
+// CreateWebhooksTable creates the webhook subscriptions table in the database
+func CreateWebhooksTable(ctx context.Context, pool *pgxpool.Pool) error {
+	webhookTableName := config.GetEnvironmentVariable("WEBHOOK_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		webhook_id BIGSERIAL PRIMARY KEY,
+		owner_id TEXT NOT NULL,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		event_types TEXT[] NOT NULL DEFAULT '{}',
+		filter TEXT NOT NULL DEFAULT '',
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		consecutive_failures INTEGER NOT NULL DEFAULT 0,
+		disabled_at TIMESTAMP,
+		disabled_reason TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_owner_id_idx ON %[1]s (owner_id);`, webhookTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating webhooks table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", webhookTableName)
+	return nil
+}
+
+// InsertWebhook stores a new webhook subscription and returns it with its id
+func InsertWebhook(ctx context.Context, pool *pgxpool.Pool, webhook WebhookSchema) (*WebhookSchema, error) {
+	webhookTableName := config.GetEnvironmentVariable("WEBHOOK_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (owner_id, url, secret, event_types, filter)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING %s;`, webhookTableName, webhookColumns)
+
+	var storedWebhook WebhookSchema
+	err := scanWebhook(pool.QueryRow(ctx, insertSQL,
+		webhook.OwnerId,
+		webhook.Url,
+		webhook.Secret,
+		nonNilStrings(webhook.EventTypes),
+		webhook.Filter), &storedWebhook)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting webhook: %v", err)
+	}
+
+	return &storedWebhook, nil
+}
+
+// GetWebhooksByOwner lists the webhooks of an owner
+func GetWebhooksByOwner(ctx context.Context, pool *pgxpool.Pool, ownerId string) ([]WebhookSchema, error) {
+	webhookTableName := config.GetEnvironmentVariable("WEBHOOK_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE owner_id = $1
+	ORDER BY webhook_id;`, webhookColumns, webhookTableName)
+
+	return queryWebhooks(ctx, pool, query, ownerId)
+}
+
+// GetWebhookByID fetches a webhook, it returns nil when it doesn't exist
+func GetWebhookByID(ctx context.Context, pool *pgxpool.Pool, webhookId int64) (*WebhookSchema, error) {
+	webhookTableName := config.GetEnvironmentVariable("WEBHOOK_TABLE_NAME")
+
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE webhook_id = $1;`, webhookColumns, webhookTableName)
+
+	var webhook WebhookSchema
+	err := scanWebhook(pool.QueryRow(ctx, query, webhookId), &webhook)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook: %v", err)
+	}
+
+	return &webhook, nil
+}
+
+// GetEnabledWebhooksForEvent returns the enabled webhooks subscribed to an event type
+func GetEnabledWebhooksForEvent(ctx context.Context, pool *pgxpool.Pool, eventType string) ([]WebhookSchema, error) {
+	webhookTableName := config.GetEnvironmentVariable("WEBHOOK_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE enabled AND $1 = ANY(event_types);`, webhookColumns, webhookTableName)
+
+	return queryWebhooks(ctx, pool, query, eventType)
+}
+
+// SetWebhookEnabled enables or disables a webhook of an owner, enabling it resets its failure count.
+// It returns nil when the owner has no such webhook.
+func SetWebhookEnabled(ctx context.Context, pool *pgxpool.Pool, ownerId string, webhookId int64, enabled bool) (*WebhookSchema, error) {
+	webhookTableName := config.GetEnvironmentVariable("WEBHOOK_TABLE_NAME")
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET enabled = $3,
+        consecutive_failures = CASE WHEN $3 THEN 0 ELSE consecutive_failures END,
+        disabled_at = CASE WHEN $3 THEN NULL ELSE CURRENT_TIMESTAMP END,
+        disabled_reason = CASE WHEN $3 THEN '' ELSE 'disabled by owner' END
+    WHERE owner_id = $1 AND webhook_id = $2
+    RETURNING %s;`, webhookTableName, webhookColumns)
+
+	var webhook WebhookSchema
+	err := scanWebhook(pool.QueryRow(ctx, updateSQL, ownerId, webhookId, enabled), &webhook)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error updating webhook: %v", err)
+	}
+
+	return &webhook, nil
+}
+
+// DeleteWebhook removes a webhook of an owner, it returns false when the owner has no such webhook
+func DeleteWebhook(ctx context.Context, pool *pgxpool.Pool, ownerId string, webhookId int64) (bool, error) {
+	webhookTableName := config.GetEnvironmentVariable("WEBHOOK_TABLE_NAME")
+
+	commandTag, err := pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE owner_id = $1 AND webhook_id = $2;`, webhookTableName), ownerId, webhookId)
+	if err != nil {
+		return false, err
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+// RecordWebhookSuccess resets the consecutive failure count of a webhook
+func RecordWebhookSuccess(ctx context.Context, pool *pgxpool.Pool, webhookId int64) error {
+	webhookTableName := config.GetEnvironmentVariable("WEBHOOK_TABLE_NAME")
+
+	_, err := pool.Exec(ctx, fmt.Sprintf(`UPDATE %s SET consecutive_failures = 0 WHERE webhook_id = $1;`, webhookTableName), webhookId)
+	return err
+}
+
+// RecordWebhookFailure counts a failed delivery attempt and disables the webhook once
+// maxFailures consecutive attempts have failed. It returns true when the webhook got disabled.
+func RecordWebhookFailure(ctx context.Context, pool *pgxpool.Pool, webhookId int64, maxFailures int) (bool, error) {
+	webhookTableName := config.GetEnvironmentVariable("WEBHOOK_TABLE_NAME")
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET consecutive_failures = consecutive_failures + 1,
+        enabled = enabled AND consecutive_failures + 1 < $2,
+        disabled_at = CASE WHEN enabled AND consecutive_failures + 1 >= $2 THEN CURRENT_TIMESTAMP ELSE disabled_at END,
+        disabled_reason = CASE WHEN enabled AND consecutive_failures + 1 >= $2 THEN 'too many consecutive delivery failures' ELSE disabled_reason END
+    WHERE webhook_id = $1
+    RETURNING enabled;`, webhookTableName)
+
+	var enabled bool
+	err := pool.QueryRow(ctx, updateSQL, webhookId, maxFailures).Scan(&enabled)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error recording webhook failure: %v", err)
+	}
+
+	return !enabled, nil
+}
+
+func queryWebhooks(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]WebhookSchema, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhooks: %v", err)
+	}
+	defer rows.Close()
+
+	webhooks := []WebhookSchema{}
+	for rows.Next() {
+		var webhook WebhookSchema
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, fmt.Errorf("error scanning webhook: %v", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func scanWebhook(row pgx.Row, webhook *WebhookSchema) error {
+	return row.Scan(
+		&webhook.WebhookId,
+		&webhook.OwnerId,
+		&webhook.Url,
+		&webhook.Secret,
+		&webhook.EventTypes,
+		&webhook.Filter,
+		&webhook.Enabled,
+		&webhook.ConsecutiveFailures,
+		&webhook.DisabledAt,
+		&webhook.DisabledReason,
+		&webhook.CreatedAt,
+	)
+}
@@ -0,0 +1,229 @@
+package schemas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"service-news-app-backend/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type WebhookDeliverySchema struct {
+	DeliveryId     int64           `json:"deliveryId"`
+	WebhookId      int64           `json:"webhookId"`
+	EventType      string          `json:"eventType"`
+	ArticleId      string          `json:"articleId"`
+	Payload        json.RawMessage `json:"payload"` // -- exact JSON body that is signed and sent
+	Status         string          `json:"status"`  // -- "pending", "succeeded" or "failed" (attempts exhausted)
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"` // TIMESTAMP
+	LastStatusCode int             `json:"lastStatusCode"`
+	LastError      string          `json:"lastError"`
+	DeliveredAt    *time.Time      `json:"deliveredAt"` // TIMESTAMP
+	CreatedAt      time.Time       `json:"createdAt"`   // TIMESTAMP
+}
+
+const webhookDeliveryColumns = `delivery_id, webhook_id, event_type, article_id, payload::TEXT, status, attempts, next_attempt_at,
+	last_status_code, last_error, delivered_at, created_at`
+
+// CreateWebhookDeliveriesTable creates the webhook delivery log table in the database
+func CreateWebhookDeliveriesTable(ctx context.Context, pool *pgxpool.Pool) error {
+	webhookDeliveryTableName := config.GetEnvironmentVariable("WEBHOOK_DELIVERY_TABLE_NAME")
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		delivery_id BIGSERIAL PRIMARY KEY,
+		webhook_id BIGINT NOT NULL,
+		event_type TEXT NOT NULL,
+		article_id TEXT NOT NULL,
+		payload JSONB NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_status_code INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		delivered_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_webhook_id_idx ON %[1]s (webhook_id, delivery_id DESC);
+	CREATE INDEX IF NOT EXISTS %[1]s_due_idx ON %[1]s (next_attempt_at) WHERE status = 'pending';
+	ALTER TABLE %[1]s DROP COLUMN IF EXISTS last_response;`, webhookDeliveryTableName)
+
+	// Execute the SQL command to create the table
+	_, err := pool.Exec(ctx, createTableSQL)
+	if err != nil {
+		fmt.Println("Error creating webhook deliveries table: ", err)
+		return err
+	}
+
+	log.Printf("%s table created successfully or already exists\n", webhookDeliveryTableName)
+	return nil
+}
+
+// InsertWebhookDelivery queues a delivery to be sent as soon as possible
+func InsertWebhookDelivery(ctx context.Context, pool *pgxpool.Pool, delivery WebhookDeliverySchema) (*WebhookDeliverySchema, error) {
+	webhookDeliveryTableName := config.GetEnvironmentVariable("WEBHOOK_DELIVERY_TABLE_NAME")
+
+	insertSQL := fmt.Sprintf(`
+    INSERT INTO %s (webhook_id, event_type, article_id, payload)
+    VALUES ($1, $2, $3, $4::JSONB)
+    RETURNING %s;`, webhookDeliveryTableName, webhookDeliveryColumns)
+
+	var storedDelivery WebhookDeliverySchema
+	err := scanWebhookDelivery(pool.QueryRow(ctx, insertSQL,
+		delivery.WebhookId,
+		delivery.EventType,
+		delivery.ArticleId,
+		string(delivery.Payload)), &storedDelivery)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting webhook delivery: %v", err)
+	}
+
+	return &storedDelivery, nil
+}
+
+// GetWebhookDeliveries lists the most recent deliveries of a webhook
+func GetWebhookDeliveries(ctx context.Context, pool *pgxpool.Pool, webhookId int64, status string, limit int, offset int) ([]WebhookDeliverySchema, error) {
+	webhookDeliveryTableName := config.GetEnvironmentVariable("WEBHOOK_DELIVERY_TABLE_NAME")
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s
+	WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+	ORDER BY delivery_id DESC
+	LIMIT $3 OFFSET $4;`, webhookDeliveryColumns, webhookDeliveryTableName)
+
+	return queryWebhookDeliveries(ctx, pool, query, webhookId, status, limit, offset)
+}
+
+// ClaimDueWebhookDeliveries picks pending deliveries whose next attempt is due and leases them
+// for leaseDuration, so concurrent workers never send the same delivery twice
+func ClaimDueWebhookDeliveries(ctx context.Context, pool *pgxpool.Pool, limit int, leaseDuration time.Duration) ([]WebhookDeliverySchema, error) {
+	webhookDeliveryTableName := config.GetEnvironmentVariable("WEBHOOK_DELIVERY_TABLE_NAME")
+
+	claimSQL := fmt.Sprintf(`
+    UPDATE %[1]s
+    SET next_attempt_at = $2
+    WHERE delivery_id IN (
+        SELECT delivery_id
+        FROM %[1]s
+        WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+        ORDER BY next_attempt_at
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING %[2]s;`, webhookDeliveryTableName, webhookDeliveryColumns)
+
+	return queryWebhookDeliveries(ctx, pool, claimSQL, limit, time.Now().UTC().Add(leaseDuration))
+}
+
+// RecordWebhookDeliveryAttempt stores the outcome of an attempt. A nil nextAttemptAt means
+// no further attempt is planned, status tells whether the delivery succeeded or failed for good.
+func RecordWebhookDeliveryAttempt(ctx context.Context, pool *pgxpool.Pool, deliveryId int64, status string, statusCode int, lastError string, nextAttemptAt *time.Time) error {
+	webhookDeliveryTableName := config.GetEnvironmentVariable("WEBHOOK_DELIVERY_TABLE_NAME")
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET status = $2,
+        attempts = attempts + 1,
+        last_status_code = $3,
+        last_error = $4,
+        next_attempt_at = COALESCE($5, next_attempt_at),
+        delivered_at = CASE WHEN $2 = 'succeeded' THEN CURRENT_TIMESTAMP ELSE delivered_at END
+    WHERE delivery_id = $1;`, webhookDeliveryTableName)
+
+	_, err := pool.Exec(ctx, updateSQL, deliveryId, status, statusCode, lastError, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("error recording webhook delivery attempt: %v", err)
+	}
+
+	return nil
+}
+
+// ReplayWebhookDelivery queues a delivery of the webhook to be sent again with a fresh attempt budget.
+// It returns nil when the webhook has no such delivery.
+func ReplayWebhookDelivery(ctx context.Context, pool *pgxpool.Pool, webhookId int64, deliveryId int64) (*WebhookDeliverySchema, error) {
+	webhookDeliveryTableName := config.GetEnvironmentVariable("WEBHOOK_DELIVERY_TABLE_NAME")
+
+	updateSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, last_error = ''
+    WHERE webhook_id = $1 AND delivery_id = $2
+    RETURNING %s;`, webhookDeliveryTableName, webhookDeliveryColumns)
+
+	var delivery WebhookDeliverySchema
+	err := scanWebhookDelivery(pool.QueryRow(ctx, updateSQL, webhookId, deliveryId), &delivery)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error replaying webhook delivery: %v", err)
+	}
+
+	return &delivery, nil
+}
+
+func queryWebhookDeliveries(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]WebhookDeliverySchema, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDeliverySchema{}
+	for rows.Next() {
+		var delivery WebhookDeliverySchema
+		if err := scanWebhookDelivery(rows, &delivery); err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %v", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func scanWebhookDelivery(row pgx.Row, delivery *WebhookDeliverySchema) error {
+	return row.Scan(
+		&delivery.DeliveryId,
+		&delivery.WebhookId,
+		&delivery.EventType,
+		&delivery.ArticleId,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.DeliveredAt,
+		&delivery.CreatedAt,
+	)
+}
+
+// redactWebhookDeliveriesByArticle removes the deliveries carrying a purged article, so they can't be replayed,
+// within the purge transaction. Deletion notices are kept with a payload reduced to the article id.
+func redactWebhookDeliveriesByArticle(ctx context.Context, tx pgx.Tx, articleId string) error {
+	webhookDeliveryTableName := config.GetEnvironmentVariable("WEBHOOK_DELIVERY_TABLE_NAME")
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE article_id = $1 AND event_type <> 'article.deleted';`, webhookDeliveryTableName), articleId)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook deliveries: %v", err)
+	}
+
+	redactSQL := fmt.Sprintf(`
+    UPDATE %s
+    SET payload = jsonb_build_object(
+            'event', payload->'event',
+            'occurredAt', payload->'occurredAt',
+            'article', jsonb_build_object('articleId', article_id))
+    WHERE article_id = $1;`, webhookDeliveryTableName)
+
+	_, err = tx.Exec(ctx, redactSQL, articleId)
+	if err != nil {
+		return fmt.Errorf("error redacting webhook deliveries: %v", err)
+	}
+	return nil
+}
@@ -9,7 +9,7 @@ func ValidateInput(input interface{}) error {
 }
 
 type ExtractMetaDataHandlerBody *struct {
-	ArticleId       string   `validate:"required" json:"articleId,omitempty" bson:"articleId,omitempty"`
+	ArticleId       string   `validate:"required,uuid" json:"articleId,omitempty" bson:"articleId,omitempty"`
 	Title           string   `validate:"required" json:"title,omitempty" bson:"title,omitempty"`
 	Publisher       string   `validate:"required" json:"publisher,omitempty" bson:"publisher,omitempty"`
 	PublicationDate string   `validate:"required" json:"publicationDate,omitempty" bson:"publicationDate,omitempty"`
@@ -18,4 +18,5 @@ type ExtractMetaDataHandlerBody *struct {
 	Summary         string   `validate:"required" json:"summary,omitempty" bson:"summary,omitempty"`
 	Tags            []string `validate:"required" json:"tags,omitempty" bson:"tags,omitempty"`
 	ContentS3Path   string   `validate:"required" json:"contentS3Path,omitempty" bson:"contentS3Path,omitempty"`
+	Language        string   `validate:"omitempty,len=2" json:"language,omitempty" bson:"language,omitempty"`
 }
@@ -0,0 +1,15 @@
+package commands
+
+import "fmt"
+
+// Run runs a one-off command of the binary (e.g. "eval" or "train") instead of the server
+func Run(name string, args []string) error {
+	switch name {
+	case "eval":
+		return runEval(args)
+	case "train":
+		return runTrain(args)
+	default:
+		return fmt.Errorf("unknown command %q, available commands: eval, train", name)
+	}
+}
@@ -0,0 +1,515 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"sort"
+	"strings"
+)
+
+// EvalConfig is a configuration of the enrichment pipeline to evaluate, read from a JSON file
+type EvalConfig struct {
+	Name           string            `json:"name"`
+	Provider       string            `json:"provider"`       // -- "fake", "recorded", "openai" or "local"
+	Recordings     string            `json:"recordings"`     // -- LLM fixtures directory, replayed by "recorded" and recorded by "openai" and "local"
+	RecordedWith   string            `json:"recordedWith"`   // -- provider the fixtures of "recorded" were recorded with, "openai" (default) or "local"
+	PromptVersions map[string]string `json:"promptVersions"` // -- e.g., {"metadata": "v2"}, versions pinned for the run
+	PromptFiles    []string          `json:"promptFiles"`    // -- prompt versions not embedded yet, e.g., ["prompts/metadata.v2.json"]
+	Classifier     string            `json:"classifier"`     // -- category classifier written by the train command, tags the categories instead of the LLM
+}
+
+// goldenArticle is a line of the golden dataset. The expected metadata is read from "expected", or from
+// "corrected" so the editor corrections export (GET /review/corrections?format=jsonl) works as a dataset.
+// Fields left out of the expected metadata are not scored.
+type goldenArticle struct {
+	ArticleId string          `json:"articleId"`
+	Title     string          `json:"title"`
+	Content   string          `json:"content"`
+	Language  string          `json:"language"`
+	Expected  *goldenMetadata `json:"expected"`
+	Corrected *goldenMetadata `json:"corrected"`
+}
+
+type goldenMetadata struct {
+	Summary        *string         `json:"summary"`
+	Categories     *[]string       `json:"categories"`
+	Entities       *utils.Entities `json:"entities"`
+	SentimentScore *string         `json:"sentimentScore"`
+}
+
+// EvalResult holds the scores of a configuration over the dataset
+type EvalResult struct {
+	Name              string               `json:"name"`
+	Articles          int                  `json:"articles"`
+	Failures          int                  `json:"failures"` // -- articles the pipeline failed on, scored as empty output
+	Categories        utils.PRF            `json:"categories"`
+	Entities          utils.PRF            `json:"entities"`
+	EntitiesByType    map[string]utils.PRF `json:"entitiesByType"`
+	Sentiment         utils.PRF            `json:"sentiment"` // -- macro average over the sentiment labels
+	SentimentAccuracy float64              `json:"sentimentAccuracy"`
+	Rouge1            utils.PRF            `json:"rouge1"` // -- averaged over the articles
+	Rouge2            utils.PRF            `json:"rouge2"`
+	RougeL            utils.PRF            `json:"rougeL"`
+	Items             []EvalItemResult     `json:"items,omitempty"`
+}
+
+// EvalItemResult is the output of the pipeline for one article of the dataset
+type EvalItemResult struct {
+	ArticleId      string   `json:"articleId"`
+	Error          string   `json:"error,omitempty"`
+	Categories     []string `json:"categories"`
+	SentimentScore string   `json:"sentimentScore"`
+	CategoriesF1   *float64 `json:"categoriesF1,omitempty"`
+	EntitiesF1     *float64 `json:"entitiesF1,omitempty"`
+	RougeLF1       *float64 `json:"rougeLF1,omitempty"`
+}
+
+// EvalRegression compares a metric between the baseline and the candidate configuration
+type EvalRegression struct {
+	Metric    string  `json:"metric"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+	Delta     float64 `json:"delta"`
+	Regressed bool    `json:"regressed"`
+}
+
+// EvalReport is the output of the eval command
+type EvalReport struct {
+	Dataset     string           `json:"dataset"`
+	Baseline    EvalResult       `json:"baseline"`
+	Candidate   *EvalResult      `json:"candidate,omitempty"`
+	Regressions []EvalRegression `json:"regressions,omitempty"`
+}
+
+var sentimentLabels = []string{"Positive", "Negative", "Neutral"}
+
+// runEval scores the enrichment pipeline on a golden dataset, and compares two configurations when a
+// candidate is given. It fails when a metric of the candidate drops more than -max-drop below the baseline.
+//
+//	go run . eval -dataset golden.jsonl -baseline baseline.json -candidate candidate.json -out report.json
+func runEval(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	datasetPath := flags.String("dataset", "", "golden dataset, one JSON article per line")
+	baselinePath := flags.String("baseline", "", "baseline configuration file (default: fake provider, current prompts)")
+	candidatePath := flags.String("candidate", "", "candidate configuration file to compare with the baseline")
+	outPath := flags.String("out", "", "file to write the JSON report to")
+	maxDrop := flags.Float64("max-drop", 0.02, "largest drop of a metric allowed before the candidate counts as a regression")
+	details := flags.Bool("details", false, "include the output of every article in the report")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *datasetPath == "" {
+		return fmt.Errorf("eval: -dataset is required")
+	}
+
+	dataset, err := readGoldenDataset(*datasetPath)
+	if err != nil {
+		return err
+	}
+	if len(dataset) == 0 {
+		return fmt.Errorf("eval: %s has no article with expected metadata", *datasetPath)
+	}
+
+	baselineConfig := EvalConfig{Name: "baseline", Provider: "fake"}
+	if *baselinePath != "" {
+		if baselineConfig, err = readEvalConfig(*baselinePath); err != nil {
+			return err
+		}
+	}
+
+	report := EvalReport{Dataset: *datasetPath}
+	report.Baseline, err = evaluateConfig(baselineConfig, dataset, *details)
+	if err != nil {
+		return err
+	}
+
+	if *candidatePath != "" {
+		candidateConfig, err := readEvalConfig(*candidatePath)
+		if err != nil {
+			return err
+		}
+		candidate, err := evaluateConfig(candidateConfig, dataset, *details)
+		if err != nil {
+			return err
+		}
+		report.Candidate = &candidate
+		report.Regressions = compareEvalResults(report.Baseline, candidate, *maxDrop)
+	}
+
+	printEvalReport(os.Stdout, report)
+
+	if *outPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*outPath, data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	regressed := 0
+	for _, regression := range report.Regressions {
+		if regression.Regressed {
+			regressed++
+		}
+	}
+	if regressed > 0 {
+		return fmt.Errorf("eval: %d metrics regressed by more than %g", regressed, *maxDrop)
+	}
+
+	return nil
+}
+
+func readGoldenDataset(path string) ([]goldenArticle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dataset := []goldenArticle{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var article goldenArticle
+		if err := json.Unmarshal(scanner.Bytes(), &article); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", path, line, err)
+		}
+		if article.Expected == nil {
+			article.Expected = article.Corrected
+		}
+		if article.Expected == nil || strings.TrimSpace(article.Content) == "" {
+			log.Printf("Skipping %s line %d: no content or expected metadata\n", path, line)
+			continue
+		}
+		if article.ArticleId == "" {
+			article.ArticleId = fmt.Sprintf("line-%d", line)
+		}
+
+		dataset = append(dataset, article)
+	}
+
+	return dataset, scanner.Err()
+}
+
+func readEvalConfig(path string) (EvalConfig, error) {
+	var evalConfig EvalConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return evalConfig, err
+	}
+	if err := json.Unmarshal(data, &evalConfig); err != nil {
+		return evalConfig, fmt.Errorf("%s: %v", path, err)
+	}
+	if evalConfig.Name == "" {
+		evalConfig.Name = strings.TrimSuffix(path, ".json")
+	}
+	return evalConfig, nil
+}
+
+// applyEvalConfig points the LLM calls and the prompt registry at the configuration. The returned function
+// puts them back.
+func applyEvalConfig(evalConfig EvalConfig) (func(), error) {
+	// fixtures are recorded and replayed at the HTTP level (see utils.RecordingLLMTransport), so "recorded"
+	// replays them through the provider they were recorded with
+	var provider utils.LLMProvider
+	var transport http.RoundTripper
+	switch evalConfig.Provider {
+	case "", "fake":
+		provider = utils.FakeLLMProvider{}
+	case "recorded":
+		if evalConfig.Recordings == "" {
+			return nil, fmt.Errorf("config %s: recordings is required", evalConfig.Name)
+		}
+		switch evalConfig.RecordedWith {
+		case "", "openai":
+			provider = utils.OpenAIProvider{}
+		case "local":
+			provider = utils.NewLocalLLMProvider()
+		default:
+			return nil, fmt.Errorf("config %s: unknown recordedWith provider %q", evalConfig.Name, evalConfig.RecordedWith)
+		}
+		transport = &utils.ReplayLLMTransport{Dir: evalConfig.Recordings}
+	case "openai", "local":
+		if evalConfig.Provider == "local" {
+			provider = &utils.ResilientLLMProvider{Provider: utils.NewLocalLLMProvider()}
+		} else {
+			provider = utils.NewResilientLLMProvider(utils.OpenAIProvider{})
+		}
+		if evalConfig.Recordings != "" {
+			transport = &utils.RecordingLLMTransport{Transport: http.DefaultTransport, Dir: evalConfig.Recordings}
+		}
+	default:
+		return nil, fmt.Errorf("config %s: unknown provider %q", evalConfig.Name, evalConfig.Provider)
+	}
+
+	var classifier *utils.CategoryClassifier
+	if evalConfig.Classifier != "" {
+		var err error
+		if classifier, err = utils.LoadCategoryClassifier(evalConfig.Classifier); err != nil {
+			return nil, fmt.Errorf("config %s: %v", evalConfig.Name, err)
+		}
+	}
+
+	prompts := []utils.PromptVersion{}
+	for _, path := range evalConfig.PromptFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %v", evalConfig.Name, err)
+		}
+		var prompt utils.PromptVersion
+		if err := json.Unmarshal(data, &prompt); err != nil {
+			return nil, fmt.Errorf("config %s: %s: %v", evalConfig.Name, path, err)
+		}
+		prompts = append(prompts, prompt)
+	}
+
+	utils.SetLLMProvider(provider)
+	utils.SetLLMTransport(transport)
+	utils.SetCategoryClassifier(classifier)
+	utils.SetStoredPromptVersions(prompts)
+	utils.PinPromptVersions(evalConfig.PromptVersions)
+
+	return func() {
+		utils.SetLLMProvider(nil)
+		utils.SetLLMTransport(nil)
+		utils.SetCategoryClassifier(nil)
+		utils.SetStoredPromptVersions(nil)
+		utils.PinPromptVersions(nil)
+	}, nil
+}
+
+// evaluateConfig runs the pipeline over the dataset with the configuration and scores its output
+func evaluateConfig(evalConfig EvalConfig, dataset []goldenArticle, details bool) (EvalResult, error) {
+	restore, err := applyEvalConfig(evalConfig)
+	if err != nil {
+		return EvalResult{}, err
+	}
+	defer restore()
+
+	ctx := context.Background()
+	result := EvalResult{Name: evalConfig.Name, Articles: len(dataset), EntitiesByType: map[string]utils.PRF{}}
+
+	var categoryCounts, entityCounts utils.MatchCounts
+	entityTypeCounts := map[string]utils.MatchCounts{}
+	sentimentCounts := map[string]utils.MatchCounts{}
+	sentimentScored, sentimentCorrect := 0, 0
+	summariesScored := 0
+
+	for _, golden := range dataset {
+		article := schemas.ArticleSchema{
+			ArticleId: golden.ArticleId,
+			Title:     golden.Title,
+			Content:   golden.Content,
+			Language:  golden.Language,
+		}
+		item := EvalItemResult{ArticleId: golden.ArticleId}
+
+		// a failure is scored as an empty output rather than left out, so it can't improve the scores
+		if err := workers.GenerateArticleMetadata(ctx, &article, nil); err != nil {
+			result.Failures++
+			item.Error = err.Error()
+			log.Printf("Error enriching %s with %s: %v\n", golden.ArticleId, evalConfig.Name, err)
+		}
+		item.Categories = article.Categories
+		item.SentimentScore = article.SentimentScore
+
+		expected := golden.Expected
+		if expected.Categories != nil {
+			counts := utils.MatchSets(*expected.Categories, article.Categories)
+			categoryCounts = categoryCounts.Add(counts)
+			f1 := counts.PRF().F1
+			item.CategoriesF1 = &f1
+		}
+
+		if expected.Entities != nil {
+			predicted := entitiesByType(article.Entities)
+			itemCounts := utils.MatchCounts{}
+			for entityType, names := range entitiesByType(*expected.Entities) {
+				counts := utils.MatchSets(names, predicted[entityType])
+				entityTypeCounts[entityType] = entityTypeCounts[entityType].Add(counts)
+				itemCounts = itemCounts.Add(counts)
+			}
+			entityCounts = entityCounts.Add(itemCounts)
+			f1 := itemCounts.PRF().F1
+			item.EntitiesF1 = &f1
+		}
+
+		if expected.SentimentScore != nil {
+			sentimentScored++
+			if strings.EqualFold(*expected.SentimentScore, article.SentimentScore) {
+				sentimentCorrect++
+			}
+			for _, label := range sentimentLabels {
+				counts := utils.MatchSets(labelIf(*expected.SentimentScore, label), labelIf(article.SentimentScore, label))
+				sentimentCounts[label] = sentimentCounts[label].Add(counts)
+			}
+		}
+
+		if expected.Summary != nil {
+			summariesScored++
+			result.Rouge1 = addPRF(result.Rouge1, utils.RougeN(*expected.Summary, article.Summary, 1))
+			result.Rouge2 = addPRF(result.Rouge2, utils.RougeN(*expected.Summary, article.Summary, 2))
+			rougeL := utils.RougeL(*expected.Summary, article.Summary)
+			result.RougeL = addPRF(result.RougeL, rougeL)
+			item.RougeLF1 = &rougeL.F1
+		}
+
+		if details {
+			result.Items = append(result.Items, item)
+		}
+	}
+
+	result.Categories = categoryCounts.PRF()
+	result.Entities = entityCounts.PRF()
+	for entityType, counts := range entityTypeCounts {
+		result.EntitiesByType[entityType] = counts.PRF()
+	}
+
+	if sentimentScored > 0 {
+		result.SentimentAccuracy = float64(sentimentCorrect) / float64(sentimentScored)
+		// labels neither expected nor predicted would score a perfect 1 and are left out of the average
+		labels := 0
+		for _, label := range sentimentLabels {
+			if counts := sentimentCounts[label]; counts.Predicted+counts.Expected > 0 {
+				result.Sentiment = addPRF(result.Sentiment, counts.PRF())
+				labels++
+			}
+		}
+		result.Sentiment = scalePRF(result.Sentiment, labels)
+	}
+
+	result.Rouge1 = scalePRF(result.Rouge1, summariesScored)
+	result.Rouge2 = scalePRF(result.Rouge2, summariesScored)
+	result.RougeL = scalePRF(result.RougeL, summariesScored)
+
+	return result, nil
+}
+
+// entitiesByType flattens entities into their names per type
+func entitiesByType(entities any) map[string][]string {
+	var typed utils.Entities
+	switch value := entities.(type) {
+	case utils.Entities:
+		typed = value
+	case nil:
+	default:
+		// entities read back from JSON
+		json.Unmarshal(utils.ConvertToJson(value), &typed)
+	}
+
+	return map[string][]string{
+		"organizations": typed.Organizations,
+		"locations":     typed.Locations,
+		"individuals":   typed.Individuals,
+	}
+}
+
+// labelIf returns the value as a single label set when it is the label, for per-label scoring
+func labelIf(value string, label string) []string {
+	if strings.EqualFold(value, label) {
+		return []string{label}
+	}
+	return nil
+}
+
+func addPRF(total utils.PRF, scores utils.PRF) utils.PRF {
+	return utils.PRF{
+		Precision: total.Precision + scores.Precision,
+		Recall:    total.Recall + scores.Recall,
+		F1:        total.F1 + scores.F1,
+	}
+}
+
+func scalePRF(total utils.PRF, count int) utils.PRF {
+	if count == 0 {
+		return utils.PRF{}
+	}
+	return utils.PRF{
+		Precision: total.Precision / float64(count),
+		Recall:    total.Recall / float64(count),
+		F1:        total.F1 / float64(count),
+	}
+}
+
+// evalMetrics lists the headline metrics of a result, the ones compared between configurations
+func evalMetrics(result EvalResult) map[string]float64 {
+	return map[string]float64{
+		"categories.f1":      result.Categories.F1,
+		"entities.f1":        result.Entities.F1,
+		"sentiment.f1":       result.Sentiment.F1,
+		"sentiment.accuracy": result.SentimentAccuracy,
+		"summary.rouge1.f1":  result.Rouge1.F1,
+		"summary.rouge2.f1":  result.Rouge2.F1,
+		"summary.rougeL.f1":  result.RougeL.F1,
+	}
+}
+
+func compareEvalResults(baseline EvalResult, candidate EvalResult, maxDrop float64) []EvalRegression {
+	baselineMetrics := evalMetrics(baseline)
+	candidateMetrics := evalMetrics(candidate)
+
+	metrics := []string{}
+	for metric := range baselineMetrics {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	regressions := []EvalRegression{}
+	for _, metric := range metrics {
+		delta := candidateMetrics[metric] - baselineMetrics[metric]
+		regressions = append(regressions, EvalRegression{
+			Metric:    metric,
+			Baseline:  baselineMetrics[metric],
+			Candidate: candidateMetrics[metric],
+			Delta:     delta,
+			Regressed: delta < -maxDrop,
+		})
+	}
+	return regressions
+}
+
+func printEvalReport(out io.Writer, report EvalReport) {
+	printResult := func(result EvalResult) {
+		fmt.Fprintf(out, "%s: %d articles, %d failures\n", result.Name, result.Articles, result.Failures)
+		metrics := evalMetrics(result)
+		names := []string{}
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(out, "  %-20s %.3f\n", name, metrics[name])
+		}
+	}
+
+	printResult(report.Baseline)
+	if report.Candidate == nil {
+		return
+	}
+	printResult(*report.Candidate)
+
+	fmt.Fprintf(out, "\n%-20s %9s %9s %9s\n", "metric", "baseline", "candidate", "delta")
+	for _, regression := range report.Regressions {
+		flag := ""
+		if regression.Regressed {
+			flag = "  REGRESSION"
+		}
+		fmt.Fprintf(out, "%-20s %9.3f %9.3f %+9.3f%s\n", regression.Metric, regression.Baseline, regression.Candidate, regression.Delta, flag)
+	}
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	envUtil "service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"sort"
+)
+
+// runTrain trains the offline category classifier on labeled articles, from a dataset in the format of the
+// eval command (e.g. the editor corrections export) or from the published articles of the database. A share
+// of the articles is held out to report the scores, the stored model is then trained on all of them.
+//
+//	go run . train -dataset corrections.jsonl -out models/category_classifier.json
+func runTrain(args []string) error {
+	flags := flag.NewFlagSet("train", flag.ContinueOnError)
+	datasetPath := flags.String("dataset", "", "labeled articles, one JSON article per line with expected or corrected categories")
+	fromDatabase := flags.Bool("from-db", false, "train on the published articles of the database instead of a dataset")
+	limit := flags.Int("limit", 20000, "most recent articles taken from the database")
+	outPath := flags.String("out", envUtil.GetEnvironmentVariableOrDefault("CATEGORY_CLASSIFIER_PATH", "models/category_classifier.json"), "file to write the classifier to")
+	validation := flags.Float64("validation", 0.2, "share of the articles held out to score the classifier, 0 to skip scoring")
+	epochs := flags.Int("epochs", utils.DefaultClassifierTrainingOptions.Epochs, "passes over the articles")
+	minDocumentFrequency := flags.Int("min-df", utils.DefaultClassifierTrainingOptions.MinDocumentFrequency, "articles a term must appear in to be a feature")
+	maxFeatures := flags.Int("max-features", utils.DefaultClassifierTrainingOptions.MaxFeatures, "largest vocabulary")
+	threshold := flags.Float64("threshold", utils.DefaultClassifierTrainingOptions.Threshold, "probability a category needs to be predicted")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if (*datasetPath == "") == !*fromDatabase {
+		return fmt.Errorf("train: give either -dataset or -from-db")
+	}
+	if *validation < 0 || *validation >= 1 {
+		return fmt.Errorf("train: -validation must be between 0 and 1")
+	}
+
+	var examples []utils.ClassifierExample
+	var err error
+	if *fromDatabase {
+		examples, err = readDatabaseExamples(*limit)
+	} else {
+		examples, err = readDatasetExamples(*datasetPath)
+	}
+	if err != nil {
+		return err
+	}
+	if len(examples) < 2 {
+		return fmt.Errorf("train: %d labeled articles, not enough to train on", len(examples))
+	}
+
+	options := utils.DefaultClassifierTrainingOptions
+	options.Epochs = *epochs
+	options.MinDocumentFrequency = *minDocumentFrequency
+	options.MaxFeatures = *maxFeatures
+	options.Threshold = *threshold
+
+	// the same articles are held out on every run, so runs can be compared
+	rand.New(rand.NewSource(1)).Shuffle(len(examples), func(i, j int) { examples[i], examples[j] = examples[j], examples[i] })
+
+	heldOut := int(float64(len(examples)) * *validation)
+	if heldOut > 0 {
+		trainingSet, validationSet := examples[:len(examples)-heldOut], examples[len(examples)-heldOut:]
+		classifier, err := utils.TrainCategoryClassifier(trainingSet, options)
+		if err != nil {
+			return fmt.Errorf("train: %v", err)
+		}
+		printClassifierScores(os.Stdout, classifier, len(trainingSet), validationSet)
+	}
+
+	classifier, err := utils.TrainCategoryClassifier(examples, options)
+	if err != nil {
+		return fmt.Errorf("train: %v", err)
+	}
+	if err := utils.SaveCategoryClassifier(classifier, *outPath); err != nil {
+		return err
+	}
+
+	fmt.Printf("classifier of %d categories and %d terms trained on %d articles, written to %s\n",
+		len(classifier.Labels), len(classifier.IDF), len(examples), *outPath)
+	return nil
+}
+
+func readDatasetExamples(path string) ([]utils.ClassifierExample, error) {
+	dataset, err := readGoldenDataset(path)
+	if err != nil {
+		return nil, err
+	}
+
+	examples := []utils.ClassifierExample{}
+	for _, article := range dataset {
+		if article.Expected.Categories == nil || len(*article.Expected.Categories) == 0 {
+			continue
+		}
+		examples = append(examples, utils.ClassifierExample{
+			Text:   article.Title + "\n\n" + article.Content,
+			Labels: *article.Expected.Categories,
+		})
+	}
+	return examples, nil
+}
+
+func readDatabaseExamples(limit int) ([]utils.ClassifierExample, error) {
+	pool := PostgresInstance.CreatePostgresInstance()
+
+	articles, err := schemas.GetLabeledArticles(context.Background(), pool, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	examples := []utils.ClassifierExample{}
+	for _, article := range articles {
+		examples = append(examples, utils.ClassifierExample{
+			Text:   article.Title + "\n\n" + article.Content,
+			Labels: article.Categories,
+		})
+	}
+	return examples, nil
+}
+
+// printClassifierScores scores the classifier on the held out articles: micro averaged over the categories,
+// then per category
+func printClassifierScores(out io.Writer, classifier *utils.CategoryClassifier, trained int, validationSet []utils.ClassifierExample) {
+	var total utils.MatchCounts
+	perLabel := map[string]utils.MatchCounts{}
+	for _, example := range validationSet {
+		predicted, _ := classifier.Classify(example.Text)
+		total = total.Add(utils.MatchSets(example.Labels, predicted))
+		for _, label := range classifier.Labels {
+			perLabel[label] = perLabel[label].Add(utils.MatchSets(
+				filterLabel(example.Labels, label),
+				filterLabel(predicted, label),
+			))
+		}
+	}
+
+	scores := total.PRF()
+	fmt.Fprintf(out, "trained on %d articles, scored on %d held out\n", trained, len(validationSet))
+	fmt.Fprintf(out, "  %-24s P %.3f  R %.3f  F1 %.3f\n", "micro average", scores.Precision, scores.Recall, scores.F1)
+
+	labels := append([]string{}, classifier.Labels...)
+	sort.Strings(labels)
+	for _, label := range labels {
+		scores := perLabel[label].PRF()
+		fmt.Fprintf(out, "  %-24s P %.3f  R %.3f  F1 %.3f\n", label, scores.Precision, scores.Recall, scores.F1)
+	}
+}
+
+// filterLabel returns label alone when it is one of the labels, nothing otherwise
+func filterLabel(labels []string, label string) []string {
+	if utils.Includes(labels, label) {
+		return []string{label}
+	}
+	return []string{}
+}
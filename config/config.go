@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
@@ -25,3 +26,37 @@ func GetEnvironmentVariable(variableName string) string {
 		return variableValue
 	}
 }
+
+// GetEnvironmentVariableOrDefault returns the value of the variable, or defaultValue when it is not set
+func GetEnvironmentVariableOrDefault(variableName string, defaultValue string) string {
+
+	variableValue := GetEnvironmentVariable(variableName)
+	if variableValue == "" {
+		return defaultValue
+	} else {
+		return variableValue
+	}
+}
+
+// GetIntEnvironmentVariable returns the variable parsed as an int, or defaultValue when it is missing or invalid
+func GetIntEnvironmentVariable(variableName string, defaultValue int) int {
+
+	variableValue, err := strconv.Atoi(GetEnvironmentVariable(variableName))
+	if err != nil {
+		return defaultValue
+	} else {
+		return variableValue
+	}
+}
+
+// GetPositiveIntEnvironmentVariable is GetIntEnvironmentVariable for values that must be above zero,
+// e.g. durations used as divisors
+func GetPositiveIntEnvironmentVariable(variableName string, defaultValue int) int {
+
+	variableValue := GetIntEnvironmentVariable(variableName, defaultValue)
+	if variableValue <= 0 {
+		return defaultValue
+	} else {
+		return variableValue
+	}
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/middlewares"
+	"service-news-app-backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+type alertRuleBody struct {
+	Categories      []string `json:"categories"`
+	Entities        []string `json:"entities"`
+	Publishers      []string `json:"publishers"`
+	MinImportance   int      `validate:"min=0,max=100" json:"minImportance"`
+	Channels        []string `validate:"required,min=1,dive,oneof=webhook fcm email" json:"channels"`
+	WebhookUrl      string   `validate:"omitempty,url" json:"webhookUrl"`
+	FcmToken        string   `json:"fcmToken"`
+	Email           string   `validate:"omitempty,email" json:"email"`
+	QuietHoursStart *int     `validate:"omitempty,min=0,max=23" json:"quietHoursStart"`
+	QuietHoursEnd   *int     `validate:"omitempty,min=0,max=23" json:"quietHoursEnd"`
+	Timezone        string   `json:"timezone"`
+}
+
+// CreateAlertRuleHandler adds a breaking-news alert rule for the authenticated user
+func CreateAlertRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var body alertRuleBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	if body.Timezone == "" {
+		body.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(body.Timezone); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", "unknown timezone", nil)
+		return
+	}
+
+	// every channel needs its address, emails only go to the user's own verified address
+	for _, channel := range body.Channels {
+		if (channel == "webhook" && body.WebhookUrl == "") || (channel == "fcm" && body.FcmToken == "") {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", "missing address for channel "+channel, nil)
+			return
+		}
+		if channel == "email" {
+			verifiedEmail := middlewares.GetVerifiedEmail(r)
+			if verifiedEmail == "" {
+				utils.SendErrorResponse(w, http.StatusBadRequest, "emailNotVerified", "verify your email address to get alert emails", nil)
+				return
+			}
+			if body.Email != "" && !strings.EqualFold(body.Email, verifiedEmail) {
+				utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", "email must be your verified email address", nil)
+				return
+			}
+			body.Email = verifiedEmail
+		}
+	}
+	if !utils.Includes(body.Channels, "email") {
+		body.Email = ""
+	}
+
+	// the server posts to the webhook, it must not reach internal addresses
+	if body.WebhookUrl != "" {
+		if err := utils.ValidatePublicURL(r.Context(), body.WebhookUrl); err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "invalidWebhookUrl", err.Error(), nil)
+			return
+		}
+	}
+
+	rule, err := schemas.InsertAlertRule(ctx, PostgresInstance.GetPostgresInstance(), schemas.AlertRuleSchema{
+		UserId:          middlewares.GetUserId(r),
+		Categories:      body.Categories,
+		Entities:        body.Entities,
+		Publishers:      body.Publishers,
+		MinImportance:   body.MinImportance,
+		Channels:        utils.ConvertDuplicatesArrtoUniqueArr(body.Channels),
+		WebhookUrl:      body.WebhookUrl,
+		FcmToken:        body.FcmToken,
+		Email:           body.Email,
+		QuietHoursStart: body.QuietHoursStart,
+		QuietHoursEnd:   body.QuietHoursEnd,
+		Timezone:        body.Timezone,
+		Enabled:         true,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Alert rule created successfully", rule)
+}
+
+// GetAlertRulesHandler lists the alert rules of the authenticated user
+func GetAlertRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := schemas.GetAlertRulesByUser(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Alert rules fetched successfully", rules)
+}
+
+// DeleteAlertRuleHandler removes an alert rule of the authenticated user
+func DeleteAlertRuleHandler(w http.ResponseWriter, r *http.Request) {
+	ruleId, err := strconv.ParseInt(chi.URLParam(r, "ruleId"), 10, 64)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidRuleId", "rule id must be a number", nil)
+		return
+	}
+
+	deleted, err := schemas.DeleteAlertRule(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), ruleId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if !deleted {
+		utils.SendErrorResponse(w, http.StatusNotFound, "alertRuleNotFound", "alert rule not found", nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Alert rule deleted successfully", nil)
+}
@@ -3,10 +3,15 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	PostgresInstance "service-news-app-backend/Postgres_Instance"
 	schemas "service-news-app-backend/Schemas"
 	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"strconv"
+	"strings"
 )
 
 var ctx = context.Background()
@@ -25,19 +30,28 @@ func ExtractMetaDataHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Call OpenAI API to get categories
-	responseFromOpenAI, err := utils.GetResponseFromChatGPT(ctx, body.Content)
+	// optional optimistic concurrency check, checked before paying for the enrichment and again when storing
+	expectedVersion, err := parseIfMatchVersion(r)
 	if err != nil {
-		utils.SendErrorResponse(w, http.StatusInternalServerError, "openAIError", err.Error(), nil)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidIfMatchHeader", err.Error(), nil)
 		return
 	}
-
-	// generating summary
-	summary, err := utils.GenerateSummary(ctx, body.Content)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusInternalServerError, "openAIError", err.Error(), nil)
-		return
+	if expectedVersion != nil {
+		storedVersion, err := schemas.GetArticleVersion(ctx, PostgresInstance.GetPostgresInstance(), body.ArticleId)
+		if errors.Is(err, schemas.ErrArticleDeleted) {
+			utils.SendErrorResponse(w, http.StatusGone, "articleDeleted", err.Error(), nil)
+			return
+		}
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+			return
+		}
+		if storedVersion == nil || *storedVersion != *expectedVersion {
+			utils.SendErrorResponse(w, http.StatusPreconditionFailed, "versionConflict", schemas.ErrArticleVersionConflict.Error(), nil)
+			return
+		}
 	}
+
 	publicationDate, _ := utils.ConvertStringToTimestamp(body.PublicationDate)
 
 	// building article info struct
@@ -48,42 +62,64 @@ func ExtractMetaDataHandler(w http.ResponseWriter, r *http.Request) {
 		PublicationDate: publicationDate,
 		Url:             body.Url,
 		Content:         body.Content,
-		Summary:         summary,
 		Tags:            body.Tags,
-		Entities:        responseFromOpenAI.Entities,
-		SentimentScore:  responseFromOpenAI.SentimentScore,
-		Categories:      responseFromOpenAI.Categories,
 		ContentS3Path:   body.ContentS3Path,
+		Language:        body.Language,
 		Status:          "published",
 	}
 
-	// get article info
-	articleInfo, err := schemas.GetArticleByID(ctx, PostgresInstance.GetPostgresInstance(), body.ArticleId)
+	// subscribers get the same events as for a queued article, the enrichment just starts right away
+	workers.PublishArticleEvent(ctx, articleInfoObj.ArticleId, workers.ArticleEventQueued, map[string]string{"articleId": articleInfoObj.ArticleId})
+
+	// generating categories, entities, sentiment and summary
+	err = workers.EnrichArticle(r.Context(), &articleInfoObj)
 	if err != nil {
-		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		workers.PublishArticleEvent(ctx, articleInfoObj.ArticleId, workers.ArticleEventFailed, map[string]string{"error": err.Error()})
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "openAIError", err.Error(), nil)
 		return
 	}
 
-	if articleInfo == nil {
-		// store article info
-		err = schemas.InsertArticleData(ctx, PostgresInstance.GetPostgresInstance(), articleInfoObj)
-		if err != nil {
-			utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+	// store or update article info
+	created, articleInfo, err := schemas.UpsertArticle(ctx, PostgresInstance.GetPostgresInstance(), articleInfoObj, expectedVersion)
+	if err != nil {
+		workers.PublishArticleEvent(ctx, articleInfoObj.ArticleId, workers.ArticleEventFailed, map[string]string{"error": err.Error()})
+		if errors.Is(err, schemas.ErrArticleVersionConflict) {
+			utils.SendErrorResponse(w, http.StatusPreconditionFailed, "versionConflict", err.Error(), nil)
+			return
+		}
+		if errors.Is(err, schemas.ErrArticleDeleted) {
+			utils.SendErrorResponse(w, http.StatusGone, "articleDeleted", err.Error(), nil)
 			return
 		}
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
 
-		utils.SendSuccessResponse(w, http.StatusOK, "Aritcle Created successfully", nil)
+	workers.StoreArticleChunks(ctx, articleInfoObj)
+	workers.PublishArticleEvent(ctx, articleInfo.ArticleId, workers.ArticleEventPublished, map[string]interface{}{"status": articleInfo.Status, "version": articleInfo.Version})
 
+	// the new version may belong in the related articles of others
+	utils.BumpCacheGeneration(ctx, utils.RelatedArticlesCacheNamespace)
+
+	if created {
+		go workers.DispatchAlerts(ctx, *articleInfo)
+		go workers.EmitWebhookEvent(ctx, "article.published", *articleInfo)
 	} else {
-		// update article info
-		err = schemas.UpdateArticleByID(ctx, PostgresInstance.GetPostgresInstance(), articleInfoObj)
-		if err != nil {
-			utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
-			return
-		}
+		go workers.EmitWebhookEvent(ctx, "article.updated", *articleInfo)
+	}
+	go workers.PercolateSavedSearches(ctx, *articleInfo)
 
-		utils.SendSuccessResponse(w, http.StatusOK, "Aritcle Updated successfully", nil)
-		return
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, articleInfo.Version))
+
+	responseData := map[string]interface{}{
+		"created": created,
+		"article": articleInfo,
+	}
+
+	if created {
+		utils.SendSuccessResponse(w, http.StatusOK, "Aritcle Created successfully", responseData)
+	} else {
+		utils.SendSuccessResponse(w, http.StatusOK, "Aritcle Updated successfully", responseData)
 	}
 
 	// Create the prompt for OpenAI
@@ -96,3 +132,19 @@ func ExtractMetaDataHandler(w http.ResponseWriter, r *http.Request) {
 	// }
 
 }
+
+// parseIfMatchVersion reads the article version expected by the client from the If-Match header
+func parseIfMatchVersion(r *http.Request) (*int, error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, nil
+	}
+
+	ifMatch = strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	version, err := strconv.Atoi(ifMatch)
+	if err != nil {
+		return nil, fmt.Errorf("If-Match must be an article version")
+	}
+
+	return &version, nil
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// GetArticleEventsHandler streams the enrichment progress of an article over SSE: queued, metadata,
+// summary_delta (the summary as it is generated), summary, embedding, then published or failed, after
+// which the stream ends. Events of the current enrichment published before the client connected are
+// replayed first, and an article already enriched gets its published event right away.
+func GetArticleEventsHandler(w http.ResponseWriter, r *http.Request) {
+	articleId := chi.URLParam(r, "id")
+
+	// subscribe before reading the article so no event falls in between
+	history, events, unsubscribe := utils.SubscribeEvents(workers.ArticleEventsTopic(articleId))
+	defer unsubscribe()
+
+	// only replay the current enrichment
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Type == workers.ArticleEventQueued {
+			history = history[i:]
+			break
+		}
+	}
+
+	article, err := schemas.GetArticleByID(r.Context(), PostgresInstance.GetPostgresInstance(), articleId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	// articles created synchronously are only stored once enriched, their events come first
+	if article == nil && len(history) == 0 {
+		utils.SendErrorResponse(w, http.StatusNotFound, "articleNotFound", "Article not found", nil)
+		return
+	}
+
+	sse, err := utils.NewSSEWriter(w)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "streamingUnsupported", err.Error(), nil)
+		return
+	}
+
+	for _, event := range history {
+		if sse.Send(event.Type, event.Data) != nil || isFinalArticleEvent(event.Type) {
+			return
+		}
+	}
+	if len(history) == 0 && article.Status != "pending" {
+		sse.Send(workers.ArticleEventPublished, map[string]interface{}{"status": article.Status, "version": article.Version})
+		return
+	}
+
+	// proxies drop idle connections, comments keep it open
+	keepAlive := time.NewTicker(time.Duration(config.GetIntEnvironmentVariable("SSE_KEEPALIVE_SECONDS", 15)) * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if sse.Comment("keep-alive") != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if sse.Send(event.Type, event.Data) != nil || isFinalArticleEvent(event.Type) {
+				return
+			}
+		}
+	}
+}
+
+func isFinalArticleEvent(eventType string) bool {
+	return eventType == workers.ArticleEventPublished || eventType == workers.ArticleEventFailed
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/utils"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// GetArticleRevisionsHandler lists the previous versions of an article
+func GetArticleRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	articleId := chi.URLParam(r, "id")
+	pool := PostgresInstance.GetPostgresInstance()
+
+	// deleted articles have no public history
+	article, err := schemas.GetArticleByID(ctx, pool, articleId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if article == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "articleNotFound", "article not found", nil)
+		return
+	}
+
+	revisions, err := schemas.GetArticleRevisions(ctx, pool, articleId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Article revisions fetched successfully", revisions)
+}
+
+// GetArticleRevisionDiffHandler returns the word-level diff between a revision and the version that replaced it
+func GetArticleRevisionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	articleId := chi.URLParam(r, "id")
+	pool := PostgresInstance.GetPostgresInstance()
+
+	revisionNumber, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidRevision", "revision must be a number", nil)
+		return
+	}
+
+	revision, err := schemas.GetArticleRevision(ctx, pool, articleId, revisionNumber)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if revision == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "revisionNotFound", "revision not found", nil)
+		return
+	}
+
+	// the revision is compared with the next one, or with the live article when it is the latest
+	toRevision := revisionNumber + 1
+	var toTitle, toSummary, toContent string
+
+	nextRevision, err := schemas.GetArticleRevision(ctx, pool, articleId, toRevision)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	if nextRevision != nil {
+		toTitle, toSummary, toContent = nextRevision.Title, nextRevision.Summary, nextRevision.Content
+	} else {
+		article, err := schemas.GetArticleByID(ctx, pool, articleId)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+			return
+		}
+		if article == nil {
+			utils.SendErrorResponse(w, http.StatusNotFound, "articleNotFound", "article not found", nil)
+			return
+		}
+		toRevision = article.Version
+		toTitle, toSummary, toContent = article.Title, article.Summary, article.Content
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Article revision diff generated successfully", map[string]interface{}{
+		"articleId":    articleId,
+		"fromRevision": revisionNumber,
+		"toRevision":   toRevision,
+		"title":        utils.DiffWords(revision.Title, toTitle),
+		"summary":      utils.DiffWords(revision.Summary, toSummary),
+		"content":      utils.DiffWords(revision.Content, toContent),
+	})
+}
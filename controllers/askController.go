@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// refusalAnswer is returned instead of an answer the articles don't support
+const refusalAnswer = "I couldn't find enough coverage in our articles to answer that."
+
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+type askBody struct {
+	Question   string `validate:"required,min=3,max=500" json:"question"`
+	Days       int    `validate:"omitempty,min=1,max=365" json:"days"`      // -- how far back to look, defaults to a week
+	MaxSources int    `validate:"omitempty,min=1,max=20" json:"maxSources"` // -- number of articles given to the LLM
+	Stream     bool   `json:"stream"`                                       // -- stream over SSE, also enabled by Accept: text/event-stream
+}
+
+type askCitation struct {
+	Index           int          `json:"index"`
+	ArticleId       string       `json:"articleId"`
+	Title           string       `json:"title"`
+	Url             string       `json:"url"`
+	Publisher       string       `json:"publisher"`
+	PublicationDate time.Time    `json:"publicationDate"`
+	Passages        []askPassage `json:"passages"` // -- the passages given to the LLM, empty when it got the summary
+}
+
+// askPassage is a cited passage, its offsets locate it in the article content
+type askPassage struct {
+	ChunkIndex  int    `json:"chunkIndex"`
+	StartOffset int    `json:"startOffset"`
+	EndOffset   int    `json:"endOffset"`
+	Text        string `json:"text"`
+}
+
+type askResponse struct {
+	Question  string        `json:"question"`
+	Answer    string        `json:"answer"`
+	Refused   bool          `json:"refused"`
+	Citations []askCitation `json:"citations"`
+}
+
+// AskHandler answers a question from the article corpus with inline citations, refusing when the
+// retrieved articles don't support an answer. The answer is streamed over SSE when asked to.
+func AskHandler(w http.ResponseWriter, r *http.Request) {
+	var body askBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	if body.Days == 0 {
+		body.Days = config.GetIntEnvironmentVariable("ASK_DEFAULT_DAYS", 7)
+	}
+	if body.MaxSources == 0 {
+		body.MaxSources = config.GetIntEnvironmentVariable("ASK_MAX_SOURCES", 6)
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -body.Days)
+	retrieved, err := workers.RetrieveArticles(r.Context(), body.Question, since, body.MaxSources)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	articleIds := []string{}
+	for _, result := range retrieved {
+		articleIds = append(articleIds, result.Article.ArticleId)
+	}
+	passages, err := workers.RetrieveArticlePassages(r.Context(), body.Question, articleIds, config.GetIntEnvironmentVariable("ASK_PASSAGES_PER_SOURCE", 2))
+	if err != nil {
+		log.Println("Error retrieving passages, citing summaries: ", err)
+		passages = map[string][]workers.RetrievedPassage{}
+	}
+
+	sources, citations := buildAnswerSources(retrieved, passages)
+	stream := body.Stream || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	if stream {
+		streamAnswer(w, r, body.Question, sources, citations)
+		return
+	}
+
+	response := askResponse{Question: body.Question, Answer: refusalAnswer, Refused: true, Citations: []askCitation{}}
+	if len(sources) > 0 {
+		answer, err := utils.GenerateGroundedAnswer(r.Context(), body.Question, sources, nil)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadGateway, "openAIError", err.Error(), nil)
+			return
+		}
+		response = finalizeAnswer(body.Question, answer, citations)
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Answer generated successfully", response)
+}
+
+// streamAnswer sends the candidate sources, then the answer as "delta" events, then a "done" event
+// with the final answer and the sources it cited. Clients show the "done" answer, it is a refusal
+// when the streamed text turned out to cite nothing.
+func streamAnswer(w http.ResponseWriter, r *http.Request, question string, sources []utils.AnswerSource, citations []askCitation) {
+	sse, err := utils.NewSSEWriter(w)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "streamingUnsupported", err.Error(), nil)
+		return
+	}
+
+	if len(sources) == 0 {
+		sse.Send("done", askResponse{Question: question, Answer: refusalAnswer, Refused: true, Citations: []askCitation{}})
+		return
+	}
+
+	sse.Send("sources", citations)
+
+	// hold the text back while it could still be the insufficient evidence reply, so it never reaches the client
+	var pending strings.Builder
+	holding := true
+	answer, err := utils.GenerateGroundedAnswer(r.Context(), question, sources, func(delta string) error {
+		if !holding {
+			return sse.Send("delta", map[string]string{"text": delta})
+		}
+
+		pending.WriteString(delta)
+		buffered := strings.TrimSpace(pending.String())
+		if strings.HasPrefix(utils.InsufficientEvidenceAnswer, buffered) {
+			return nil
+		}
+
+		holding = false
+		return sse.Send("delta", map[string]string{"text": pending.String()})
+	})
+	if err != nil {
+		sse.Send("error", map[string]string{"message": err.Error()})
+		return
+	}
+
+	sse.Send("done", finalizeAnswer(question, answer, citations))
+}
+
+// buildAnswerSources keeps the retrieved articles that are real evidence: a full-text match or
+// an embedding close enough to the question. Each one is numbered for citation, its text is its
+// passages matching the question in reading order, or its summary when it has none.
+func buildAnswerSources(retrieved []workers.RetrievedArticle, passages map[string][]workers.RetrievedPassage) ([]utils.AnswerSource, []askCitation) {
+	minSimilarity := float64(config.GetIntEnvironmentVariable("ASK_MIN_SIMILARITY_PERCENT", 78)) / 100
+
+	sources := []utils.AnswerSource{}
+	citations := []askCitation{}
+	for _, result := range retrieved {
+		if result.TextRank == 0 && result.Similarity < minSimilarity {
+			continue
+		}
+
+		article := result.Article
+		index := len(sources) + 1
+
+		citedPassages := []askPassage{}
+		for _, passage := range passages[article.ArticleId] {
+			citedPassages = append(citedPassages, askPassage{
+				ChunkIndex:  passage.Passage.ChunkIndex,
+				StartOffset: passage.Passage.StartOffset,
+				EndOffset:   passage.Passage.EndOffset,
+				Text:        passage.Passage.Content,
+			})
+		}
+		sort.Slice(citedPassages, func(i, j int) bool { return citedPassages[i].ChunkIndex < citedPassages[j].ChunkIndex })
+
+		text := article.Summary
+		if len(citedPassages) > 0 {
+			texts := []string{}
+			for _, passage := range citedPassages {
+				texts = append(texts, passage.Text)
+			}
+			text = strings.Join(texts, "\n...\n")
+		}
+
+		sources = append(sources, utils.AnswerSource{
+			Index:           index,
+			Title:           article.Title,
+			Publisher:       article.Publisher,
+			PublicationDate: article.PublicationDate.Format("2006-01-02"),
+			Text:            text,
+		})
+		citations = append(citations, askCitation{
+			Index:           index,
+			ArticleId:       article.ArticleId,
+			Title:           article.Title,
+			Url:             article.Url,
+			Publisher:       article.Publisher,
+			PublicationDate: article.PublicationDate,
+			Passages:        citedPassages,
+		})
+	}
+
+	return sources, citations
+}
+
+// finalizeAnswer turns the LLM reply into the response, an answer without any valid citation is not grounded
+// and is refused like an explicit insufficient evidence reply
+func finalizeAnswer(question string, answer string, citations []askCitation) askResponse {
+	answer = strings.TrimSpace(answer)
+
+	cited := []askCitation{}
+	seen := map[int]bool{}
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		index, _ := strconv.Atoi(match[1])
+		if index < 1 || index > len(citations) || seen[index] {
+			continue
+		}
+		seen[index] = true
+		cited = append(cited, citations[index-1])
+	}
+
+	if strings.Contains(answer, utils.InsufficientEvidenceAnswer) || len(cited) == 0 {
+		return askResponse{Question: question, Answer: refusalAnswer, Refused: true, Citations: []askCitation{}}
+	}
+
+	return askResponse{Question: question, Answer: answer, Refused: false, Citations: cited}
+}
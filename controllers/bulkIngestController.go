@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"strings"
+)
+
+// maximum size of a single NDJSON line, articles carry their full content
+const maxBulkLineSize = 10 * 1024 * 1024
+
+// BulkLineResult is the per-line outcome streamed back by BulkIngestArticlesHandler
+type BulkLineResult struct {
+	Line      int    `json:"line"`
+	ArticleId string `json:"articleId,omitempty"`
+	Status    string `json:"status"` // -- "accepted", "deferred", "duplicate" or "invalid"
+	Reason    string `json:"reason,omitempty"`
+}
+
+// BulkIngestSummary is the last line of the bulk ingestion report
+type BulkIngestSummary struct {
+	Accepted  int    `json:"accepted"`
+	Deferred  int    `json:"deferred"` // -- stored as pending but not queued, enriched on the next start
+	Duplicate int    `json:"duplicate"`
+	Invalid   int    `json:"invalid"`
+	Error     string `json:"error,omitempty"`
+}
+
+type pendingBulkLine struct {
+	line    int
+	article schemas.ArticleSchema
+}
+
+// BulkIngestArticlesHandler accepts NDJSON (optionally gzip compressed) with one article per line,
+// stores the valid articles as pending, enqueues them for enrichment and streams back a per-line report
+func BulkIngestArticlesHandler(w http.ResponseWriter, r *http.Request) {
+
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" || strings.Contains(r.Header.Get("Content-Type"), "gzip") {
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "invalidGzipBody", err.Error(), nil)
+			return
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	batchSize := config.GetIntEnvironmentVariable("BULK_INGEST_BATCH_SIZE", 500)
+
+	summary := BulkIngestSummary{}
+	batch := []pendingBulkLine{}
+
+	writeResult := func(result BulkLineResult) {
+		switch result.Status {
+		case "accepted":
+			summary.Accepted++
+		case "deferred":
+			summary.Deferred++
+		case "duplicate":
+			summary.Duplicate++
+		default:
+			summary.Invalid++
+		}
+		encoder.Encode(result)
+	}
+
+	flushBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		articles := make([]schemas.ArticleSchema, len(batch))
+		for i, pending := range batch {
+			articles[i] = pending.article
+		}
+
+		created, err := schemas.BulkInsertArticles(ctx, PostgresInstance.GetPostgresInstance(), articles)
+		if err != nil {
+			return err
+		}
+
+		for i, pending := range batch {
+			if created[i] && workers.EnqueueEnrichment(pending.article.ArticleId) {
+				writeResult(BulkLineResult{Line: pending.line, ArticleId: pending.article.ArticleId, Status: "accepted"})
+			} else if created[i] {
+				writeResult(BulkLineResult{Line: pending.line, ArticleId: pending.article.ArticleId, Status: "deferred", Reason: "queueFull"})
+			} else {
+				writeResult(BulkLineResult{Line: pending.line, ArticleId: pending.article.ArticleId, Status: "duplicate"})
+			}
+		}
+
+		batch = batch[:0]
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxBulkLineSize)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var body schemas.ExtractMetaDataHandlerBody
+		if err := json.Unmarshal([]byte(line), &body); err != nil || body == nil {
+			writeResult(BulkLineResult{Line: lineNumber, Status: "invalid", Reason: "malformed json"})
+			continue
+		}
+
+		// body validation
+		if validationError := schemas.ValidateInput(body); validationError != nil {
+			writeResult(BulkLineResult{Line: lineNumber, ArticleId: body.ArticleId, Status: "invalid", Reason: validationError.Error()})
+			continue
+		}
+
+		publicationDate, err := utils.ConvertStringToTimestamp(body.PublicationDate)
+		if err != nil {
+			writeResult(BulkLineResult{Line: lineNumber, ArticleId: body.ArticleId, Status: "invalid", Reason: err.Error()})
+			continue
+		}
+
+		batch = append(batch, pendingBulkLine{
+			line: lineNumber,
+			article: schemas.ArticleSchema{
+				ArticleId:       body.ArticleId,
+				Title:           body.Title,
+				Publisher:       body.Publisher,
+				PublicationDate: publicationDate,
+				Url:             body.Url,
+				Content:         body.Content,
+				Summary:         body.Summary,
+				Tags:            body.Tags,
+				ContentS3Path:   body.ContentS3Path,
+				Language:        body.Language,
+				Status:          "pending",
+			},
+		})
+
+		if len(batch) >= batchSize {
+			if err := flushBatch(); err != nil {
+				summary.Error = err.Error()
+				encoder.Encode(map[string]interface{}{"summary": summary})
+				return
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		summary.Error = err.Error()
+	}
+
+	if err := flushBatch(); err != nil {
+		summary.Error = err.Error()
+	}
+
+	encoder.Encode(map[string]interface{}{"summary": summary})
+}
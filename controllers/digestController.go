@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"time"
+)
+
+// GetLatestDigestHandler returns the latest digest of an edition, as JSON or rendered with ?format=html|text
+func GetLatestDigestHandler(w http.ResponseWriter, r *http.Request) {
+	edition := r.URL.Query().Get("edition")
+	if edition == "" {
+		edition = workers.DefaultDigestEdition
+	}
+
+	digest, err := schemas.GetLatestDigest(ctx, PostgresInstance.GetPostgresInstance(), edition)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if digest == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "digestNotFound", "no digest has been generated yet", nil)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(digest.Html))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(digest.Text))
+	default:
+		utils.SendSuccessResponse(w, http.StatusOK, "Digest fetched successfully", digest)
+	}
+}
+
+// GenerateDigestHandler generates a digest of the period ending now, outside of the schedule
+func GenerateDigestHandler(w http.ResponseWriter, r *http.Request) {
+	edition := r.URL.Query().Get("edition")
+	if edition == "" {
+		edition = workers.DefaultDigestEdition
+	}
+
+	digest, err := workers.GenerateDigest(ctx, edition, time.Now())
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Digest generated successfully", digest)
+}
@@ -0,0 +1,374 @@
+package controller
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/middlewares"
+	"service-news-app-backend/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type userPreferenceBody struct {
+	FollowedCategories []string `json:"followedCategories"`
+	FollowedEntities   []string `json:"followedEntities"`
+	FollowedPublishers []string `json:"followedPublishers"`
+	MutedTopics        []string `json:"mutedTopics"`
+}
+
+type userEventBody struct {
+	ArticleId    string `validate:"required,uuid" json:"articleId"`
+	EventType    string `validate:"required,oneof=view click dwell share" json:"eventType"`
+	DwellSeconds int    `validate:"min=0" json:"dwellSeconds"`
+}
+
+type userEventsBody struct {
+	Events []userEventBody `validate:"required,min=1,max=500,dive" json:"events"`
+}
+
+// FeedItem is a ranked article of the personalized feed
+type FeedItem struct {
+	Article         schemas.ArticleSchema `json:"article"`
+	Score           float64               `json:"score"`
+	PreferenceScore float64               `json:"preferenceScore"`
+	SimilarityScore float64               `json:"similarityScore"`
+	FreshnessScore  float64               `json:"freshnessScore"`
+	PopularityScore float64               `json:"popularityScore"`
+	ClusterId       int                   `json:"clusterId"` // -- articles sharing a cluster id cover the same story, -1 when not clustered
+}
+
+// GetUserPreferenceHandler returns the feed preferences of the authenticated user
+func GetUserPreferenceHandler(w http.ResponseWriter, r *http.Request) {
+	preference, err := schemas.GetUserPreference(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Preferences fetched successfully", preference)
+}
+
+// PutUserPreferenceHandler replaces the feed preferences of the authenticated user
+func PutUserPreferenceHandler(w http.ResponseWriter, r *http.Request) {
+	var body userPreferenceBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	err := schemas.UpsertUserPreference(ctx, PostgresInstance.GetPostgresInstance(), schemas.UserPreferenceSchema{
+		UserId:             middlewares.GetUserId(r),
+		FollowedCategories: utils.ConvertDuplicatesArrtoUniqueArr(body.FollowedCategories),
+		FollowedEntities:   utils.ConvertDuplicatesArrtoUniqueArr(body.FollowedEntities),
+		FollowedPublishers: utils.ConvertDuplicatesArrtoUniqueArr(body.FollowedPublishers),
+		MutedTopics:        utils.ConvertDuplicatesArrtoUniqueArr(body.MutedTopics),
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Preferences saved successfully", nil)
+}
+
+// TrackEventsHandler stores view, click, dwell and share events of the authenticated user
+func TrackEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var body userEventsBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	userId := middlewares.GetUserId(r)
+	events := make([]schemas.UserEventSchema, len(body.Events))
+	for i, event := range body.Events {
+		events[i] = schemas.UserEventSchema{
+			UserId:       userId,
+			ArticleId:    event.ArticleId,
+			EventType:    event.EventType,
+			DwellSeconds: event.DwellSeconds,
+		}
+	}
+
+	err := schemas.InsertUserEvents(ctx, PostgresInstance.GetPostgresInstance(), events)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Events tracked successfully", nil)
+}
+
+// GetFeedHandler ranks recent articles for the authenticated user by preference match,
+// similarity to their reading history, freshness and popularity, limiting how many
+// articles of the same story cluster appear together
+func GetFeedHandler(w http.ResponseWriter, r *http.Request) {
+	pool := PostgresInstance.GetPostgresInstance()
+	userId := middlewares.GetUserId(r)
+	now := time.Now()
+
+	limit, offset := parsePagination(r, 20, 100)
+
+	preference, err := schemas.GetUserPreference(ctx, pool, userId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	candidateHours := config.GetIntEnvironmentVariable("FEED_CANDIDATE_HOURS", 72)
+	candidates, err := schemas.GetRecentArticles(ctx, pool, now.Add(-time.Duration(candidateHours)*time.Hour), config.GetIntEnvironmentVariable("FEED_CANDIDATE_LIMIT", 500))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	// the reading history of the last 30 days builds the interest profile
+	interest, err := schemas.GetUserArticleInterest(ctx, pool, userId, now.AddDate(0, 0, -30), 50)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	historyIds := []string{}
+	for articleId := range interest {
+		historyIds = append(historyIds, articleId)
+	}
+	historyArticles, err := schemas.GetArticlesByIDs(ctx, pool, historyIds)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	historyEmbeddings := [][]float32{}
+	historyWeights := []float64{}
+	for _, article := range historyArticles {
+		historyEmbeddings = append(historyEmbeddings, article.Embedding)
+		historyWeights = append(historyWeights, interest[article.ArticleId])
+	}
+	profile := utils.WeightedAverageVector(historyEmbeddings, historyWeights)
+
+	candidateIds := make([]string, len(candidates))
+	for i, article := range candidates {
+		candidateIds[i] = article.ArticleId
+	}
+	popularity, err := schemas.GetArticlePopularity(ctx, pool, candidateIds, now.Add(-24*time.Hour))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	items := scoreFeedCandidates(candidates, *preference, profile, interest, popularity, now)
+	items = diversifyFeed(items, config.GetIntEnvironmentVariable("FEED_MAX_PER_CLUSTER", 2))
+
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Feed fetched successfully", map[string]interface{}{
+		"items":  items[offset:end],
+		"total":  len(items),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+func scoreFeedCandidates(candidates []schemas.ArticleSchema, preference schemas.UserPreferenceSchema, profile []float32,
+	interest map[string]float64, popularity map[string]float64, now time.Time) []FeedItem {
+
+	preferenceWeight := float64(config.GetIntEnvironmentVariable("FEED_PREFERENCE_WEIGHT", 35)) / 100
+	similarityWeight := float64(config.GetIntEnvironmentVariable("FEED_SIMILARITY_WEIGHT", 30)) / 100
+	freshnessWeight := float64(config.GetIntEnvironmentVariable("FEED_FRESHNESS_WEIGHT", 20)) / 100
+	popularityWeight := float64(config.GetIntEnvironmentVariable("FEED_POPULARITY_WEIGHT", 15)) / 100
+	halfLifeHours := float64(config.GetPositiveIntEnvironmentVariable("FEED_FRESHNESS_HALF_LIFE_HOURS", 12))
+
+	followedCategories := lowercaseSet(preference.FollowedCategories)
+	followedEntities := lowercaseSet(preference.FollowedEntities)
+	followedPublishers := lowercaseSet(preference.FollowedPublishers)
+	mutedTopics := lowercaseSet(preference.MutedTopics)
+
+	maxPopularity := 0.0
+	for _, value := range popularity {
+		maxPopularity = math.Max(maxPopularity, value)
+	}
+
+	items := []FeedItem{}
+	for _, article := range candidates {
+		// already read articles and muted topics are left out
+		if _, seen := interest[article.ArticleId]; seen {
+			continue
+		}
+		if matchesAny(mutedTopics, article.Categories) || matchesAny(mutedTopics, article.Tags) {
+			continue
+		}
+
+		preferenceScore := 0.0
+		if matchesAny(followedCategories, article.Categories) || matchesAny(followedCategories, article.Tags) {
+			preferenceScore += 1.0 / 3
+		}
+		if followedPublishers[strings.ToLower(article.Publisher)] {
+			preferenceScore += 1.0 / 3
+		}
+		if matchesAny(followedEntities, article.EntityNames()) {
+			preferenceScore += 1.0 / 3
+		}
+
+		similarityScore := math.Max(0, utils.CosineSimilarity(profile, article.Embedding))
+		freshnessScore := utils.FreshnessScore(now.Sub(article.PublicationDate).Hours(), halfLifeHours)
+
+		popularityScore := 0.0
+		if maxPopularity > 0 {
+			popularityScore = math.Log1p(popularity[article.ArticleId]) / math.Log1p(maxPopularity)
+		}
+
+		items = append(items, FeedItem{
+			Article:         article,
+			PreferenceScore: preferenceScore,
+			SimilarityScore: similarityScore,
+			FreshnessScore:  freshnessScore,
+			PopularityScore: popularityScore,
+			Score: preferenceWeight*preferenceScore + similarityWeight*similarityScore +
+				freshnessWeight*freshnessScore + popularityWeight*popularityScore,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Score > items[j].Score
+	})
+
+	return items
+}
+
+// diversifyFeed groups the top ranked items (FEED_DIVERSIFY_POOL_SIZE, default 100) into story clusters and
+// moves every item beyond maxPerCluster of its cluster to the end of the feed, keeping the ranking order otherwise.
+// Clustering compares every item with every cluster, the lower ranked items are left as they are.
+func diversifyFeed(items []FeedItem, maxPerCluster int) []FeedItem {
+	clusterThreshold := float64(config.GetIntEnvironmentVariable("FEED_CLUSTER_SIMILARITY_PERCENT", 88)) / 100
+
+	poolSize := config.GetPositiveIntEnvironmentVariable("FEED_DIVERSIFY_POOL_SIZE", 100)
+	unclustered := []FeedItem{}
+	if len(items) > poolSize {
+		items, unclustered = items[:poolSize], items[poolSize:]
+	}
+	for i := range unclustered {
+		unclustered[i].ClusterId = -1
+	}
+
+	representatives := []schemas.ArticleSchema{}
+	clusterCounts := []int{}
+
+	selected := []FeedItem{}
+	overflow := []FeedItem{}
+
+	for _, item := range items {
+		clusterId := -1
+		for i, representative := range representatives {
+			if isSameStory(item.Article, representative, clusterThreshold) {
+				clusterId = i
+				break
+			}
+		}
+		if clusterId == -1 {
+			clusterId = len(representatives)
+			representatives = append(representatives, item.Article)
+			clusterCounts = append(clusterCounts, 0)
+		}
+
+		item.ClusterId = clusterId
+		clusterCounts[clusterId]++
+
+		if clusterCounts[clusterId] > maxPerCluster {
+			overflow = append(overflow, item)
+		} else {
+			selected = append(selected, item)
+		}
+	}
+
+	selected = append(selected, unclustered...)
+	return append(selected, overflow...)
+}
+
+// isSameStory compares embeddings when both articles have one, otherwise the overlap of their title words
+func isSameStory(a schemas.ArticleSchema, b schemas.ArticleSchema, threshold float64) bool {
+	if len(a.Embedding) > 0 && len(b.Embedding) > 0 {
+		return utils.CosineSimilarity(a.Embedding, b.Embedding) >= threshold
+	}
+
+	aWords := lowercaseSet(strings.Fields(a.Title))
+	bWords := lowercaseSet(strings.Fields(b.Title))
+	if len(aWords) == 0 || len(bWords) == 0 {
+		return false
+	}
+
+	shared := 0
+	for word := range aWords {
+		if bWords[word] {
+			shared++
+		}
+	}
+	return float64(shared)/float64(len(aWords)+len(bWords)-shared) >= 0.5
+}
+
+func lowercaseSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[strings.ToLower(strings.TrimSpace(value))] = true
+	}
+	return set
+}
+
+func matchesAny(set map[string]bool, values []string) bool {
+	for _, value := range values {
+		if set[strings.ToLower(strings.TrimSpace(value))] {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePagination reads the limit and offset query parameters
+func parsePagination(r *http.Request, defaultLimit int, maxLimit int) (int, int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+// parseIntOrDefault parses a query parameter, falling back to defaultValue when it is missing or out of [min, max]
+func parseIntOrDefault(value string, defaultValue int, min int, max int) int {
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < min || parsed > max {
+		return defaultValue
+	}
+	return parsed
+}
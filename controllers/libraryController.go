@@ -0,0 +1,428 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/middlewares"
+	"service-news-app-backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+type articleStateBody struct {
+	Bookmarked *bool `json:"bookmarked"`
+	Read       *bool `json:"read"`
+	Progress   *int  `validate:"omitempty,min=0,max=100" json:"progress"`
+}
+
+type collectionBody struct {
+	Name string `validate:"required,max=100" json:"name"`
+}
+
+// PutArticleStateHandler bookmarks, marks as read or stores the reading progress of an article for the authenticated user.
+// Fields left out keep their value.
+func PutArticleStateHandler(w http.ResponseWriter, r *http.Request) {
+	var body articleStateBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	articleId := chi.URLParam(r, "id")
+	pool := PostgresInstance.GetPostgresInstance()
+
+	article, err := schemas.GetArticleByID(ctx, pool, articleId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if article == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "articleNotFound", "article not found", nil)
+		return
+	}
+
+	state, err := schemas.UpsertUserArticleState(ctx, pool, middlewares.GetUserId(r), articleId, schemas.UserArticleStateChange{
+		Bookmarked: body.Bookmarked,
+		Read:       body.Read,
+		Progress:   body.Progress,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Article state updated successfully", state)
+}
+
+// GetArticleStatesHandler lists the article states of the authenticated user, filtered by bookmarked, read and updated_since (or cursor)
+func GetArticleStatesHandler(w http.ResponseWriter, r *http.Request) {
+	after, ok := parseSyncCursor(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(r, 100, 1000)
+
+	states, err := schemas.GetUserArticleStates(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), schemas.UserArticleStateFilter{
+		After:      after,
+		Bookmarked: parseBoolParam(r, "bookmarked"),
+		Read:       parseBoolParam(r, "read"),
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Article states fetched successfully", states)
+}
+
+// GetBookmarksHandler returns the read-later list of the authenticated user, ?unread=true leaves out what was read
+func GetBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r, 20, 100)
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	articles, err := schemas.GetBookmarkedArticles(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), unreadOnly, limit, offset)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Bookmarks fetched successfully", articles)
+}
+
+// GetCollectionsHandler lists the collections of the authenticated user, with tombstones when updated_since (or cursor) is given
+func GetCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	after, ok := parseSyncCursor(w, r)
+	if !ok {
+		return
+	}
+
+	collections, err := schemas.GetCollections(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), after)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Collections fetched successfully", collections)
+}
+
+// CreateCollectionHandler creates a collection for the authenticated user
+func CreateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var body collectionBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	collection, err := schemas.InsertCollection(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), body.Name)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Collection created successfully", collection)
+}
+
+// RenameCollectionHandler renames a collection of the authenticated user
+func RenameCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionId, ok := parseCollectionId(w, r)
+	if !ok {
+		return
+	}
+
+	var body collectionBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	collection, err := schemas.RenameCollection(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), collectionId, body.Name)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if collection == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "collectionNotFound", "collection not found", nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Collection updated successfully", collection)
+}
+
+// DeleteCollectionHandler deletes a collection of the authenticated user with its items
+func DeleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionId, ok := parseCollectionId(w, r)
+	if !ok {
+		return
+	}
+
+	collection, err := schemas.DeleteCollection(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), collectionId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if collection == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "collectionNotFound", "collection not found", nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Collection deleted successfully", nil)
+}
+
+// GetCollectionItemsHandler lists the articles of a collection of the authenticated user, with removals when updated_since (or cursor) is given
+func GetCollectionItemsHandler(w http.ResponseWriter, r *http.Request) {
+	collectionId, ok := parseCollectionId(w, r)
+	if !ok {
+		return
+	}
+
+	after, ok := parseSyncCursor(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(r, 100, 1000)
+
+	items, err := schemas.GetCollectionItems(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), &collectionId, after, limit, offset)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Collection items fetched successfully", items)
+}
+
+// AddCollectionItemHandler saves an article in a collection of the authenticated user
+func AddCollectionItemHandler(w http.ResponseWriter, r *http.Request) {
+	collectionId, ok := parseCollectionId(w, r)
+	if !ok {
+		return
+	}
+
+	articleId := chi.URLParam(r, "id")
+	userId := middlewares.GetUserId(r)
+	pool := PostgresInstance.GetPostgresInstance()
+
+	collection, err := schemas.GetLiveCollection(ctx, pool, userId, collectionId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if collection == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "collectionNotFound", "collection not found", nil)
+		return
+	}
+
+	article, err := schemas.GetArticleByID(ctx, pool, articleId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if article == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "articleNotFound", "article not found", nil)
+		return
+	}
+
+	item, err := schemas.AddCollectionItem(ctx, pool, userId, collectionId, articleId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Article added to collection successfully", item)
+}
+
+// RemoveCollectionItemHandler takes an article out of a collection of the authenticated user
+func RemoveCollectionItemHandler(w http.ResponseWriter, r *http.Request) {
+	collectionId, ok := parseCollectionId(w, r)
+	if !ok {
+		return
+	}
+
+	item, err := schemas.RemoveCollectionItem(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), collectionId, chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if item == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "collectionItemNotFound", "article is not in the collection", nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Article removed from collection successfully", nil)
+}
+
+// SyncLibraryHandler returns everything that changed in the library of the authenticated user after the
+// cursor (or since updated_since for the first sync): article states, collections and collection items, deletions
+// included. Clients pass the returned cursor to the next call, right away while hasMore is true.
+func SyncLibraryHandler(w http.ResponseWriter, r *http.Request) {
+	after, ok := parseSyncCursor(w, r)
+	if !ok {
+		return
+	}
+
+	userId := middlewares.GetUserId(r)
+	pool := PostgresInstance.GetPostgresInstance()
+	limit := 1000
+
+	states, err := schemas.GetUserArticleStates(ctx, pool, userId, schemas.UserArticleStateFilter{After: after, Limit: limit})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	collections, err := schemas.GetCollections(ctx, pool, userId, after)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	items, err := schemas.GetCollectionItems(ctx, pool, userId, nil, after, limit, 0)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	// a truncated list caps the cursor at its last change, so nothing after it is skipped.
+	// Changes of the other lists past the cap come again on the next call.
+	hasMore := len(states) == limit || len(items) == limit
+	var next *schemas.SyncCursor
+	var capAt *schemas.SyncCursor
+	advance := func(cursor schemas.SyncCursor) {
+		if next == nil || next.Before(cursor) {
+			next = &cursor
+		}
+	}
+	capTo := func(cursor schemas.SyncCursor) {
+		if capAt == nil || cursor.Before(*capAt) {
+			capAt = &cursor
+		}
+	}
+
+	for _, state := range states {
+		advance(state.SyncCursor())
+	}
+	for _, collection := range collections {
+		advance(collection.SyncCursor())
+	}
+	for _, item := range items {
+		advance(item.SyncCursor())
+	}
+	if len(states) == limit {
+		capTo(states[len(states)-1].SyncCursor())
+	}
+	if len(items) == limit {
+		capTo(items[len(items)-1].SyncCursor())
+	}
+	if capAt != nil {
+		next = capAt
+	}
+	if next == nil {
+		next = after
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Library synced successfully", map[string]interface{}{
+		"articleStates":   states,
+		"collections":     collections,
+		"collectionItems": items,
+		"cursor":          encodeSyncCursor(next),
+		"hasMore":         hasMore,
+	})
+}
+
+// parseSyncCursor reads the optional sync position: the cursor returned by a previous sync, or an updated_since
+// timestamp. It writes the error response itself and returns false when the value is invalid.
+func parseSyncCursor(w http.ResponseWriter, r *http.Request) (*schemas.SyncCursor, bool) {
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(value)
+		parts := strings.SplitN(string(decoded), "|", 2)
+		if err != nil || len(parts) != 2 {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "invalidCursor", "cursor must be returned by a previous sync", nil)
+			return nil, false
+		}
+
+		updatedAt, err := time.Parse(time.RFC3339Nano, parts[0])
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "invalidCursor", "cursor must be returned by a previous sync", nil)
+			return nil, false
+		}
+
+		return &schemas.SyncCursor{UpdatedAt: updatedAt, Key: parts[1]}, true
+	}
+
+	value := r.URL.Query().Get("updated_since")
+	if value == "" {
+		return nil, true
+	}
+
+	updatedSince, err := utils.ConvertStringToTimestamp(value)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidUpdatedSince", "updated_since must be an RFC3339 timestamp", nil)
+		return nil, false
+	}
+
+	// the empty key comes before every row changed at that time
+	return &schemas.SyncCursor{UpdatedAt: updatedSince}, true
+}
+
+// encodeSyncCursor turns a sync position into the opaque cursor given to clients, empty when there is none
+func encodeSyncCursor(cursor *schemas.SyncCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor.UpdatedAt.Format(time.RFC3339Nano) + "|" + cursor.Key))
+}
+
+// parseCollectionId reads the collection id of the URL, it writes the error response itself and returns false when it is invalid
+func parseCollectionId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	collectionId, err := strconv.ParseInt(chi.URLParam(r, "collectionId"), 10, 64)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidCollectionId", "collection id must be a number", nil)
+		return 0, false
+	}
+	return collectionId, true
+}
+
+// parseBoolParam reads an optional true/false query parameter, nil when missing or invalid
+func parseBoolParam(r *http.Request, name string) *bool {
+	value, err := strconv.ParseBool(r.URL.Query().Get(name))
+	if err != nil {
+		return nil
+	}
+	return &value
+}
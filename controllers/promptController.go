@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+type promptVersionBody struct {
+	Version       string   `validate:"required,max=50" json:"version"`
+	System        string   `validate:"required" json:"system"`
+	User          string   `validate:"required" json:"user"`
+	Model         string   `json:"model"`
+	Temperature   *float64 `validate:"omitempty,min=0,max=2" json:"temperature"`
+	TrafficWeight int      `validate:"min=0" json:"trafficWeight"`
+}
+
+type promptTrafficBody struct {
+	TrafficWeight *int `validate:"required,min=0" json:"trafficWeight"`
+}
+
+// GetPromptsHandler lists every prompt with its versions and their traffic weights
+func GetPromptsHandler(w http.ResponseWriter, r *http.Request) {
+	prompts := map[string][]utils.PromptVersion{}
+	for _, name := range utils.GetPromptNames() {
+		prompts[name] = utils.GetPromptVersions(name)
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Prompts fetched successfully", prompts)
+}
+
+// CreatePromptVersionHandler adds a version to a prompt. It starts receiving its share of the traffic right away
+// on this instance, and on the others at their next registry refresh.
+func CreatePromptVersionHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !utils.Includes(utils.GetPromptNames(), name) {
+		utils.SendErrorResponse(w, http.StatusNotFound, "promptNotFound", "prompt not found", nil)
+		return
+	}
+
+	var body promptVersionBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	if err := utils.ValidatePromptTemplates(body.System, body.User); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidPromptTemplate", err.Error(), nil)
+		return
+	}
+
+	if _, exists := utils.GetPromptVersion(name, body.Version); exists {
+		utils.SendErrorResponse(w, http.StatusConflict, "promptVersionExists", "prompt version already exists, versions can't be changed", nil)
+		return
+	}
+
+	promptVersion := schemas.PromptVersionSchema{
+		Name:          name,
+		Version:       body.Version,
+		System:        body.System,
+		User:          body.User,
+		Model:         body.Model,
+		Temperature:   utils.DefaultLLMTemperature,
+		TrafficWeight: body.TrafficWeight,
+	}
+	if promptVersion.Model == "" {
+		promptVersion.Model = utils.DefaultLLMModel
+	}
+	if body.Temperature != nil {
+		promptVersion.Temperature = *body.Temperature
+	}
+
+	storedVersion, err := schemas.InsertPromptVersion(ctx, PostgresInstance.GetPostgresInstance(), promptVersion)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if storedVersion == nil {
+		utils.SendErrorResponse(w, http.StatusConflict, "promptVersionExists", "prompt version already exists, versions can't be changed", nil)
+		return
+	}
+
+	if err := workers.LoadPromptRegistry(ctx); err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Prompt version created successfully", storedVersion)
+}
+
+// UpdatePromptTrafficHandler changes the traffic weight of a prompt version, 0 takes it out of rotation
+func UpdatePromptTrafficHandler(w http.ResponseWriter, r *http.Request) {
+	promptVersion, ok := utils.GetPromptVersion(chi.URLParam(r, "name"), chi.URLParam(r, "version"))
+	if !ok {
+		utils.SendErrorResponse(w, http.StatusNotFound, "promptVersionNotFound", "prompt version not found", nil)
+		return
+	}
+
+	var body promptTrafficBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	storedVersion, err := schemas.SetPromptVersionTraffic(ctx, PostgresInstance.GetPostgresInstance(), schemas.PromptVersionSchema{
+		Name:          promptVersion.Name,
+		Version:       promptVersion.Version,
+		System:        promptVersion.System,
+		User:          promptVersion.User,
+		Model:         promptVersion.Model,
+		Temperature:   promptVersion.Temperature,
+		TrafficWeight: *body.TrafficWeight,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	if err := workers.LoadPromptRegistry(ctx); err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Prompt version traffic updated successfully", storedVersion)
+}
+
+// GetPromptStatsHandler compares the output of the versions of a prompt over the last ?days (default 30):
+// confidence, importance, categories, summary length, sentiment mix and how often editors corrected it
+func GetPromptStatsHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !utils.Includes(utils.GetPromptNames(), name) {
+		utils.SendErrorResponse(w, http.StatusNotFound, "promptNotFound", "prompt not found", nil)
+		return
+	}
+
+	days := parseIntOrDefault(r.URL.Query().Get("days"), 30, 1, 365)
+	since := time.Now().AddDate(0, 0, -days)
+
+	stats, err := schemas.GetPromptVersionStats(ctx, PostgresInstance.GetPostgresInstance(), name, since)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Prompt stats fetched successfully", map[string]interface{}{
+		"prompt":   name,
+		"since":    since,
+		"versions": stats,
+	})
+}
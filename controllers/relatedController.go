@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// similarity above which two articles are considered the same piece (syndicated copies, re-posts)
+const duplicateSimilarity = 0.97
+
+// RelatedArticle is an article recommended next to another one
+type RelatedArticle struct {
+	Article          schemas.ArticleSchema `json:"article"`
+	Score            float64               `json:"score"`
+	SimilarityScore  float64               `json:"similarityScore"`
+	SharedEntities   []string              `json:"sharedEntities"`
+	SharedCategories []string              `json:"sharedCategories"`
+}
+
+// GetRelatedArticlesHandler returns "more like this" articles combining embedding nearest neighbours,
+// shared entities and shared categories.
+// Query parameters: limit, excludeSameStory (true/false) and halfLifeHours (recency decay, 0 disables it).
+func GetRelatedArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	pool := PostgresInstance.GetPostgresInstance()
+	articleId := chi.URLParam(r, "id")
+
+	limit, _ := parsePagination(r, 10, 50)
+	excludeSameStory := r.URL.Query().Get("excludeSameStory") == "true"
+
+	halfLifeHours, err := strconv.Atoi(r.URL.Query().Get("halfLifeHours"))
+	if err != nil || halfLifeHours < 0 {
+		halfLifeHours = config.GetIntEnvironmentVariable("RELATED_HALF_LIFE_HOURS", 72)
+	}
+
+	cacheKey := fmt.Sprintf("related:%d:%s:%d:%t:%d", utils.GetCacheGeneration(ctx, utils.RelatedArticlesCacheNamespace),
+		articleId, limit, excludeSameStory, halfLifeHours)
+
+	related := []RelatedArticle{}
+	if utils.GetCachedJSON(ctx, cacheKey, &related) {
+		utils.SendSuccessResponse(w, http.StatusOK, "Related articles fetched successfully", related)
+		return
+	}
+
+	articles, err := schemas.GetArticlesByIDs(ctx, pool, []string{articleId})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if len(articles) == 0 {
+		utils.SendErrorResponse(w, http.StatusNotFound, "articleNotFound", "article not found", nil)
+		return
+	}
+	article := articles[0]
+
+	candidateLimit := config.GetIntEnvironmentVariable("RELATED_CANDIDATE_LIMIT", 100)
+	candidates := map[string]schemas.ArticleSchema{}
+
+	if len(article.Embedding) > 0 {
+		nearest, err := schemas.GetNearestArticles(ctx, pool, article.Embedding, articleId, candidateLimit)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+			return
+		}
+		for _, candidate := range nearest {
+			candidates[candidate.ArticleId] = candidate
+		}
+	}
+
+	sharingTopics, err := schemas.GetArticlesSharingTopics(ctx, pool, article.Categories, article.EntityNames(), articleId,
+		time.Now().AddDate(0, 0, -config.GetIntEnvironmentVariable("RELATED_CANDIDATE_DAYS", 30)), candidateLimit)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	for _, candidate := range sharingTopics {
+		candidates[candidate.ArticleId] = candidate
+	}
+
+	related = rankRelatedArticles(article, candidates, excludeSameStory, float64(halfLifeHours))
+	if len(related) > limit {
+		related = related[:limit]
+	}
+
+	utils.SetCachedJSON(ctx, cacheKey, related, time.Duration(config.GetIntEnvironmentVariable("RELATED_CACHE_TTL_MINUTES", 60))*time.Minute)
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Related articles fetched successfully", related)
+}
+
+func rankRelatedArticles(article schemas.ArticleSchema, candidates map[string]schemas.ArticleSchema, excludeSameStory bool, halfLifeHours float64) []RelatedArticle {
+	similarityWeight := float64(config.GetIntEnvironmentVariable("RELATED_SIMILARITY_WEIGHT", 60)) / 100
+	entityWeight := float64(config.GetIntEnvironmentVariable("RELATED_ENTITY_WEIGHT", 25)) / 100
+	categoryWeight := float64(config.GetIntEnvironmentVariable("RELATED_CATEGORY_WEIGHT", 15)) / 100
+	sameStoryThreshold := float64(config.GetIntEnvironmentVariable("FEED_CLUSTER_SIMILARITY_PERCENT", 88)) / 100
+
+	articleEntities := article.EntityNames()
+	now := time.Now()
+
+	related := []RelatedArticle{}
+	for _, candidate := range candidates {
+		similarityScore := utils.CosineSimilarity(article.Embedding, candidate.Embedding)
+
+		// copies of the same piece are never recommended
+		if similarityScore >= duplicateSimilarity || candidate.Url == article.Url ||
+			strings.EqualFold(strings.TrimSpace(candidate.Title), strings.TrimSpace(article.Title)) {
+			continue
+		}
+		if excludeSameStory && isSameStory(article, candidate, sameStoryThreshold) {
+			continue
+		}
+
+		sharedEntities := sharedValues(articleEntities, candidate.EntityNames())
+		sharedCategories := sharedValues(article.Categories, candidate.Categories)
+
+		score := similarityWeight*similarityScore +
+			entityWeight*overlapRatio(len(sharedEntities), len(articleEntities)) +
+			categoryWeight*overlapRatio(len(sharedCategories), len(article.Categories))
+
+		if halfLifeHours > 0 {
+			score *= utils.FreshnessScore(now.Sub(candidate.PublicationDate).Hours(), halfLifeHours)
+		}
+
+		related = append(related, RelatedArticle{
+			Article:          candidate,
+			Score:            score,
+			SimilarityScore:  similarityScore,
+			SharedEntities:   sharedEntities,
+			SharedCategories: sharedCategories,
+		})
+	}
+
+	sort.SliceStable(related, func(i, j int) bool {
+		return related[i].Score > related[j].Score
+	})
+
+	return related
+}
+
+// sharedValues returns the values of a also present in b, compared case-insensitively
+func sharedValues(a []string, b []string) []string {
+	bSet := lowercaseSet(b)
+
+	shared := []string{}
+	for _, value := range utils.ConvertDuplicatesArrtoUniqueArr(a) {
+		if bSet[strings.ToLower(strings.TrimSpace(value))] {
+			shared = append(shared, value)
+		}
+	}
+	return shared
+}
+
+func overlapRatio(shared int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(shared) / float64(total)
+}
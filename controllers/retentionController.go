@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/middlewares"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// DeleteArticleHandler soft deletes an article on behalf of an editor, recorded as the actor in the audit trail.
+// It is purged by the retention job after the grace period.
+func DeleteArticleHandler(w http.ResponseWriter, r *http.Request) {
+	articleId := chi.URLParam(r, "id")
+	pool := PostgresInstance.GetPostgresInstance()
+
+	article, err := schemas.SoftDeleteArticle(ctx, pool, articleId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if article == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "articleNotFound", "article not found", nil)
+		return
+	}
+
+	err = schemas.InsertPurgeAudit(ctx, pool, schemas.PurgeAuditSchema{
+		ArticleId: article.ArticleId,
+		Publisher: article.Publisher,
+		Action:    "soft_delete",
+		Actor:     middlewares.GetUserId(r),
+		Reason:    r.URL.Query().Get("reason"),
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.BumpCacheGeneration(ctx, utils.RelatedArticlesCacheNamespace)
+	go workers.EmitWebhookEvent(ctx, "article.deleted", *article)
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Article deleted successfully", nil)
+}
+
+// PurgeArticleHandler permanently removes an article and everything derived from it (takedown requests)
+func PurgeArticleHandler(w http.ResponseWriter, r *http.Request) {
+	articleId := chi.URLParam(r, "id")
+
+	article, err := workers.PurgeArticle(ctx, articleId, "admin", r.URL.Query().Get("reason"))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if article == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "articleNotFound", "article not found", nil)
+		return
+	}
+
+	// soft deleted articles were already announced
+	if article.DeletedAt == nil {
+		go workers.EmitWebhookEvent(ctx, "article.deleted", *article)
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Article purged successfully", nil)
+}
+
+type retentionPolicyBody struct {
+	ContentRetentionDays *int `json:"contentRetentionDays" validate:"omitempty,min=1"`
+	ArticleRetentionDays *int `json:"articleRetentionDays" validate:"omitempty,min=1"`
+}
+
+// GetRetentionPoliciesHandler lists the retention policies
+func GetRetentionPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	policies, err := schemas.GetRetentionPolicies(ctx, PostgresInstance.GetPostgresInstance())
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Retention policies fetched successfully", policies)
+}
+
+// PutRetentionPolicyHandler creates or replaces the retention policy of a publisher ("*" for the default policy)
+func PutRetentionPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	var body retentionPolicyBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	policy, err := schemas.UpsertRetentionPolicy(ctx, PostgresInstance.GetPostgresInstance(), schemas.RetentionPolicySchema{
+		Publisher:            chi.URLParam(r, "publisher"),
+		ContentRetentionDays: body.ContentRetentionDays,
+		ArticleRetentionDays: body.ArticleRetentionDays,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Retention policy saved successfully", policy)
+}
+
+// DeleteRetentionPolicyHandler removes the retention policy of a publisher
+func DeleteRetentionPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	err := schemas.DeleteRetentionPolicy(ctx, PostgresInstance.GetPostgresInstance(), chi.URLParam(r, "publisher"))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Retention policy deleted successfully", nil)
+}
+
+// GetPurgeAuditHandler returns the deletion audit trail, optionally filtered by ?articleId=
+func GetPurgeAuditHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	audits, err := schemas.GetPurgeAudits(ctx, PostgresInstance.GetPostgresInstance(), r.URL.Query().Get("articleId"), limit)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Purge audit fetched successfully", audits)
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/middlewares"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+type articleMetadataBody struct {
+	Summary        *string         `validate:"omitempty,min=1" json:"summary"`
+	Categories     *[]string       `validate:"omitempty,min=1,dive,required" json:"categories"`
+	Entities       *utils.Entities `json:"entities"`
+	SentimentScore *string         `validate:"omitempty,oneof=Positive Negative Neutral" json:"sentimentScore"`
+	Unlock         []string        `validate:"omitempty,dive,oneof=summary categories entities sentimentScore" json:"unlock"`
+}
+
+// GetReviewQueueHandler lists the articles whose enrichment confidence is too low to go unchecked, least confident first
+func GetReviewQueueHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r, 20, 100)
+
+	articles, err := schemas.GetReviewQueue(ctx, PostgresInstance.GetPostgresInstance(), limit, offset)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Review queue fetched successfully", articles)
+}
+
+// PatchArticleMetadataHandler lets an editor override the summary, categories, entities or sentiment of an article.
+// Overridden fields are locked against re-enrichment until they are listed in unlock.
+func PatchArticleMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	var body articleMetadataBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	if body.Summary == nil && body.Categories == nil && body.Entities == nil && body.SentimentScore == nil && len(body.Unlock) == 0 {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", "nothing to change, use the approve endpoint to confirm the metadata as is", nil)
+		return
+	}
+
+	override := schemas.ArticleMetadataOverride{
+		Summary:        body.Summary,
+		Categories:     body.Categories,
+		SentimentScore: body.SentimentScore,
+		Unlock:         body.Unlock,
+	}
+	if body.Entities != nil {
+		override.Entities = utils.Entities{
+			Organizations: nonNilList(body.Entities.Organizations),
+			Locations:     nonNilList(body.Entities.Locations),
+			Individuals:   nonNilList(body.Entities.Individuals),
+		}
+	}
+
+	writeArticleMetadata(w, r, override, "Article metadata updated successfully")
+}
+
+// ApproveArticleMetadataHandler confirms the generated metadata of an article as is and takes it out of the review queue
+func ApproveArticleMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	writeArticleMetadata(w, r, schemas.ArticleMetadataOverride{}, "Article metadata approved successfully")
+}
+
+// writeArticleMetadata stores the verdict of the editor on the article of the URL and notifies subscribers of the change
+func writeArticleMetadata(w http.ResponseWriter, r *http.Request, override schemas.ArticleMetadataOverride, message string) {
+	// optional optimistic concurrency check
+	expectedVersion, err := parseIfMatchVersion(r)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidIfMatchHeader", err.Error(), nil)
+		return
+	}
+
+	article, err := schemas.OverrideArticleMetadata(ctx, PostgresInstance.GetPostgresInstance(), chi.URLParam(r, "id"), middlewares.GetUserId(r), override, expectedVersion)
+	if err != nil {
+		if errors.Is(err, schemas.ErrArticleVersionConflict) {
+			utils.SendErrorResponse(w, http.StatusPreconditionFailed, "versionConflict", err.Error(), nil)
+			return
+		}
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if article == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "articleNotFound", "article not found", nil)
+		return
+	}
+
+	// categories and entities feed related articles, feeds and saved searches
+	utils.BumpCacheGeneration(ctx, utils.RelatedArticlesCacheNamespace)
+	go workers.EmitWebhookEvent(ctx, "article.updated", *article)
+	go workers.PercolateSavedSearches(ctx, *article)
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, article.Version))
+
+	utils.SendSuccessResponse(w, http.StatusOK, message, article)
+}
+
+// GetMetadataCorrectionsHandler exports the corrections made by editors as labeled data, filtered by field, kind and since.
+// format=jsonl streams one correction per line for evaluation datasets.
+func GetMetadataCorrectionsHandler(w http.ResponseWriter, r *http.Request) {
+	var since *time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := parseDateParam(value)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "invalidSince", "since must be a date (YYYY-MM-DD) or an RFC3339 timestamp", nil)
+			return
+		}
+		since = &parsed
+	}
+
+	field := r.URL.Query().Get("field")
+	if field != "" && !utils.Includes(schemas.MetadataFields, field) {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidField", "field must be one of summary, categories, entities, sentimentScore", nil)
+		return
+	}
+
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && kind != "override" && kind != "confirmation" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidKind", "kind must be override or confirmation", nil)
+		return
+	}
+
+	limit, offset := parsePagination(r, 100, 5000)
+
+	corrections, err := schemas.GetMetadataCorrections(ctx, PostgresInstance.GetPostgresInstance(), schemas.MetadataCorrectionFilter{
+		Field:  field,
+		Kind:   kind,
+		Since:  since,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		for _, correction := range corrections {
+			encoder.Encode(correction)
+		}
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Metadata corrections fetched successfully", corrections)
+}
+
+func nonNilList(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/middlewares"
+	"service-news-app-backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+type savedSearchBody struct {
+	Name       string   `validate:"required,max=200" json:"name"`
+	Query      string   `validate:"required,max=500" json:"query"`
+	Publishers []string `json:"publishers"`
+	Categories []string `json:"categories"`
+	Sentiments []string `json:"sentiments"`
+	Languages  []string `validate:"dive,len=2" json:"languages"`
+	Channels   []string `validate:"dive,oneof=webhook email" json:"channels"`
+	WebhookUrl string   `validate:"omitempty,url" json:"webhookUrl"`
+	Email      string   `validate:"omitempty,email" json:"email"`
+}
+
+// CreateSavedSearchHandler saves a search of the authenticated user, new articles matching it are recorded as they are published
+func CreateSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var body savedSearchBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	// every channel needs its address, emails only go to the user's own verified address
+	for _, channel := range body.Channels {
+		if channel == "webhook" && body.WebhookUrl == "" {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", "missing address for channel "+channel, nil)
+			return
+		}
+		if channel == "email" {
+			verifiedEmail := middlewares.GetVerifiedEmail(r)
+			if verifiedEmail == "" {
+				utils.SendErrorResponse(w, http.StatusBadRequest, "emailNotVerified", "verify your email address to get saved search emails", nil)
+				return
+			}
+			if body.Email != "" && !strings.EqualFold(body.Email, verifiedEmail) {
+				utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", "email must be your verified email address", nil)
+				return
+			}
+			body.Email = verifiedEmail
+		}
+	}
+	if !utils.Includes(body.Channels, "email") {
+		body.Email = ""
+	}
+
+	// the server posts to the webhook, it must not reach internal addresses
+	if body.WebhookUrl != "" {
+		if err := utils.ValidatePublicURL(r.Context(), body.WebhookUrl); err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "invalidWebhookUrl", err.Error(), nil)
+			return
+		}
+	}
+
+	search, err := schemas.InsertSavedSearch(ctx, PostgresInstance.GetPostgresInstance(), schemas.SavedSearchSchema{
+		UserId:     middlewares.GetUserId(r),
+		Name:       body.Name,
+		Query:      strings.TrimSpace(body.Query),
+		Publishers: lowercaseList(body.Publishers),
+		Categories: lowercaseList(body.Categories),
+		Sentiments: lowercaseList(body.Sentiments),
+		Languages:  lowercaseList(body.Languages),
+		Channels:   utils.ConvertDuplicatesArrtoUniqueArr(body.Channels),
+		WebhookUrl: body.WebhookUrl,
+		Email:      body.Email,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Saved search created successfully", search)
+}
+
+// GetSavedSearchesHandler lists the saved searches of the authenticated user
+func GetSavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	searches, err := schemas.GetSavedSearchesByUser(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Saved searches fetched successfully", searches)
+}
+
+// DeleteSavedSearchHandler removes a saved search of the authenticated user
+func DeleteSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	searchId, err := strconv.ParseInt(chi.URLParam(r, "searchId"), 10, 64)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidSearchId", "search id must be a number", nil)
+		return
+	}
+
+	deleted, err := schemas.DeleteSavedSearch(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), searchId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if !deleted {
+		utils.SendErrorResponse(w, http.StatusNotFound, "savedSearchNotFound", "saved search not found", nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Saved search deleted successfully", nil)
+}
+
+// GetSavedSearchNewArticlesHandler returns the articles that matched a saved search since the checkpoint, in match
+// order, and moves the checkpoint to the last one returned. While hasMore is true the next call returns the next page.
+func GetSavedSearchNewArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	searchId, err := strconv.ParseInt(chi.URLParam(r, "searchId"), 10, 64)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidSearchId", "search id must be a number", nil)
+		return
+	}
+
+	pool := PostgresInstance.GetPostgresInstance()
+	userId := middlewares.GetUserId(r)
+	limit, _ := parsePagination(r, 100, 500)
+
+	search, err := schemas.GetSavedSearchByID(ctx, pool, userId, searchId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if search == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "savedSearchNotFound", "saved search not found", nil)
+		return
+	}
+
+	since := search.CreatedAt
+	if search.LastCheckedAt != nil {
+		since = *search.LastCheckedAt
+	}
+
+	// the checkpoint only moves once the matches are fetched, a failed call loses nothing
+	matches, err := schemas.GetSavedSearchMatches(ctx, pool, searchId, since, search.LastCheckedId,
+		config.GetIntEnvironmentVariable("SAVED_SEARCH_SETTLE_SECONDS", 5), limit)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	until := since
+	if len(matches) > 0 {
+		last := matches[len(matches)-1]
+		if err := schemas.AdvanceSavedSearchCheckpoint(ctx, pool, userId, searchId, last.MatchedAt, last.ArticleId); err != nil {
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+			return
+		}
+		until = last.MatchedAt
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "New articles fetched successfully", map[string]interface{}{
+		"since":    since,
+		"until":    until,
+		"articles": matches,
+		"hasMore":  len(matches) == limit,
+	})
+}
+
+func lowercaseList(values []string) []string {
+	lowercased := []string{}
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			lowercased = append(lowercased, value)
+		}
+	}
+	return utils.ConvertDuplicatesArrtoUniqueArr(lowercased)
+}
@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"net/http"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"sort"
+	"strings"
+	"time"
+)
+
+type facetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type searchFacets struct {
+	Publisher []facetCount `json:"publisher"`
+	Category  []facetCount `json:"category"`
+	Sentiment []facetCount `json:"sentiment"`
+	Language  []facetCount `json:"language"`
+	Date      []facetCount `json:"date"` // -- histogram buckets in chronological order, e.g., "2024-05-01"
+}
+
+// SearchArticlesHandler searches articles with full-text and vector search fused by reciprocal rank fusion.
+// Filters: publisher, category, sentiment, language (comma separated or repeated), from, to.
+// freshness (0-100) boosts recent articles, interval (day, week, month) sets the date histogram buckets.
+// Facet counts cover the whole result set, not only the returned page.
+func SearchArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "missingQuery", "q is required", nil)
+		return
+	}
+
+	filter, ok := parseSearchFilter(w, r)
+	if !ok {
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+	if !utils.Includes([]string{"day", "week", "month"}, interval) {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidInterval", "interval must be day, week or month", nil)
+		return
+	}
+
+	freshness := parseIntOrDefault(r.URL.Query().Get("freshness"), config.GetIntEnvironmentVariable("SEARCH_FRESHNESS_WEIGHT", 30), 0, 100)
+	limit, offset := parsePagination(r, 20, 100)
+
+	results, err := workers.HybridSearch(r.Context(), query, workers.HybridSearchOptions{
+		Filter:          filter,
+		Candidates:      config.GetIntEnvironmentVariable("SEARCH_CANDIDATES", 200),
+		FreshnessWeight: float64(freshness) / 100,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	page := []workers.RetrievedArticle{}
+	if offset < len(results) {
+		page = results[offset:]
+		if len(page) > limit {
+			page = page[:limit]
+		}
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Search results fetched successfully", map[string]interface{}{
+		"query":   query,
+		"total":   len(results),
+		"results": page,
+		"facets":  buildSearchFacets(results, interval),
+	})
+}
+
+// SearchPassagesHandler searches article passages with full-text and vector search fused by reciprocal
+// rank fusion, taking the same filters as SearchArticlesHandler. Each passage comes with its offsets and
+// the matches of the query terms in the article content, and per_article caps the passages of one article.
+func SearchPassagesHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "missingQuery", "q is required", nil)
+		return
+	}
+
+	filter, ok := parseSearchFilter(w, r)
+	if !ok {
+		return
+	}
+
+	perArticle := parseIntOrDefault(r.URL.Query().Get("per_article"), 3, 0, 20)
+	limit, offset := parsePagination(r, 20, 100)
+
+	results, err := workers.PassageSearch(r.Context(), query, workers.PassageSearchOptions{
+		Filter:        filter,
+		Candidates:    config.GetIntEnvironmentVariable("SEARCH_CANDIDATES", 200),
+		MaxPerArticle: perArticle,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	page := []workers.RetrievedPassage{}
+	if offset < len(results) {
+		page = results[offset:]
+		if len(page) > limit {
+			page = page[:limit]
+		}
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Passages fetched successfully", map[string]interface{}{
+		"query":   query,
+		"total":   len(results),
+		"results": page,
+	})
+}
+
+// parseSearchFilter reads the publisher, category, sentiment, language, from and to filters of a search,
+// it writes the error response itself and returns false when a date is invalid
+func parseSearchFilter(w http.ResponseWriter, r *http.Request) (schemas.ArticleSearchFilter, bool) {
+	filter := schemas.ArticleSearchFilter{
+		Publishers: parseListParam(r, "publisher"),
+		Categories: parseListParam(r, "category"),
+		Sentiments: parseListParam(r, "sentiment"),
+		Languages:  parseListParam(r, "language"),
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := r.URL.Query().Get(bound.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := parseDateParam(value)
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "invalidDate", bound.name+" must be a date (2006-01-02) or an RFC3339 timestamp", nil)
+			return filter, false
+		}
+		*bound.target = &parsed
+	}
+
+	return filter, true
+}
+
+// buildSearchFacets counts the publishers, categories, sentiments, languages and publication dates of the results
+func buildSearchFacets(results []workers.RetrievedArticle, interval string) searchFacets {
+	publishers, categories, sentiments, languages, dates := map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}
+
+	for _, result := range results {
+		article := result.Article
+		publishers[article.Publisher]++
+		for _, category := range utils.ConvertDuplicatesArrtoUniqueArr(article.Categories) {
+			categories[category]++
+		}
+		if article.SentimentScore != "" {
+			sentiments[article.SentimentScore]++
+		}
+		if article.Language != "" {
+			languages[article.Language]++
+		}
+		dates[dateBucket(article.PublicationDate, interval)]++
+	}
+
+	histogram := sortFacetCounts(dates)
+	sort.Slice(histogram, func(i, j int) bool { return histogram[i].Value < histogram[j].Value })
+
+	return searchFacets{
+		Publisher: sortFacetCounts(publishers),
+		Category:  sortFacetCounts(categories),
+		Sentiment: sortFacetCounts(sentiments),
+		Language:  sortFacetCounts(languages),
+		Date:      histogram,
+	}
+}
+
+// sortFacetCounts orders the facet values by descending count, then alphabetically
+func sortFacetCounts(counts map[string]int) []facetCount {
+	facets := []facetCount{}
+	for value, count := range counts {
+		facets = append(facets, facetCount{Value: value, Count: count})
+	}
+
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+
+	return facets
+}
+
+// dateBucket returns the start of the day, ISO week (Monday) or month of t as a date
+func dateBucket(t time.Time, interval string) string {
+	t = t.UTC()
+	switch interval {
+	case "week":
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return t.AddDate(0, 0, -daysSinceMonday).Format("2006-01-02")
+	case "month":
+		return t.Format("2006-01") + "-01"
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// parseListParam collects the values of a query parameter given repeatedly or comma separated
+func parseListParam(r *http.Request, name string) []string {
+	values := []string{}
+	for _, param := range r.URL.Query()[name] {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// parseDateParam accepts a date (2006-01-02, UTC midnight) or an RFC3339 timestamp
+func parseDateParam(value string) (time.Time, error) {
+	if parsed, err := time.Parse("2006-01-02", value); err == nil {
+		return parsed, nil
+	}
+	return utils.ConvertStringToTimestamp(value)
+}
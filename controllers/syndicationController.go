@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+)
+
+// GetSyndicationFeedHandler renders the enriched articles of a category, publisher, entity or story
+// as RSS, Atom or JSON Feed, e.g. GET /feeds/category/Economy.rss
+func GetSyndicationFeedHandler(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	if !utils.Includes(schemas.FeedKinds, kind) {
+		utils.SendErrorResponse(w, http.StatusNotFound, "unknownFeedKind", "feeds exist per category, publisher, entity or story", nil)
+		return
+	}
+
+	// the extension picks the format, names themselves may contain dots
+	file := chi.URLParam(r, "file")
+	separator := strings.LastIndex(file, ".")
+	if separator <= 0 {
+		utils.SendErrorResponse(w, http.StatusNotFound, "unknownFeedFormat", "feed format must be rss, atom or json", nil)
+		return
+	}
+	value, format := file[:separator], file[separator+1:]
+	contentType, ok := utils.FeedContentTypes[format]
+	if !ok {
+		utils.SendErrorResponse(w, http.StatusNotFound, "unknownFeedFormat", "feed format must be rss, atom or json", nil)
+		return
+	}
+
+	limit := parseIntOrDefault(r.URL.Query().Get("limit"), config.GetIntEnvironmentVariable("SYNDICATION_FEED_SIZE", 50), 1, 200)
+
+	articles, err := schemas.GetFeedArticles(ctx, PostgresInstance.GetPostgresInstance(), kind, value, limit)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	// the ETag changes whenever an article enters, leaves or is edited in the feed
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s|%s|%s|%d", kind, strings.ToLower(value), format, limit)
+	for _, article := range articles {
+		fmt.Fprintf(hash, "|%s:%d", article.ArticleId, article.Version)
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil))[:32] + `"`
+	lastModified := getFeedLastModified(fmt.Sprintf("syndication-feed:%s:%s:%s:%d", kind, strings.ToLower(value), format, limit), etag)
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	// without PUBLIC_BASE_URL the links come from the Host header, shared caches must not keep them
+	baseUrl := strings.TrimRight(config.GetEnvironmentVariable("PUBLIC_BASE_URL"), "/")
+	cacheScope := "public"
+	if baseUrl == "" {
+		baseUrl = "https://" + r.Host
+		cacheScope = "private"
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", cacheScope, config.GetIntEnvironmentVariable("SYNDICATION_FEED_MAX_AGE_SECONDS", 300)))
+
+	if isFeedNotModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	feed := utils.Feed{
+		Title:       fmt.Sprintf("%s: %s", capitalize(kind), value),
+		Link:        baseUrl,
+		FeedUrl:     baseUrl + r.URL.Path,
+		Description: fmt.Sprintf("Latest articles for %s %s", kind, value),
+		Updated:     lastModified,
+	}
+	for _, article := range articles {
+		feed.Items = append(feed.Items, utils.FeedItem{
+			Id:         article.ArticleId,
+			Title:      article.Title,
+			Link:       article.Url,
+			Summary:    article.Summary,
+			Author:     article.Publisher,
+			Published:  article.PublicationDate,
+			Updated:    article.UpdatedAt,
+			Categories: []string(article.Categories),
+			Entities:   article.EntityNames(),
+			Sentiment:  article.SentimentScore,
+		})
+	}
+
+	body, err := utils.RenderFeed(feed, format)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// isFeedNotModified applies the conditional request headers, If-None-Match wins over If-Modified-Since
+func isFeedNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(ifModifiedSince)
+}
+
+// feedValidators is the last known state of a feed
+type feedValidators struct {
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// getFeedLastModified returns when the feed last changed. The newest item can't tell, an article leaving the
+// feed makes it go backwards, so the time the ETag changed is remembered. Without the cache every request
+// counts as a change and only If-None-Match can match.
+func getFeedLastModified(cacheKey string, etag string) time.Time {
+	var validators feedValidators
+	if utils.GetCachedJSON(ctx, cacheKey, &validators) && validators.ETag == etag {
+		return validators.LastModified
+	}
+
+	validators = feedValidators{ETag: etag, LastModified: time.Now().UTC().Truncate(time.Second)}
+	utils.SetCachedJSON(ctx, cacheKey, validators, time.Duration(config.GetIntEnvironmentVariable("SYNDICATION_FEED_STATE_TTL_DAYS", 30))*24*time.Hour)
+	return validators.LastModified
+}
+
+// capitalize upper-cases the first letter of a word
+func capitalize(word string) string {
+	first, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(first)) + word[size:]
+}
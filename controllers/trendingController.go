@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/utils"
+	"service-news-app-backend/workers"
+	"time"
+)
+
+// GetTrendingHandler returns the trending terms of ?type= (entity, category or tag) over ?window= (1h, 6h or 24h).
+// The latest snapshot of the trending job is served, computed live until the job has produced one.
+// ?burstsOnly=true keeps only the terms flagged as bursting.
+func GetTrendingHandler(w http.ResponseWriter, r *http.Request) {
+	windowName, termType, ok := parseTrendingParams(w, r)
+	if !ok {
+		return
+	}
+	limit, _ := parsePagination(r, 20, 100)
+
+	snapshots, err := schemas.GetLatestTrendingSnapshot(ctx, PostgresInstance.GetPostgresInstance(), windowName, termType, 1000)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	if len(snapshots) == 0 {
+		snapshots, err = workers.ComputeTrending(ctx, windowName, termType, time.Now())
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+			return
+		}
+	}
+
+	if r.URL.Query().Get("burstsOnly") == "true" {
+		bursts := []schemas.TrendingSnapshotSchema{}
+		for _, snapshot := range snapshots {
+			if snapshot.IsBurst {
+				bursts = append(bursts, snapshot)
+			}
+		}
+		snapshots = bursts
+	}
+
+	if len(snapshots) > limit {
+		snapshots = snapshots[:limit]
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Trending fetched successfully", snapshots)
+}
+
+// GetTrendingHistoryHandler returns the stored snapshots of ?term= for charts, over the last ?days= (default 7)
+func GetTrendingHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	windowName, termType, ok := parseTrendingParams(w, r)
+	if !ok {
+		return
+	}
+
+	term := r.URL.Query().Get("term")
+	if term == "" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidTerm", "term is required", nil)
+		return
+	}
+
+	days := parseIntOrDefault(r.URL.Query().Get("days"), 7, 1, 90)
+
+	history, err := schemas.GetTrendingHistory(ctx, PostgresInstance.GetPostgresInstance(), windowName, termType, term, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Trending history fetched successfully", history)
+}
+
+func parseTrendingParams(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	windowName := r.URL.Query().Get("window")
+	if windowName == "" {
+		windowName = "1h"
+	}
+	if _, ok := workers.TrendingWindows[windowName]; !ok {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidWindow", "window must be one of 1h, 6h, 24h", nil)
+		return "", "", false
+	}
+
+	termType := r.URL.Query().Get("type")
+	if termType == "" {
+		termType = "entity"
+	}
+	if !utils.Includes(workers.TrendingTermTypes, termType) {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidType", "type must be one of entity, category, tag", nil)
+		return "", "", false
+	}
+
+	return windowName, termType, true
+}
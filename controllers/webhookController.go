@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/middlewares"
+	"service-news-app-backend/utils"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+type webhookBody struct {
+	Url        string   `validate:"required,url" json:"url"`
+	EventTypes []string `validate:"required,min=1,dive,oneof=article.published article.updated article.deleted" json:"eventTypes"`
+	Filter     string   `json:"filter"`
+}
+
+type webhookStatusBody struct {
+	Enabled bool `json:"enabled"`
+}
+
+// CreateWebhookHandler subscribes a partner endpoint to article events, the signing secret is only returned here
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var body webhookBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	// body validation
+	validationError := schemas.ValidateInput(body)
+	if validationError != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", validationError.Error(), nil)
+		return
+	}
+
+	if err := utils.ValidatePublicURL(r.Context(), body.Url); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidWebhookUrl", err.Error(), nil)
+		return
+	}
+
+	if _, err := utils.ParseFilterExpression(body.Filter); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidFilterExpression", err.Error(), nil)
+		return
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	webhook, err := schemas.InsertWebhook(ctx, PostgresInstance.GetPostgresInstance(), schemas.WebhookSchema{
+		OwnerId:    middlewares.GetUserId(r),
+		Url:        body.Url,
+		Secret:     "whsec_" + hex.EncodeToString(secretBytes),
+		EventTypes: utils.ConvertDuplicatesArrtoUniqueArr(body.EventTypes),
+		Filter:     body.Filter,
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Webhook created successfully", webhook)
+}
+
+// GetWebhooksHandler lists the webhooks of the authenticated user
+func GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := schemas.GetWebhooksByOwner(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Webhooks fetched successfully", webhooks)
+}
+
+// UpdateWebhookStatusHandler enables or disables a webhook, enabling it again resets its failure count
+func UpdateWebhookStatusHandler(w http.ResponseWriter, r *http.Request) {
+	webhookId, err := strconv.ParseInt(chi.URLParam(r, "webhookId"), 10, 64)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidWebhookId", "webhook id must be a number", nil)
+		return
+	}
+
+	var body webhookStatusBody
+
+	// decode body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "bodyValidationFailed", err.Error(), nil)
+		return
+	}
+
+	webhook, err := schemas.SetWebhookEnabled(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), webhookId, body.Enabled)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if webhook == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "webhookNotFound", "webhook not found", nil)
+		return
+	}
+
+	webhook.Secret = ""
+	utils.SendSuccessResponse(w, http.StatusOK, "Webhook updated successfully", webhook)
+}
+
+// DeleteWebhookHandler removes a webhook of the authenticated user
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookId, err := strconv.ParseInt(chi.URLParam(r, "webhookId"), 10, 64)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidWebhookId", "webhook id must be a number", nil)
+		return
+	}
+
+	deleted, err := schemas.DeleteWebhook(ctx, PostgresInstance.GetPostgresInstance(), middlewares.GetUserId(r), webhookId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if !deleted {
+		utils.SendErrorResponse(w, http.StatusNotFound, "webhookNotFound", "webhook not found", nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Webhook deleted successfully", nil)
+}
+
+// GetWebhookDeliveriesHandler lists the delivery log of a webhook, optionally filtered by status
+func GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := getOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(r, 50, 200)
+
+	deliveries, err := schemas.GetWebhookDeliveries(ctx, PostgresInstance.GetPostgresInstance(), webhook.WebhookId, r.URL.Query().Get("status"), limit, offset)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Webhook deliveries fetched successfully", deliveries)
+}
+
+// ReplayWebhookDeliveryHandler queues a past delivery to be sent again
+func ReplayWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := getOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if !webhook.Enabled {
+		utils.SendErrorResponse(w, http.StatusConflict, "webhookDisabled", "enable the webhook before replaying deliveries", nil)
+		return
+	}
+
+	deliveryId, err := strconv.ParseInt(chi.URLParam(r, "deliveryId"), 10, 64)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidDeliveryId", "delivery id must be a number", nil)
+		return
+	}
+
+	delivery, err := schemas.ReplayWebhookDelivery(ctx, PostgresInstance.GetPostgresInstance(), webhook.WebhookId, deliveryId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return
+	}
+	if delivery == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "deliveryNotFound", "delivery not found", nil)
+		return
+	}
+
+	utils.SendSuccessResponse(w, http.StatusOK, "Webhook delivery queued for replay", delivery)
+}
+
+// getOwnedWebhook loads the webhook in the URL and checks it belongs to the authenticated user,
+// it writes the error response itself and returns false when it doesn't
+func getOwnedWebhook(w http.ResponseWriter, r *http.Request) (*schemas.WebhookSchema, bool) {
+	webhookId, err := strconv.ParseInt(chi.URLParam(r, "webhookId"), 10, 64)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalidWebhookId", "webhook id must be a number", nil)
+		return nil, false
+	}
+
+	webhook, err := schemas.GetWebhookByID(ctx, PostgresInstance.GetPostgresInstance(), webhookId)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "internalServerError", err.Error(), nil)
+		return nil, false
+	}
+	if webhook == nil || webhook.OwnerId != middlewares.GetUserId(r) {
+		utils.SendErrorResponse(w, http.StatusNotFound, "webhookNotFound", "webhook not found", nil)
+		return nil, false
+	}
+
+	return webhook, true
+}
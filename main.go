@@ -4,21 +4,60 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"os"
 
 	PostgresInstance "service-news-app-backend/Postgres_Instance"
 	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/commands"
 	envUtil "service-news-app-backend/config"
 	"service-news-app-backend/routes" // Import the new routes package
+	"service-news-app-backend/workers"
 )
 
 func main() {
 	// Load environment variables
 	envUtil.LoadEnvironmentVariables()
 
+	// one-off commands, e.g. "eval", run instead of the server
+	if len(os.Args) > 1 {
+		if err := commands.Run(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Create database connection
 	PostgresInstance.CreatePostgresInstance()
 	PostgresInstance.CreateDatabase()
 	schemas.CreateArticlesTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateArticleRevisionsTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateArticleChunksTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateRetentionPoliciesTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreatePurgeAuditTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateUserPreferencesTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateUserEventsTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateTrendingSnapshotsTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateAlertRulesTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateNotificationLogTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateWebhooksTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateWebhookDeliveriesTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateDigestsTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateSavedSearchesTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateSavedSearchMatchesTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateUserArticleStatesTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateCollectionsTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateCollectionItemsTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreateMetadataCorrectionsTable(context.Background(), PostgresInstance.GetPostgresInstance())
+	schemas.CreatePromptVersionsTable(context.Background(), PostgresInstance.GetPostgresInstance())
+
+	// Start background workers
+	workers.StartPromptRegistryWorker(context.Background())
+	workers.StartEnrichmentWorkers(context.Background())
+	workers.StartRetentionWorker(context.Background())
+	workers.StartTrendingWorker(context.Background())
+	workers.StartWebhookWorker(context.Background())
+	workers.StartDigestWorker(context.Background())
+	workers.StartArticleEventRelay(context.Background())
 
 	// Setup routes
 	r := routes.SetupRoutes()
@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+)
+
+// AdminOnly allows the request through only when the X-Admin-Token header matches ADMIN_API_TOKEN
+func AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminToken := config.GetEnvironmentVariable("ADMIN_API_TOKEN")
+		requestToken := r.Header.Get("X-Admin-Token")
+
+		if adminToken == "" || subtle.ConstantTimeCompare([]byte(adminToken), []byte(requestToken)) != 1 {
+			utils.SendErrorResponse(w, http.StatusUnauthorized, "unauthorized", "admin token required", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminUserOnly allows the request through only for casdoor admins. It must run after Authenticate.
+func AdminUserOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsAdminUser(r) {
+			utils.SendErrorResponse(w, http.StatusForbidden, "forbidden", "admin role required", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsAdminUser reports whether the authenticated user is a casdoor admin
+func IsAdminUser(r *http.Request) bool {
+	isAdmin, _ := r.Context().Value(userIsAdminContextKey).(bool)
+	return isAdmin
+}
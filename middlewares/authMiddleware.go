@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"strings"
+	"sync"
+
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+type contextKey string
+
+const userIdContextKey contextKey = "userId"
+const userIsAdminContextKey contextKey = "userIsAdmin"
+const userVerifiedEmailContextKey contextKey = "userVerifiedEmail"
+
+var casdoorInitOnce sync.Once
+
+func initCasdoor() {
+	casdoorsdk.InitConfig(
+		config.GetEnvironmentVariable("CASDOOR_ENDPOINT"),
+		config.GetEnvironmentVariable("CASDOOR_CLIENT_ID"),
+		config.GetEnvironmentVariable("CASDOOR_CLIENT_SECRET"),
+		config.GetEnvironmentVariable("CASDOOR_CERTIFICATE"),
+		config.GetEnvironmentVariable("CASDOOR_ORGANIZATION_NAME"),
+		config.GetEnvironmentVariable("CASDOOR_APPLICATION_NAME"),
+	)
+}
+
+// Authenticate verifies the casdoor token sent in the authToken (or Authorization: Bearer) header
+// and makes the user id available to the handlers through GetUserId
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		casdoorInitOnce.Do(initCasdoor)
+
+		token := r.Header.Get("authToken")
+		if token == "" {
+			token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if token == "" {
+			utils.SendErrorResponse(w, http.StatusUnauthorized, "unauthorized", "auth token required", nil)
+			return
+		}
+
+		claims, err := casdoorsdk.ParseJwtToken(token)
+		if err != nil || claims.User.Id == "" {
+			utils.SendErrorResponse(w, http.StatusUnauthorized, "unauthorized", "invalid auth token", nil)
+			return
+		}
+
+		requestContext := context.WithValue(r.Context(), userIdContextKey, claims.User.Id)
+		requestContext = context.WithValue(requestContext, userIsAdminContextKey, claims.User.IsAdmin)
+		if claims.User.EmailVerified {
+			requestContext = context.WithValue(requestContext, userVerifiedEmailContextKey, claims.User.Email)
+		}
+		next.ServeHTTP(w, r.WithContext(requestContext))
+	})
+}
+
+// GetUserId returns the id of the authenticated user, empty outside of Authenticate
+func GetUserId(r *http.Request) string {
+	userId, _ := r.Context().Value(userIdContextKey).(string)
+	return userId
+}
+
+// GetVerifiedEmail returns the email address of the authenticated user, empty when it is not verified
+func GetVerifiedEmail(r *http.Request) string {
+	email, _ := r.Context().Value(userVerifiedEmailContextKey).(string)
+	return email
+}
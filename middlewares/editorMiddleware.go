@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"net/http"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"strings"
+)
+
+// EditorOnly allows the request through only for casdoor admins and the users listed in EDITOR_USER_IDS
+// (comma separated). It must run after Authenticate.
+func EditorOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsEditor(r) {
+			utils.SendErrorResponse(w, http.StatusForbidden, "forbidden", "editor role required", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsEditor reports whether the authenticated user may review and override article metadata
+func IsEditor(r *http.Request) bool {
+	if IsAdminUser(r) {
+		return true
+	}
+
+	userId := GetUserId(r)
+	if userId == "" {
+		return false
+	}
+
+	for _, editorId := range strings.Split(config.GetEnvironmentVariable("EDITOR_USER_IDS"), ",") {
+		if strings.TrimSpace(editorId) == userId {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	controller "service-news-app-backend/controllers"
+	"service-news-app-backend/middlewares"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/v5/middleware"
@@ -18,15 +19,104 @@ func SetupRoutes() *chi.Mux {
 	// Basic CORS
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "authToken"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "authToken", "If-Match", "If-None-Match", "If-Modified-Since", "X-Admin-Token"},
+		ExposedHeaders:   []string{"Link", "ETag", "Last-Modified"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
 	// Define API routes
 	r.Post("/extract-meata-data", controller.ExtractMetaDataHandler)
+	r.Get("/articles/{id}/revisions", controller.GetArticleRevisionsHandler)
+	r.Get("/articles/{id}/revisions/{rev}/diff", controller.GetArticleRevisionDiffHandler)
+	r.Get("/articles/{id}/related", controller.GetRelatedArticlesHandler)
+	r.Get("/articles/{id}/events", controller.GetArticleEventsHandler)
+	r.Get("/trending", controller.GetTrendingHandler)
+	r.Get("/trending/history", controller.GetTrendingHistoryHandler)
+	r.Get("/feeds/{kind}/{file}", controller.GetSyndicationFeedHandler)
+	r.Get("/digests/latest", controller.GetLatestDigestHandler)
+
+	// User routes
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.Authenticate)
+
+		r.Get("/users/me/preferences", controller.GetUserPreferenceHandler)
+		r.Put("/users/me/preferences", controller.PutUserPreferenceHandler)
+		r.Post("/events", controller.TrackEventsHandler)
+		r.Get("/feed", controller.GetFeedHandler)
+		r.Get("/alert-rules", controller.GetAlertRulesHandler)
+		r.Post("/alert-rules", controller.CreateAlertRuleHandler)
+		r.Delete("/alert-rules/{ruleId}", controller.DeleteAlertRuleHandler)
+		r.Get("/saved-searches", controller.GetSavedSearchesHandler)
+		r.Post("/saved-searches", controller.CreateSavedSearchHandler)
+		r.Delete("/saved-searches/{searchId}", controller.DeleteSavedSearchHandler)
+		r.Get("/saved-searches/{searchId}/new", controller.GetSavedSearchNewArticlesHandler)
+		r.Put("/users/me/articles/{id}/state", controller.PutArticleStateHandler)
+		r.Get("/users/me/article-states", controller.GetArticleStatesHandler)
+		r.Get("/users/me/bookmarks", controller.GetBookmarksHandler)
+		r.Get("/users/me/collections", controller.GetCollectionsHandler)
+		r.Post("/users/me/collections", controller.CreateCollectionHandler)
+		r.Patch("/users/me/collections/{collectionId}", controller.RenameCollectionHandler)
+		r.Delete("/users/me/collections/{collectionId}", controller.DeleteCollectionHandler)
+		r.Get("/users/me/collections/{collectionId}/articles", controller.GetCollectionItemsHandler)
+		r.Put("/users/me/collections/{collectionId}/articles/{id}", controller.AddCollectionItemHandler)
+		r.Delete("/users/me/collections/{collectionId}/articles/{id}", controller.RemoveCollectionItemHandler)
+		r.Get("/users/me/sync", controller.SyncLibraryHandler)
+
+		// every question and search pays for embeddings and completions
+		r.Post("/ask", controller.AskHandler)
+		r.Get("/search", controller.SearchArticlesHandler)
+		r.Get("/search/passages", controller.SearchPassagesHandler)
+	})
+
+	// Editor routes
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.Authenticate)
+		r.Use(middlewares.EditorOnly)
+
+		r.Delete("/articles/{id}", controller.DeleteArticleHandler)
+		r.Patch("/articles/{id}/metadata", controller.PatchArticleMetadataHandler)
+		r.Get("/review/articles", controller.GetReviewQueueHandler)
+		r.Post("/review/articles/{id}/approve", controller.ApproveArticleMetadataHandler)
+		r.Get("/review/corrections", controller.GetMetadataCorrectionsHandler)
+	})
+
+	// Ingestion routes
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.AdminOnly)
+
+		r.Post("/articles/bulk", controller.BulkIngestArticlesHandler)
+	})
+
+	// Partner webhook routes
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.Authenticate)
+		r.Use(middlewares.AdminUserOnly)
+
+		r.Get("/webhooks", controller.GetWebhooksHandler)
+		r.Post("/webhooks", controller.CreateWebhookHandler)
+		r.Patch("/webhooks/{webhookId}", controller.UpdateWebhookStatusHandler)
+		r.Delete("/webhooks/{webhookId}", controller.DeleteWebhookHandler)
+		r.Get("/webhooks/{webhookId}/deliveries", controller.GetWebhookDeliveriesHandler)
+		r.Post("/webhooks/{webhookId}/deliveries/{deliveryId}/replay", controller.ReplayWebhookDeliveryHandler)
+	})
+
+	// Admin routes
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(middlewares.AdminOnly)
+
+		r.Delete("/articles/{id}/purge", controller.PurgeArticleHandler)
+		r.Get("/retention-policies", controller.GetRetentionPoliciesHandler)
+		r.Put("/retention-policies/{publisher}", controller.PutRetentionPolicyHandler)
+		r.Delete("/retention-policies/{publisher}", controller.DeleteRetentionPolicyHandler)
+		r.Get("/purge-audit", controller.GetPurgeAuditHandler)
+		r.Post("/digests", controller.GenerateDigestHandler)
+		r.Get("/prompts", controller.GetPromptsHandler)
+		r.Post("/prompts/{name}/versions", controller.CreatePromptVersionHandler)
+		r.Patch("/prompts/{name}/versions/{version}", controller.UpdatePromptTrafficHandler)
+		r.Get("/prompts/{name}/stats", controller.GetPromptStatsHandler)
+	})
 
 	return r
 }
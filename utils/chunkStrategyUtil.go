@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"math"
+	envUtil "service-news-app-backend/config"
+	"sort"
+	"strings"
+)
+
+// ChunkStrategy is how an enrichment step handles content longer than its token budget
+type ChunkStrategy string
+
+const (
+	ChunkStrategyTruncate     ChunkStrategy = "truncate"      // -- keep the beginning, cut at the end of a sentence
+	ChunkStrategyMapReduce    ChunkStrategy = "map_reduce"    // -- run the step on every chunk and combine the results
+	ChunkStrategyChunkAverage ChunkStrategy = "chunk_average" // -- embed every chunk and average the embeddings
+)
+
+// default strategy of each enrichment step, <STEP>_CHUNK_STRATEGY overrides it, e.g. SUMMARY_CHUNK_STRATEGY=truncate
+var defaultChunkStrategies = map[string]ChunkStrategy{
+	"metadata":  ChunkStrategyTruncate,
+	"summary":   ChunkStrategyMapReduce,
+	"embedding": ChunkStrategyChunkAverage,
+}
+
+// GetChunkStrategy returns the strategy of an enrichment step ("metadata", "summary" or "embedding")
+func GetChunkStrategy(step string) ChunkStrategy {
+	defaultStrategy := defaultChunkStrategies[step]
+	strategy := ChunkStrategy(envUtil.GetEnvironmentVariableOrDefault(strings.ToUpper(step)+"_CHUNK_STRATEGY", string(defaultStrategy)))
+
+	switch strategy {
+	case ChunkStrategyTruncate, ChunkStrategyMapReduce, ChunkStrategyChunkAverage:
+		return strategy
+	default:
+		log.Printf("Unknown chunk strategy %q for %s, using %s\n", strategy, step, defaultStrategy)
+		return defaultStrategy
+	}
+}
+
+// promptInputBudget returns the model of the prompt version selected for the subject and how many tokens of
+// content it takes: its context window less the templates and the room kept for the answer
+// (LLM_OUTPUT_RESERVE_TOKENS, default 1024), capped by LLM_MAX_INPUT_TOKENS when set
+func promptInputBudget(name string, subject string) (string, int, error) {
+	prompt, err := SelectPromptVersion(name, subject)
+	if err != nil {
+		return "", 0, err
+	}
+
+	budget := ModelContextTokens(prompt.Model) -
+		CountTokens(prompt.Model, prompt.System+prompt.User) -
+		envUtil.GetIntEnvironmentVariable("LLM_OUTPUT_RESERVE_TOKENS", 1024)
+	if maxInputTokens := envUtil.GetIntEnvironmentVariable("LLM_MAX_INPUT_TOKENS", 0); maxInputTokens > 0 && budget > maxInputTokens {
+		budget = maxInputTokens
+	}
+	if budget < 256 {
+		budget = 256
+	}
+
+	return prompt.Model, budget, nil
+}
+
+// limitChunks keeps the first LLM_MAX_CHUNKS chunks (default 8), bounding the calls a map-reduce makes
+func limitChunks(chunks []TextChunk) []TextChunk {
+	maxChunks := envUtil.GetIntEnvironmentVariable("LLM_MAX_CHUNKS", 8)
+	if maxChunks > 0 && len(chunks) > maxChunks {
+		return chunks[:maxChunks]
+	}
+	return chunks
+}
+
+// mergeMetaData combines the metadata of the chunks of an article: the three most frequent categories, every
+// entity, the most frequent sentiment (the first chunk breaks ties) and the lowest confidence
+func mergeMetaData(results []MetaData) MetaData {
+	merged := MetaData{
+		Entities:    Entities{Organizations: []string{}, Locations: []string{}, Individuals: []string{}},
+		Categories:  []string{},
+		FieldErrors: []SchemaFieldError{},
+	}
+	if len(results) == 0 {
+		return merged
+	}
+
+	categoryCounts := map[string]int{}
+	categoryOrder := []string{}
+	sentimentCounts := map[string]int{}
+	merged.Confidence = 100
+
+	for _, result := range results {
+		for _, category := range result.Categories {
+			if categoryCounts[category] == 0 {
+				categoryOrder = append(categoryOrder, category)
+			}
+			categoryCounts[category]++
+		}
+
+		merged.Entities.Organizations = appendUnique(merged.Entities.Organizations, result.Entities.Organizations)
+		merged.Entities.Locations = appendUnique(merged.Entities.Locations, result.Entities.Locations)
+		merged.Entities.Individuals = appendUnique(merged.Entities.Individuals, result.Entities.Individuals)
+
+		if result.SentimentScore != "" {
+			sentimentCounts[result.SentimentScore]++
+		}
+		if result.Confidence < merged.Confidence {
+			merged.Confidence = result.Confidence
+		}
+		merged.FieldErrors = append(merged.FieldErrors, result.FieldErrors...)
+	}
+
+	sort.SliceStable(categoryOrder, func(i, j int) bool {
+		return categoryCounts[categoryOrder[i]] > categoryCounts[categoryOrder[j]]
+	})
+	if len(categoryOrder) > 3 {
+		categoryOrder = categoryOrder[:3]
+	}
+	merged.Categories = append(merged.Categories, categoryOrder...)
+
+	for _, result := range results {
+		if sentimentCounts[result.SentimentScore] > sentimentCounts[merged.SentimentScore] {
+			merged.SentimentScore = result.SentimentScore
+		}
+	}
+
+	return merged
+}
+
+func appendUnique(values []string, additions []string) []string {
+	for _, addition := range additions {
+		if !Includes(values, addition) {
+			values = append(values, addition)
+		}
+	}
+	return values
+}
+
+// EmbeddedChunk is a passage of an article with its own embedding
+type EmbeddedChunk struct {
+	TextChunk
+	Embedding []float32
+}
+
+// GenerateArticleEmbeddings embeds an article following the "embedding" chunk strategy. With chunk_average the
+// content is cut into passages of EMBEDDING_CHUNK_TOKENS tokens (default 400, overlapping by
+// EMBEDDING_CHUNK_OVERLAP_TOKENS, default 50), each embedded with the title for context; the article embedding
+// is their normalized mean and the passages are returned for passage-level search, with offsets into the content.
+// With truncate the title and the beginning of the content get a single embedding and there are no passages.
+func GenerateArticleEmbeddings(ctx context.Context, title string, content string) ([]float32, []EmbeddedChunk, error) {
+	if GetChunkStrategy("embedding") != ChunkStrategyChunkAverage || strings.TrimSpace(content) == "" {
+		embedding, err := GenerateVectorEmebeddings(ctx, title+"\n\n"+content)
+		return embedding, nil, err
+	}
+
+	_, embeddingModel := GetEmbeddingProvider()
+	chunks := ChunkText(embeddingModel, content,
+		envUtil.GetIntEnvironmentVariable("EMBEDDING_CHUNK_TOKENS", 400),
+		envUtil.GetIntEnvironmentVariable("EMBEDDING_CHUNK_OVERLAP_TOKENS", 50))
+
+	inputs := []string{}
+	for _, chunk := range chunks {
+		inputs = append(inputs, title+"\n\n"+chunk.Text)
+	}
+	embeddings, err := GenerateVectorEmbeddingsBatch(ctx, inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	embeddedChunks := []EmbeddedChunk{}
+	for i, chunk := range chunks {
+		embeddedChunks = append(embeddedChunks, EmbeddedChunk{TextChunk: chunk, Embedding: embeddings[i]})
+	}
+
+	return averageEmbeddings(embeddings), embeddedChunks, nil
+}
+
+// averageEmbeddings returns the mean of the embeddings scaled back to unit length, as the model's own are
+func averageEmbeddings(embeddings [][]float32) []float32 {
+	if len(embeddings) == 0 {
+		return nil
+	}
+
+	mean := make([]float64, len(embeddings[0]))
+	for _, embedding := range embeddings {
+		for i, value := range embedding {
+			if i < len(mean) {
+				mean[i] += float64(value)
+			}
+		}
+	}
+
+	norm := 0.0
+	for _, value := range mean {
+		norm += value * value
+	}
+	norm = math.Sqrt(norm)
+
+	average := make([]float32, len(mean))
+	for i, value := range mean {
+		if norm > 0 {
+			average[i] = float32(value / norm)
+		}
+	}
+	return average
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextChunk is a piece of a text, cut on paragraph or sentence boundaries
+type TextChunk struct {
+	Index  int    `json:"index"`
+	Text   string `json:"text"`
+	Start  int    `json:"start"` // -- byte offset of the chunk in the text
+	End    int    `json:"end"`   // -- byte offset just past the chunk
+	Tokens int    `json:"tokens"`
+}
+
+// textUnit is a sentence of the text, or a run of words of a sentence too long for a chunk
+type textUnit struct {
+	start, end int
+	paragraph  int
+	tokens     int
+}
+
+// ChunkText cuts the text into chunks of at most maxTokens tokens of the model. Whole paragraphs are kept
+// together when they fit, otherwise paragraphs are cut between sentences. Each chunk repeats up to
+// overlapTokens of the end of the previous one so no passage loses its context at a cut.
+func ChunkText(model string, text string, maxTokens int, overlapTokens int) []TextChunk {
+	if maxTokens <= 0 {
+		maxTokens = 1
+	}
+	if overlapTokens >= maxTokens {
+		overlapTokens = maxTokens / 2
+	}
+
+	units := splitTextUnits(model, text, maxTokens)
+	chunks := []TextChunk{}
+
+	current := []textUnit{}
+	currentTokens := 0
+	hasNewUnits := false // -- the chunk holds more than the overlap of the previous one
+
+	flush := func(keepOverlap bool) {
+		if !hasNewUnits {
+			return
+		}
+		start, end := current[0].start, current[len(current)-1].end
+		chunks = append(chunks, TextChunk{Index: len(chunks), Text: text[start:end], Start: start, End: end, Tokens: currentTokens})
+
+		overlap := []textUnit{}
+		overlapSize := 0
+		for i := len(current) - 1; keepOverlap && i > 0 && overlapSize+current[i].tokens <= overlapTokens; i-- {
+			overlap = append([]textUnit{current[i]}, overlap...)
+			overlapSize += current[i].tokens
+		}
+		current, currentTokens, hasNewUnits = overlap, overlapSize, false
+	}
+
+	add := func(unit textUnit) {
+		// the overlap gives way to new text
+		for len(current) > 0 && !hasNewUnits && currentTokens+unit.tokens > maxTokens {
+			currentTokens -= current[0].tokens
+			current = current[1:]
+		}
+		current = append(current, unit)
+		currentTokens += unit.tokens
+		hasNewUnits = true
+	}
+
+	for i := 0; i < len(units); {
+		// the units of the paragraph
+		end := i
+		paragraphTokens := 0
+		for end < len(units) && units[end].paragraph == units[i].paragraph {
+			paragraphTokens += units[end].tokens
+			end++
+		}
+
+		if currentTokens+paragraphTokens > maxTokens {
+			flush(true)
+		}
+		for _, unit := range units[i:end] {
+			if currentTokens+unit.tokens > maxTokens {
+				flush(true)
+			}
+			add(unit)
+		}
+		i = end
+	}
+	flush(false)
+
+	return chunks
+}
+
+// TruncateToTokens keeps the beginning of the text that fits in maxTokens tokens of the model, cut at the
+// end of a sentence
+func TruncateToTokens(model string, text string, maxTokens int) string {
+	if CountTokens(model, text) <= maxTokens {
+		return text
+	}
+	chunks := ChunkText(model, text, maxTokens, 0)
+	if len(chunks) == 0 {
+		return ""
+	}
+	return text[:chunks[0].End]
+}
+
+// splitTextUnits splits the text into sentences, numbered by paragraph. Sentences longer than maxTokens
+// are split between words.
+func splitTextUnits(model string, text string, maxTokens int) []textUnit {
+	units := []textUnit{}
+	paragraph := 0
+
+	addSentence := func(start int, end int) {
+		// trim the surrounding spaces, offsets stay on the text
+		for start < end && isSpaceByte(text[start]) {
+			start++
+		}
+		for end > start && isSpaceByte(text[end-1]) {
+			end--
+		}
+		if start == end {
+			return
+		}
+
+		tokens := CountTokens(model, text[start:end])
+		if tokens <= maxTokens {
+			units = append(units, textUnit{start: start, end: end, paragraph: paragraph, tokens: tokens})
+			return
+		}
+		units = append(units, splitWords(model, text, start, end, paragraph, maxTokens)...)
+	}
+
+	sentenceStart := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		if r == '\n' {
+			addSentence(sentenceStart, i)
+			// a blank line or a line break starts a new paragraph
+			for i < len(text) && isSpaceByte(text[i]) {
+				i++
+			}
+			sentenceStart = i
+			paragraph++
+			continue
+		}
+
+		i += size
+		if r == '.' || r == '!' || r == '?' || r == '।' {
+			// the sentence ends when the punctuation is followed by a space, after closing quotes
+			end := i
+			for end < len(text) {
+				quote, quoteSize := utf8.DecodeRuneInString(text[end:])
+				if !strings.ContainsRune(`"')”’`, quote) {
+					break
+				}
+				end += quoteSize
+			}
+			if end == len(text) || isSpaceByte(text[end]) {
+				addSentence(sentenceStart, end)
+				sentenceStart = end
+				i = end
+			}
+		}
+	}
+	addSentence(sentenceStart, len(text))
+
+	return units
+}
+
+func splitWords(model string, text string, start int, end int, paragraph int, maxTokens int) []textUnit {
+	units := []textUnit{}
+	pieceStart, pieceTokens := -1, 0
+
+	wordStart := -1
+	for i := start; i <= end; i++ {
+		if i < end && !isSpaceByte(text[i]) {
+			if wordStart < 0 {
+				wordStart = i
+			}
+			continue
+		}
+		if wordStart < 0 {
+			continue
+		}
+
+		tokens := CountTokens(model, text[wordStart:i])
+		if pieceStart >= 0 && pieceTokens+tokens > maxTokens {
+			units = append(units, textUnit{start: pieceStart, end: wordStart, paragraph: paragraph, tokens: pieceTokens})
+			pieceStart, pieceTokens = -1, 0
+		}
+		if tokens > maxTokens {
+			// a word longer than a chunk (e.g. a URL or a base64 blob) is cut between characters
+			units = append(units, splitCharacters(model, text, wordStart, i, paragraph, maxTokens)...)
+			wordStart = -1
+			continue
+		}
+		if pieceStart < 0 {
+			pieceStart = wordStart
+		}
+		pieceTokens += tokens
+		wordStart = -1
+	}
+	if pieceStart >= 0 {
+		units = append(units, textUnit{start: pieceStart, end: end, paragraph: paragraph, tokens: pieceTokens})
+	}
+
+	// the pieces end on the space before the next word
+	for i := range units {
+		for units[i].end > units[i].start && isSpaceByte(text[units[i].end-1]) {
+			units[i].end--
+		}
+	}
+	return units
+}
+
+// splitCharacters cuts a single word into pieces of at most maxTokens tokens, on character boundaries
+func splitCharacters(model string, text string, start int, end int, paragraph int, maxTokens int) []textUnit {
+	units := []textUnit{}
+
+	// the byte offsets where a character ends
+	boundaries := []int{}
+	for i := start; i < end; {
+		_, size := utf8.DecodeRuneInString(text[i:end])
+		i += size
+		boundaries = append(boundaries, i)
+	}
+
+	for first := 0; first < len(boundaries); {
+		// the longest run of characters that fits, at least one character
+		last := first
+		low, high := first+1, len(boundaries)-1
+		for low <= high {
+			middle := (low + high) / 2
+			if CountTokens(model, text[start:boundaries[middle]]) <= maxTokens {
+				last, low = middle, middle+1
+			} else {
+				high = middle - 1
+			}
+		}
+
+		pieceEnd := boundaries[last]
+		units = append(units, textUnit{start: start, end: pieceEnd, paragraph: paragraph, tokens: CountTokens(model, text[start:pieceEnd])})
+		start, first = pieceEnd, last+1
+	}
+	return units
+}
+
+func isSpaceByte(b byte) bool {
+	return b < utf8.RuneSelf && unicode.IsSpace(rune(b))
+}
@@ -0,0 +1,379 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	envUtil "service-news-app-backend/config"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClassifierExample is a labeled article to train the category classifier on
+type ClassifierExample struct {
+	Text   string
+	Labels []string
+}
+
+// ClassifierTrainingOptions tune the training, zero values take the defaults of DefaultClassifierTrainingOptions
+type ClassifierTrainingOptions struct {
+	Epochs               int     // -- passes over the examples
+	LearningRate         float64 // -- initial step size, decreasing with the epochs
+	L2                   float64 // -- weight decay, keeps small datasets from being learned by heart
+	MinDocumentFrequency int     // -- terms of fewer examples are left out of the vocabulary
+	MaxFeatures          int     // -- the most frequent terms kept in the vocabulary
+	Threshold            float64 // -- probability a category needs to be predicted
+	MaxLabels            int     // -- categories predicted at most per article
+}
+
+// DefaultClassifierTrainingOptions suit a few thousand articles over a dozen categories
+var DefaultClassifierTrainingOptions = ClassifierTrainingOptions{
+	Epochs:               20,
+	LearningRate:         0.5,
+	L2:                   0.0001,
+	MinDocumentFrequency: 2,
+	MaxFeatures:          20000,
+	Threshold:            0.5,
+	MaxLabels:            3,
+}
+
+// CategoryClassifier predicts the categories of an article from the TF-IDF weights of its words and word pairs,
+// with a logistic regression per category (one-vs-rest). It needs no model server, the train command fits it
+// on labeled articles and stores it as JSON.
+type CategoryClassifier struct {
+	Labels     []string       `json:"labels"`
+	Vocabulary map[string]int `json:"vocabulary"` // -- term to column
+	IDF        []float64      `json:"idf"`
+	Weights    [][]float64    `json:"weights"` // -- a row per label, a column per term
+	Biases     []float64      `json:"biases"`
+	Threshold  float64        `json:"threshold"`
+	MaxLabels  int            `json:"maxLabels"`
+	Examples   int            `json:"examples"` // -- number of articles it was trained on
+	TrainedAt  time.Time      `json:"trainedAt"`
+}
+
+// ClassifierPrediction is the probability of a category for an article
+type ClassifierPrediction struct {
+	Label       string  `json:"label"`
+	Probability float64 `json:"probability"`
+}
+
+// sparseFeature is a non-zero column of a TF-IDF vector
+type sparseFeature struct {
+	column int
+	value  float64
+}
+
+// TrainCategoryClassifier fits a classifier on the examples with stochastic gradient descent. The examples
+// are shuffled with a fixed seed so the same data gives the same model.
+func TrainCategoryClassifier(examples []ClassifierExample, options ClassifierTrainingOptions) (*CategoryClassifier, error) {
+	options = withDefaultTrainingOptions(options)
+
+	labelSet := map[string]bool{}
+	documents := make([][]string, len(examples))
+	for i, example := range examples {
+		for _, label := range example.Labels {
+			labelSet[label] = true
+		}
+		documents[i] = classifierTerms(example.Text)
+	}
+	if len(labelSet) == 0 {
+		return nil, fmt.Errorf("no labeled examples to train on")
+	}
+
+	classifier := &CategoryClassifier{
+		Labels:     sortedKeys(labelSet),
+		Vocabulary: map[string]int{},
+		Threshold:  options.Threshold,
+		MaxLabels:  options.MaxLabels,
+		Examples:   len(examples),
+		TrainedAt:  time.Now().UTC(),
+	}
+
+	// vocabulary: the terms of the most examples
+	documentFrequency := map[string]int{}
+	for _, terms := range documents {
+		seen := map[string]bool{}
+		for _, term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				documentFrequency[term]++
+			}
+		}
+	}
+	vocabulary := []string{}
+	for term, frequency := range documentFrequency {
+		if frequency >= options.MinDocumentFrequency {
+			vocabulary = append(vocabulary, term)
+		}
+	}
+	sort.Slice(vocabulary, func(i, j int) bool {
+		if documentFrequency[vocabulary[i]] != documentFrequency[vocabulary[j]] {
+			return documentFrequency[vocabulary[i]] > documentFrequency[vocabulary[j]]
+		}
+		return vocabulary[i] < vocabulary[j]
+	})
+	if len(vocabulary) > options.MaxFeatures {
+		vocabulary = vocabulary[:options.MaxFeatures]
+	}
+	if len(vocabulary) == 0 {
+		return nil, fmt.Errorf("no term appears in %d examples, add examples or lower the minimum document frequency", options.MinDocumentFrequency)
+	}
+
+	classifier.IDF = make([]float64, len(vocabulary))
+	for column, term := range vocabulary {
+		classifier.Vocabulary[term] = column
+		classifier.IDF[column] = math.Log(float64(1+len(examples))/float64(1+documentFrequency[term])) + 1
+	}
+
+	features := make([][]sparseFeature, len(documents))
+	targets := make([][]float64, len(examples))
+	for i, terms := range documents {
+		features[i] = classifier.vectorize(terms)
+		targets[i] = make([]float64, len(classifier.Labels))
+		for k, label := range classifier.Labels {
+			if Includes(examples[i].Labels, label) {
+				targets[i][k] = 1
+			}
+		}
+	}
+
+	classifier.Weights = make([][]float64, len(classifier.Labels))
+	for k := range classifier.Weights {
+		classifier.Weights[k] = make([]float64, len(vocabulary))
+	}
+	classifier.Biases = make([]float64, len(classifier.Labels))
+
+	random := rand.New(rand.NewSource(1))
+	order := random.Perm(len(examples))
+	for epoch := 0; epoch < options.Epochs; epoch++ {
+		learningRate := options.LearningRate / math.Sqrt(float64(1+epoch))
+		random.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+
+		for _, i := range order {
+			for k := range classifier.Labels {
+				gradient := sigmoid(classifier.score(k, features[i])) - targets[i][k]
+				weights := classifier.Weights[k]
+				// the decay is only applied to the columns of the example, keeping each step sparse
+				for _, feature := range features[i] {
+					weights[feature.column] -= learningRate * (gradient*feature.value + options.L2*weights[feature.column])
+				}
+				classifier.Biases[k] -= learningRate * gradient
+			}
+		}
+	}
+
+	return classifier, nil
+}
+
+func withDefaultTrainingOptions(options ClassifierTrainingOptions) ClassifierTrainingOptions {
+	defaults := DefaultClassifierTrainingOptions
+	if options.Epochs <= 0 {
+		options.Epochs = defaults.Epochs
+	}
+	if options.LearningRate <= 0 {
+		options.LearningRate = defaults.LearningRate
+	}
+	if options.L2 <= 0 {
+		options.L2 = defaults.L2
+	}
+	if options.MinDocumentFrequency <= 0 {
+		options.MinDocumentFrequency = defaults.MinDocumentFrequency
+	}
+	if options.MaxFeatures <= 0 {
+		options.MaxFeatures = defaults.MaxFeatures
+	}
+	if options.Threshold <= 0 || options.Threshold >= 1 {
+		options.Threshold = defaults.Threshold
+	}
+	if options.MaxLabels <= 0 {
+		options.MaxLabels = defaults.MaxLabels
+	}
+	return options
+}
+
+// Predict returns the probability of every category for the text, most likely first
+func (classifier *CategoryClassifier) Predict(text string) []ClassifierPrediction {
+	features := classifier.vectorize(classifierTerms(text))
+
+	predictions := make([]ClassifierPrediction, len(classifier.Labels))
+	for k, label := range classifier.Labels {
+		predictions[k] = ClassifierPrediction{Label: label, Probability: sigmoid(classifier.score(k, features))}
+	}
+	sort.SliceStable(predictions, func(i, j int) bool { return predictions[i].Probability > predictions[j].Probability })
+	return predictions
+}
+
+// Classify returns the categories of the text: those over the threshold, up to MaxLabels, and at least the
+// most likely one. The confidence (0 to 100) is the probability of the most likely category.
+func (classifier *CategoryClassifier) Classify(text string) ([]string, int) {
+	predictions := classifier.Predict(text)
+	if len(predictions) == 0 {
+		return []string{}, 0
+	}
+
+	categories := []string{predictions[0].Label}
+	for _, prediction := range predictions[1:] {
+		if len(categories) >= classifier.MaxLabels || prediction.Probability < classifier.Threshold {
+			break
+		}
+		categories = append(categories, prediction.Label)
+	}
+	return categories, int(math.Round(predictions[0].Probability * 100))
+}
+
+func (classifier *CategoryClassifier) score(label int, features []sparseFeature) float64 {
+	score := classifier.Biases[label]
+	weights := classifier.Weights[label]
+	for _, feature := range features {
+		score += weights[feature.column] * feature.value
+	}
+	return score
+}
+
+// vectorize turns the terms into their TF-IDF vector (sublinear term frequency, unit length), unknown terms are dropped
+func (classifier *CategoryClassifier) vectorize(terms []string) []sparseFeature {
+	counts := map[int]int{}
+	for _, term := range terms {
+		if column, ok := classifier.Vocabulary[term]; ok {
+			counts[column]++
+		}
+	}
+
+	features := make([]sparseFeature, 0, len(counts))
+	norm := 0.0
+	for column, count := range counts {
+		value := (1 + math.Log(float64(count))) * classifier.IDF[column]
+		features = append(features, sparseFeature{column: column, value: value})
+		norm += value * value
+	}
+	norm = math.Sqrt(norm)
+
+	sort.Slice(features, func(i, j int) bool { return features[i].column < features[j].column })
+	for i := range features {
+		features[i].value /= norm
+	}
+	return features
+}
+
+// classifierTerms returns the stemmed words of the text without stopwords or numbers, and the pairs of
+// consecutive words (e.g. "prime minist")
+func classifierTerms(text string) []string {
+	words := []string{}
+	for _, span := range textWords(text) {
+		word := strings.ToLower(text[span.Start:span.End])
+		if len(word) < 2 || strings.Trim(word, "0123456789") == "" || isStopword(word) {
+			continue
+		}
+		words = append(words, stemWord(word))
+	}
+
+	terms := append([]string{}, words...)
+	for i := 1; i < len(words); i++ {
+		terms = append(terms, words[i-1]+" "+words[i])
+	}
+	return terms
+}
+
+func isStopword(word string) bool {
+	if Includes(highlightStopwords, word) {
+		return true
+	}
+	for _, stopwords := range languageStopwords {
+		if Includes(stopwords, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func sigmoid(value float64) float64 {
+	return 1 / (1 + math.Exp(-value))
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SaveCategoryClassifier writes the classifier as JSON to path, creating its directory
+func SaveCategoryClassifier(classifier *CategoryClassifier, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(classifier)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadCategoryClassifier reads a classifier written by SaveCategoryClassifier
+func LoadCategoryClassifier(path string) (*CategoryClassifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var classifier CategoryClassifier
+	if err := json.Unmarshal(data, &classifier); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(classifier.Weights) != len(classifier.Labels) || len(classifier.Biases) != len(classifier.Labels) {
+		return nil, fmt.Errorf("%s: weights don't match the %d labels", path, len(classifier.Labels))
+	}
+	for _, weights := range classifier.Weights {
+		if len(weights) != len(classifier.IDF) {
+			return nil, fmt.Errorf("%s: weights don't match the vocabulary", path)
+		}
+	}
+	return &classifier, nil
+}
+
+var (
+	categoryClassifierMutex    sync.Mutex
+	categoryClassifierOverride *CategoryClassifier
+	loadedCategoryClassifier   *CategoryClassifier
+	loadedClassifierPath       string
+)
+
+// GetCategoryClassifier returns the classifier tagging categories in place of the LLM: the one set with
+// SetCategoryClassifier, or with CATEGORIES_PROVIDER=classifier the one stored at CATEGORY_CLASSIFIER_PATH
+// (default models/category_classifier.json). It returns nil when the LLM tags the categories.
+func GetCategoryClassifier() (*CategoryClassifier, error) {
+	categoryClassifierMutex.Lock()
+	defer categoryClassifierMutex.Unlock()
+
+	if categoryClassifierOverride != nil {
+		return categoryClassifierOverride, nil
+	}
+	if envUtil.GetEnvironmentVariableOrDefault("CATEGORIES_PROVIDER", "llm") != "classifier" {
+		return nil, nil
+	}
+
+	path := envUtil.GetEnvironmentVariableOrDefault("CATEGORY_CLASSIFIER_PATH", "models/category_classifier.json")
+	if loadedCategoryClassifier == nil || loadedClassifierPath != path {
+		classifier, err := LoadCategoryClassifier(path)
+		if err != nil {
+			return nil, fmt.Errorf("error loading category classifier: %v", err)
+		}
+		loadedCategoryClassifier, loadedClassifierPath = classifier, path
+	}
+	return loadedCategoryClassifier, nil
+}
+
+// SetCategoryClassifier tags categories with the given classifier, nil goes back to CATEGORIES_PROVIDER
+func SetCategoryClassifier(classifier *CategoryClassifier) {
+	categoryClassifierMutex.Lock()
+	categoryClassifierOverride = classifier
+	categoryClassifierMutex.Unlock()
+}
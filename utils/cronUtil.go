@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a standard five field cron expression (minute hour day-of-month month day-of-week).
+// Fields accept *, single values, ranges (1-5), lists (1,15) and steps (*/15, 0-30/10).
+type CronSchedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+	anyDay      [2]bool // -- whether day-of-month and day-of-week were "*"
+}
+
+// ParseCronSchedule parses a five field cron expression, e.g. "0 6 * * 1-5" for 06:00 on weekdays
+func ParseCronSchedule(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression needs 5 fields, got %d", len(fields))
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	parsed := [5]map[int]bool{}
+	for i, field := range fields {
+		values, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron field %q: %v", field, err)
+		}
+		parsed[i] = values
+	}
+
+	// 7 is Sunday as well
+	if parsed[4][7] {
+		parsed[4][0] = true
+	}
+
+	return &CronSchedule{
+		minutes:     parsed[0],
+		hours:       parsed[1],
+		daysOfMonth: parsed[2],
+		months:      parsed[3],
+		daysOfWeek:  parsed[4],
+		anyDay:      [2]bool{fields[2] == "*", fields[4] == "*"},
+	}, nil
+}
+
+// Matches reports whether the schedule fires at the minute of t
+func (schedule *CronSchedule) Matches(t time.Time) bool {
+	if !schedule.minutes[t.Minute()] || !schedule.hours[t.Hour()] || !schedule.months[int(t.Month())] {
+		return false
+	}
+
+	dayOfMonth := schedule.daysOfMonth[t.Day()]
+	dayOfWeek := schedule.daysOfWeek[int(t.Weekday())]
+
+	// as in cron, a restricted day-of-month and day-of-week match when either does
+	switch {
+	case schedule.anyDay[0] && schedule.anyDay[1]:
+		return true
+	case schedule.anyDay[0]:
+		return dayOfWeek
+	case schedule.anyDay[1]:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
+
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	values := map[int]bool{}
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, found := strings.Cut(part, "/"); found {
+			parsedStep, err := strconv.Atoi(stepPart)
+			if err != nil || parsedStep <= 0 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+			part, step = rangePart, parsedStep
+		}
+
+		start, end := min, max
+		if part != "*" {
+			startPart, endPart, isRange := strings.Cut(part, "-")
+
+			var err error
+			start, err = strconv.Atoi(startPart)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", startPart)
+			}
+			end = start
+			if isRange {
+				end, err = strconv.Atoi(endPart)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", endPart)
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
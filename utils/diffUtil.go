@@ -0,0 +1,125 @@
+package utils
+
+import "strings"
+
+// above this many cells the changed middle of two texts is reported as a single replacement
+const maxDiffMatrixCells = 4_000_000
+
+// DiffOp is one segment of a word-level diff
+type DiffOp struct {
+	Type string `json:"type"` // -- "equal", "insert" or "delete"
+	Text string `json:"text"`
+}
+
+// DiffWords returns the word-level diff turning oldText into newText
+func DiffWords(oldText string, newText string) []DiffOp {
+	oldWords := strings.Fields(oldText)
+	newWords := strings.Fields(newText)
+
+	// common prefix and suffix are trimmed so the LCS only runs on the changed middle
+	prefix := 0
+	for prefix < len(oldWords) && prefix < len(newWords) && oldWords[prefix] == newWords[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(oldWords)-prefix && suffix < len(newWords)-prefix &&
+		oldWords[len(oldWords)-1-suffix] == newWords[len(newWords)-1-suffix] {
+		suffix++
+	}
+
+	ops := []DiffOp{}
+	ops = appendDiffOp(ops, "equal", oldWords[:prefix])
+	ops = append(ops, diffMiddle(oldWords[prefix:len(oldWords)-suffix], newWords[prefix:len(newWords)-suffix])...)
+	ops = appendDiffOp(ops, "equal", oldWords[len(oldWords)-suffix:])
+
+	return ops
+}
+
+// DiffChangeRatio returns the share of words inserted or deleted relative to the longer text
+func DiffChangeRatio(ops []DiffOp) float64 {
+	changed, oldTotal, newTotal := 0, 0, 0
+	for _, op := range ops {
+		words := len(strings.Fields(op.Text))
+		switch op.Type {
+		case "equal":
+			oldTotal += words
+			newTotal += words
+		case "delete":
+			changed += words
+			oldTotal += words
+		case "insert":
+			changed += words
+			newTotal += words
+		}
+	}
+
+	total := oldTotal
+	if newTotal > total {
+		total = newTotal
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(changed) / float64(total)
+}
+
+func diffMiddle(oldWords []string, newWords []string) []DiffOp {
+	ops := []DiffOp{}
+
+	if len(oldWords)*len(newWords) > maxDiffMatrixCells {
+		ops = appendDiffOp(ops, "delete", oldWords)
+		return appendDiffOp(ops, "insert", newWords)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of oldWords[i:] and newWords[j:]
+	lcs := make([][]int32, len(oldWords)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(newWords)+1)
+	}
+	for i := len(oldWords) - 1; i >= 0; i-- {
+		for j := len(newWords) - 1; j >= 0; j-- {
+			if oldWords[i] == newWords[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(oldWords) && j < len(newWords) {
+		if oldWords[i] == newWords[j] {
+			ops = appendDiffOp(ops, "equal", oldWords[i:i+1])
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			ops = appendDiffOp(ops, "delete", oldWords[i:i+1])
+			i++
+		} else {
+			ops = appendDiffOp(ops, "insert", newWords[j:j+1])
+			j++
+		}
+	}
+	ops = appendDiffOp(ops, "delete", oldWords[i:])
+	ops = appendDiffOp(ops, "insert", newWords[j:])
+
+	return ops
+}
+
+// appendDiffOp adds the words to the diff, merging them into the last segment when it has the same type
+func appendDiffOp(ops []DiffOp, opType string, words []string) []DiffOp {
+	if len(words) == 0 {
+		return ops
+	}
+
+	text := strings.Join(words, " ")
+	if len(ops) > 0 && ops[len(ops)-1].Type == opType {
+		ops[len(ops)-1].Text += " " + text
+		return ops
+	}
+
+	return append(ops, DiffOp{Type: opType, Text: text})
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PRF holds precision, recall and F1, from 0 to 1
+type PRF struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+// MatchCounts counts the predicted items found in the expected ones, to be summed over a dataset
+// (micro averaging) before computing PRF
+type MatchCounts struct {
+	TruePositives int `json:"truePositives"`
+	Predicted     int `json:"predicted"`
+	Expected      int `json:"expected"`
+}
+
+// Add sums the counts
+func (counts MatchCounts) Add(other MatchCounts) MatchCounts {
+	return MatchCounts{
+		TruePositives: counts.TruePositives + other.TruePositives,
+		Predicted:     counts.Predicted + other.Predicted,
+		Expected:      counts.Expected + other.Expected,
+	}
+}
+
+// PRF computes precision, recall and F1 from the counts. Nothing expected and nothing predicted is a perfect score.
+func (counts MatchCounts) PRF() PRF {
+	if counts.Predicted == 0 && counts.Expected == 0 {
+		return PRF{Precision: 1, Recall: 1, F1: 1}
+	}
+
+	var scores PRF
+	if counts.Predicted > 0 {
+		scores.Precision = float64(counts.TruePositives) / float64(counts.Predicted)
+	}
+	if counts.Expected > 0 {
+		scores.Recall = float64(counts.TruePositives) / float64(counts.Expected)
+	}
+	if scores.Precision+scores.Recall > 0 {
+		scores.F1 = 2 * scores.Precision * scores.Recall / (scores.Precision + scores.Recall)
+	}
+	return scores
+}
+
+// MatchSets compares a predicted set of labels with the expected one, ignoring case, surrounding spaces and duplicates
+func MatchSets(expected []string, predicted []string) MatchCounts {
+	expectedSet := normalizedSet(expected)
+	predictedSet := normalizedSet(predicted)
+
+	counts := MatchCounts{Predicted: len(predictedSet), Expected: len(expectedSet)}
+	for label := range predictedSet {
+		if expectedSet[label] {
+			counts.TruePositives++
+		}
+	}
+	return counts
+}
+
+func normalizedSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != "" {
+			set[value] = true
+		}
+	}
+	return set
+}
+
+// RougeN scores the n-gram overlap of a candidate summary with a reference one
+func RougeN(reference string, candidate string, n int) PRF {
+	referenceGrams := countNGrams(rougeTokens(reference), n)
+	candidateGrams := countNGrams(rougeTokens(candidate), n)
+
+	counts := MatchCounts{}
+	for gram, count := range referenceGrams {
+		counts.Expected += count
+		if candidateCount := candidateGrams[gram]; candidateCount < count {
+			counts.TruePositives += candidateCount
+		} else {
+			counts.TruePositives += count
+		}
+	}
+	for _, count := range candidateGrams {
+		counts.Predicted += count
+	}
+
+	return counts.PRF()
+}
+
+// RougeL scores the longest common subsequence of words of a candidate summary and a reference one
+func RougeL(reference string, candidate string) PRF {
+	referenceTokens := rougeTokens(reference)
+	candidateTokens := rougeTokens(candidate)
+
+	// classic dynamic programming, one row at a time
+	previous := make([]int, len(candidateTokens)+1)
+	current := make([]int, len(candidateTokens)+1)
+	for i := 1; i <= len(referenceTokens); i++ {
+		for j := 1; j <= len(candidateTokens); j++ {
+			if referenceTokens[i-1] == candidateTokens[j-1] {
+				current[j] = previous[j-1] + 1
+			} else if previous[j] > current[j-1] {
+				current[j] = previous[j]
+			} else {
+				current[j] = current[j-1]
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return MatchCounts{
+		TruePositives: previous[len(candidateTokens)],
+		Predicted:     len(candidateTokens),
+		Expected:      len(referenceTokens),
+	}.PRF()
+}
+
+func rougeTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func countNGrams(tokens []string, n int) map[string]int {
+	grams := map[string]int{}
+	for i := 0; i+n <= len(tokens); i++ {
+		grams[strings.Join(tokens[i:i+n], " ")]++
+	}
+	return grams
+}
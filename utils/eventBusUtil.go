@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	envUtil "service-news-app-backend/config"
+	"sync"
+	"time"
+)
+
+// BusEvent is a message published on a topic of the event bus, e.g. "article:<id>"
+type BusEvent struct {
+	Topic     string          `json:"topic"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	Time      time.Time       `json:"time"`
+	Transient bool            `json:"transient"` // -- not kept for late subscribers, e.g. streamed tokens
+	Origin    string          `json:"origin"`    // -- instance that published it
+}
+
+// a subscriber that falls this far behind misses events rather than blocking the publishers
+const eventSubscriberBuffer = 256
+
+type eventTopic struct {
+	history     []BusEvent
+	subscribers map[chan BusEvent]bool
+	updatedAt   time.Time
+}
+
+var (
+	eventBusMutex  sync.Mutex
+	eventTopics    = map[string]*eventTopic{}
+	eventsPrunedAt time.Time
+	eventBusOrigin = newEventBusOrigin()
+)
+
+func newEventBusOrigin() string {
+	originBytes := make([]byte, 8)
+	rand.Read(originBytes)
+	return hex.EncodeToString(originBytes)
+}
+
+// PublishEvent delivers an event to the subscribers of the topic on this instance, and through the
+// EVENT_BUS_CHANNEL redis channel (default "event-bus") to the other instances when redis is configured
+func PublishEvent(ctx context.Context, topic string, eventType string, data interface{}) {
+	publishEvent(ctx, topic, eventType, data, false)
+}
+
+// PublishTransientEvent is PublishEvent for events late subscribers don't need, e.g. streamed tokens
+func PublishTransientEvent(ctx context.Context, topic string, eventType string, data interface{}) {
+	publishEvent(ctx, topic, eventType, data, true)
+}
+
+func publishEvent(ctx context.Context, topic string, eventType string, data interface{}, transient bool) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding %s event of %s: %v\n", eventType, topic, err)
+		return
+	}
+
+	event := BusEvent{
+		Topic:     topic,
+		Type:      eventType,
+		Data:      payload,
+		Time:      time.Now().UTC(),
+		Transient: transient,
+		Origin:    eventBusOrigin,
+	}
+	deliverEvent(event)
+
+	client := GetRedisClient()
+	if client == nil {
+		return
+	}
+	if err := client.Publish(ctx, eventBusChannel(), ConvertToJson(event)).Err(); err != nil {
+		log.Println("Error publishing event to redis: ", err)
+	}
+}
+
+// SubscribeEvents returns the events of the topic kept for late subscribers (published within
+// EVENT_HISTORY_MINUTES, default 10) and a channel receiving the next ones. unsubscribe closes the channel.
+func SubscribeEvents(topic string) (history []BusEvent, events <-chan BusEvent, unsubscribe func()) {
+	eventBusMutex.Lock()
+	defer eventBusMutex.Unlock()
+
+	subscriber := make(chan BusEvent, eventSubscriberBuffer)
+	state := getEventTopic(topic)
+	state.subscribers[subscriber] = true
+
+	cutoff := time.Now().Add(-time.Duration(envUtil.GetIntEnvironmentVariable("EVENT_HISTORY_MINUTES", 10)) * time.Minute)
+	history = []BusEvent{}
+	for _, event := range state.history {
+		if !event.Time.Before(cutoff) {
+			history = append(history, event)
+		}
+	}
+
+	unsubscribe = func() {
+		eventBusMutex.Lock()
+		defer eventBusMutex.Unlock()
+
+		if state.subscribers[subscriber] {
+			delete(state.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+
+	return history, subscriber, unsubscribe
+}
+
+// StartEventRelay delivers the events published by the other instances to the subscribers of this one,
+// until ctx is done. Without redis every instance only sees its own events.
+func StartEventRelay(ctx context.Context) {
+	client := GetRedisClient()
+	if client == nil {
+		return
+	}
+
+	pubsub := client.Subscribe(ctx, eventBusChannel())
+	go func() {
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var event BusEvent
+				if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+					log.Println("Error decoding relayed event: ", err)
+					continue
+				}
+				if event.Origin != eventBusOrigin {
+					deliverEvent(event)
+				}
+			}
+		}
+	}()
+}
+
+func deliverEvent(event BusEvent) {
+	eventBusMutex.Lock()
+	defer eventBusMutex.Unlock()
+
+	cutoff := time.Now().Add(-time.Duration(envUtil.GetIntEnvironmentVariable("EVENT_HISTORY_MINUTES", 10)) * time.Minute)
+	if eventsPrunedAt.Before(time.Now().Add(-time.Minute)) {
+		pruneEventTopics(cutoff)
+		eventsPrunedAt = time.Now()
+	}
+
+	state := getEventTopic(event.Topic)
+	for len(state.history) > 0 && state.history[0].Time.Before(cutoff) {
+		state.history = state.history[1:]
+	}
+	state.updatedAt = time.Now()
+	if !event.Transient {
+		state.history = append(state.history, event)
+	}
+
+	for subscriber := range state.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// getEventTopic returns the state of a topic, eventBusMutex must be held
+func getEventTopic(topic string) *eventTopic {
+	state, ok := eventTopics[topic]
+	if !ok {
+		state = &eventTopic{history: []BusEvent{}, subscribers: map[chan BusEvent]bool{}, updatedAt: time.Now()}
+		eventTopics[topic] = state
+	}
+	return state
+}
+
+// pruneEventTopics forgets the topics without subscribers and without events since cutoff,
+// eventBusMutex must be held
+func pruneEventTopics(cutoff time.Time) {
+	for topic, state := range eventTopics {
+		if len(state.subscribers) == 0 && state.updatedAt.Before(cutoff) {
+			delete(eventTopics, topic)
+		}
+	}
+}
+
+func eventBusChannel() string {
+	return envUtil.GetEnvironmentVariableOrDefault("EVENT_BUS_CHANNEL", "event-bus")
+}
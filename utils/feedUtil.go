@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// Feed is a format independent feed, rendered as RSS 2.0, Atom 1.0 or JSON Feed 1.1
+type Feed struct {
+	Title       string
+	Link        string // -- e.g., link to the HTML page of the feed
+	FeedUrl     string // -- e.g., the URL the feed itself is served from
+	Description string
+	Updated     time.Time
+	Items       []FeedItem
+}
+
+type FeedItem struct {
+	Id         string
+	Title      string
+	Link       string
+	Summary    string
+	Author     string // -- e.g., the publisher
+	Published  time.Time
+	Updated    time.Time
+	Categories []string
+	Entities   []string
+	Sentiment  string
+}
+
+// FeedContentTypes maps the supported feed formats to their content types
+var FeedContentTypes = map[string]string{
+	"rss":  "application/rss+xml; charset=utf-8",
+	"atom": "application/atom+xml; charset=utf-8",
+	"json": "application/feed+json; charset=utf-8",
+}
+
+// RenderFeed renders the feed in the given format ("rss", "atom" or "json")
+func RenderFeed(feed Feed, format string) ([]byte, error) {
+	switch format {
+	case "rss":
+		return renderRSS(feed)
+	case "atom":
+		return renderAtom(feed)
+	case "json":
+		return renderJSONFeed(feed)
+	default:
+		return nil, fmt.Errorf("unsupported feed format %q", format)
+	}
+}
+
+type rssCategory struct {
+	Domain string `xml:"domain,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+type rssGuid struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Guid        rssGuid       `xml:"guid"`
+	Description string        `xml:"description"`
+	Author      string        `xml:"dc:creator,omitempty"`
+	PubDate     string        `xml:"pubDate"`
+	Categories  []rssCategory `xml:"category"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DcNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+func renderRSS(feed Feed) ([]byte, error) {
+	channel := rssChannel{
+		Title:         feed.Title,
+		Link:          feed.Link,
+		Description:   feed.Description,
+		LastBuildDate: feed.Updated.UTC().Format(time.RFC1123Z),
+		AtomLink:      atomLink{Href: feed.FeedUrl, Rel: "self", Type: FeedContentTypes["rss"]},
+	}
+
+	for _, item := range feed.Items {
+		categories := []rssCategory{}
+		for _, category := range item.Categories {
+			categories = append(categories, rssCategory{Value: category})
+		}
+		for _, entity := range item.Entities {
+			categories = append(categories, rssCategory{Domain: "entity", Value: entity})
+		}
+
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Guid:        rssGuid{IsPermaLink: "false", Value: item.Id},
+			Description: item.Summary,
+			Author:      item.Author,
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+			Categories:  categories,
+		})
+	}
+
+	return marshalXML(rssDocument{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DcNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	})
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term   string `xml:"term,attr"`
+	Scheme string `xml:"scheme,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Id         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Summary    string         `xml:"summary"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomDocument struct {
+	XMLName  xml.Name    `xml:"feed"`
+	NS       string      `xml:"xmlns,attr"`
+	Id       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+func renderAtom(feed Feed) ([]byte, error) {
+	document := atomDocument{
+		NS:       "http://www.w3.org/2005/Atom",
+		Id:       feed.FeedUrl,
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		Updated:  feed.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feed.FeedUrl, Rel: "self", Type: FeedContentTypes["atom"]},
+			{Href: feed.Link, Rel: "alternate"},
+		},
+	}
+
+	for _, item := range feed.Items {
+		categories := []atomCategory{}
+		for _, category := range item.Categories {
+			categories = append(categories, atomCategory{Term: category})
+		}
+		for _, entity := range item.Entities {
+			categories = append(categories, atomCategory{Term: entity, Scheme: "entity"})
+		}
+
+		entry := atomEntry{
+			Id:         "urn:uuid:" + item.Id,
+			Title:      item.Title,
+			Link:       atomLink{Href: item.Link, Rel: "alternate"},
+			Summary:    item.Summary,
+			Published:  item.Published.UTC().Format(time.RFC3339),
+			Updated:    item.Updated.UTC().Format(time.RFC3339),
+			Categories: categories,
+		}
+		if item.Author != "" {
+			entry.Author = &atomAuthor{Name: item.Author}
+		}
+		document.Entries = append(document.Entries, entry)
+	}
+
+	return marshalXML(document)
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	Id            string           `json:"id"`
+	Url           string           `json:"url"`
+	Title         string           `json:"title"`
+	Summary       string           `json:"summary"`
+	ContentText   string           `json:"content_text"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags"`
+	Extension     map[string]any   `json:"_news"` // -- custom extension with the enrichment not covered by the spec
+}
+
+type jsonFeedDocument struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageUrl string         `json:"home_page_url"`
+	FeedUrl     string         `json:"feed_url"`
+	Description string         `json:"description"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+func renderJSONFeed(feed Feed) ([]byte, error) {
+	document := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageUrl: feed.Link,
+		FeedUrl:     feed.FeedUrl,
+		Description: feed.Description,
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, item := range feed.Items {
+		jsonItem := jsonFeedItem{
+			Id:            item.Id,
+			Url:           item.Link,
+			Title:         item.Title,
+			Summary:       item.Summary,
+			ContentText:   item.Summary,
+			DatePublished: item.Published.UTC().Format(time.RFC3339),
+			DateModified:  item.Updated.UTC().Format(time.RFC3339),
+			Tags:          append([]string{}, item.Categories...),
+			Extension: map[string]any{
+				"categories": item.Categories,
+				"entities":   item.Entities,
+				"sentiment":  item.Sentiment,
+			},
+		}
+		if item.Author != "" {
+			jsonItem.Authors = []jsonFeedAuthor{{Name: item.Author}}
+		}
+		document.Items = append(document.Items, jsonItem)
+	}
+
+	return json.MarshalIndent(document, "", "  ")
+}
+
+func marshalXML(document any) ([]byte, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
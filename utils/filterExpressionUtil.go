@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FilterExpression is a parsed boolean filter such as
+//
+//	category == "Economy" and (importance >= 60 or publisher == "Reuters") and not title ~ "opinion"
+//
+// Comparisons take a field on the left and a string or number literal on the right.
+// == and != test membership on list fields, ~ is a case-insensitive substring match,
+// and <, <=, >, >= compare numbers. Conditions combine with and/&&, or/|| and not/!.
+type FilterExpression interface {
+	Evaluate(document map[string]interface{}) bool
+}
+
+// ParseFilterExpression parses a filter expression, an empty expression matches everything
+func ParseFilterExpression(expression string) (FilterExpression, error) {
+	tokens, err := tokenizeFilterExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return matchAllExpression{}, nil
+	}
+
+	parser := &filterParser{tokens: tokens}
+	parsed, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.position < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", parser.tokens[parser.position].text, parser.position)
+	}
+
+	return parsed, nil
+}
+
+type filterTokenKind int
+
+const (
+	filterTokenIdentifier filterTokenKind = iota
+	filterTokenString
+	filterTokenNumber
+	filterTokenOperator
+	filterTokenOpenParen
+	filterTokenCloseParen
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+}
+
+func tokenizeFilterExpression(expression string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		current := runes[i]
+
+		switch {
+		case unicode.IsSpace(current):
+			i++
+		case current == '(':
+			tokens = append(tokens, filterToken{filterTokenOpenParen, "("})
+			i++
+		case current == ')':
+			tokens = append(tokens, filterToken{filterTokenCloseParen, ")"})
+			i++
+		case current == '"' || current == '\'':
+			var literal strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != current; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				literal.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, filterToken{filterTokenString, literal.String()})
+			i = j + 1
+		case unicode.IsDigit(current) || (current == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{filterTokenNumber, string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(current) || current == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			word := string(runes[i:j])
+			switch strings.ToLower(word) {
+			case "and", "or", "not":
+				tokens = append(tokens, filterToken{filterTokenOperator, strings.ToLower(word)})
+			default:
+				tokens = append(tokens, filterToken{filterTokenIdentifier, word})
+			}
+			i = j
+		default:
+			operator := ""
+			for _, candidate := range []string{"==", "!=", ">=", "<=", "&&", "||", ">", "<", "~", "!"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", current, i)
+			}
+
+			i += len(operator)
+
+			switch operator {
+			case "&&":
+				operator = "and"
+			case "||":
+				operator = "or"
+			case "!":
+				operator = "not"
+			}
+			tokens = append(tokens, filterToken{filterTokenOperator, operator})
+		}
+	}
+
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens   []filterToken
+	position int
+}
+
+func (parser *filterParser) peek() *filterToken {
+	if parser.position >= len(parser.tokens) {
+		return nil
+	}
+	return &parser.tokens[parser.position]
+}
+
+func (parser *filterParser) acceptOperator(operator string) bool {
+	token := parser.peek()
+	if token != nil && token.kind == filterTokenOperator && token.text == operator {
+		parser.position++
+		return true
+	}
+	return false
+}
+
+func (parser *filterParser) parseOr() (FilterExpression, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for parser.acceptOperator("or") {
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpression{left, right}
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseAnd() (FilterExpression, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for parser.acceptOperator("and") {
+		right, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpression{left, right}
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseUnary() (FilterExpression, error) {
+	if parser.acceptOperator("not") {
+		inner, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpression{inner}, nil
+	}
+
+	token := parser.peek()
+	if token == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	if token.kind == filterTokenOpenParen {
+		parser.position++
+		inner, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing := parser.peek()
+		if closing == nil || closing.kind != filterTokenCloseParen {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		parser.position++
+		return inner, nil
+	}
+
+	return parser.parseComparison()
+}
+
+func (parser *filterParser) parseComparison() (FilterExpression, error) {
+	field := parser.peek()
+	if field == nil || field.kind != filterTokenIdentifier {
+		return nil, fmt.Errorf("expected a field name at position %d", parser.position)
+	}
+	parser.position++
+
+	operator := parser.peek()
+	if operator == nil || operator.kind != filterTokenOperator || !Includes([]string{"==", "!=", ">=", "<=", ">", "<", "~"}, operator.text) {
+		return nil, fmt.Errorf("expected a comparison after %q", field.text)
+	}
+	parser.position++
+
+	value := parser.peek()
+	if value == nil || (value.kind != filterTokenString && value.kind != filterTokenNumber) {
+		return nil, fmt.Errorf("expected a value after %q %s", field.text, operator.text)
+	}
+	parser.position++
+
+	comparison := comparisonExpression{field: field.text, operator: operator.text, value: value.text}
+	if value.kind == filterTokenNumber {
+		number, err := strconv.ParseFloat(value.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", value.text)
+		}
+		comparison.number = &number
+	} else if Includes([]string{">=", "<=", ">", "<"}, operator.text) {
+		return nil, fmt.Errorf("%s needs a number", operator.text)
+	}
+
+	return comparison, nil
+}
+
+type matchAllExpression struct{}
+
+func (matchAllExpression) Evaluate(document map[string]interface{}) bool { return true }
+
+type andExpression struct{ left, right FilterExpression }
+
+func (expression andExpression) Evaluate(document map[string]interface{}) bool {
+	return expression.left.Evaluate(document) && expression.right.Evaluate(document)
+}
+
+type orExpression struct{ left, right FilterExpression }
+
+func (expression orExpression) Evaluate(document map[string]interface{}) bool {
+	return expression.left.Evaluate(document) || expression.right.Evaluate(document)
+}
+
+type notExpression struct{ inner FilterExpression }
+
+func (expression notExpression) Evaluate(document map[string]interface{}) bool {
+	return !expression.inner.Evaluate(document)
+}
+
+type comparisonExpression struct {
+	field    string
+	operator string
+	value    string
+	number   *float64
+}
+
+func (expression comparisonExpression) Evaluate(document map[string]interface{}) bool {
+	fieldValue, ok := document[expression.field]
+	if !ok {
+		return expression.operator == "!="
+	}
+
+	switch typed := fieldValue.(type) {
+	case []string:
+		matched := false
+		for _, item := range typed {
+			if expression.matchString(item) {
+				matched = true
+				break
+			}
+		}
+		if expression.operator == "!=" {
+			return !matched
+		}
+		return matched
+	case string:
+		if expression.operator == "!=" {
+			return !strings.EqualFold(typed, expression.value)
+		}
+		return expression.matchString(typed)
+	case int:
+		return expression.compareNumber(float64(typed))
+	case float64:
+		return expression.compareNumber(typed)
+	default:
+		return false
+	}
+}
+
+func (expression comparisonExpression) matchString(value string) bool {
+	switch expression.operator {
+	case "==", "!=":
+		return strings.EqualFold(value, expression.value)
+	case "~":
+		return strings.Contains(strings.ToLower(value), strings.ToLower(expression.value))
+	default:
+		return false
+	}
+}
+
+func (expression comparisonExpression) compareNumber(value float64) bool {
+	if expression.number == nil {
+		return false
+	}
+
+	switch expression.operator {
+	case "==":
+		return value == *expression.number
+	case "!=":
+		return value != *expression.number
+	case ">=":
+		return value >= *expression.number
+	case "<=":
+		return value <= *expression.number
+	case ">":
+		return value > *expression.number
+	case "<":
+		return value < *expression.number
+	default:
+		return false
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextSpan is a part of a text, as byte offsets
+type TextSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"` // -- byte offset just past the span
+}
+
+// query words too common to be worth highlighting
+var highlightStopwords = append([]string{"a", "an", "or", "are", "be", "by", "at", "as", "it", "from", "what", "who", "how"}, languageStopwords["en"]...)
+
+// HighlightSpans finds the words of the text matching the terms of a search query, ignoring case and
+// simple inflections (e.g. "elections" matches "election"). Excluded terms (-word) and stopwords are not
+// highlighted, and matches only separated by spaces are joined so phrases come out as one span.
+func HighlightSpans(text string, query string) []TextSpan {
+	terms := map[string]bool{}
+	for _, word := range strings.Fields(query) {
+		if strings.HasPrefix(word, "-") || word == "OR" {
+			continue
+		}
+		for _, term := range textWords(word) {
+			lowered := strings.ToLower(word[term.Start:term.End])
+			if !Includes(highlightStopwords, lowered) {
+				terms[stemWord(lowered)] = true
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return []TextSpan{}
+	}
+
+	spans := []TextSpan{}
+	for _, word := range textWords(text) {
+		if !terms[stemWord(strings.ToLower(text[word.Start:word.End]))] {
+			continue
+		}
+		if last := len(spans) - 1; last >= 0 && strings.TrimSpace(text[spans[last].End:word.Start]) == "" {
+			spans[last].End = word.End
+			continue
+		}
+		spans = append(spans, word)
+	}
+
+	return spans
+}
+
+// HighlightHTML escapes the text for HTML and wraps the spans in <mark> tags
+func HighlightHTML(text string, spans []TextSpan) string {
+	sorted := append([]TextSpan{}, spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var builder strings.Builder
+	position := 0
+	for _, span := range sorted {
+		if span.Start < position || span.End > len(text) || span.Start >= span.End {
+			continue
+		}
+		builder.WriteString(html.EscapeString(text[position:span.Start]))
+		builder.WriteString("<mark>")
+		builder.WriteString(html.EscapeString(text[span.Start:span.End]))
+		builder.WriteString("</mark>")
+		position = span.End
+	}
+	builder.WriteString(html.EscapeString(text[position:]))
+
+	return builder.String()
+}
+
+// textWords returns the runs of letters and digits of the text
+func textWords(text string) []TextSpan {
+	words := []TextSpan{}
+	start := -1
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+		if isWordRune && start < 0 {
+			start = i
+		}
+		if !isWordRune && start >= 0 {
+			words = append(words, TextSpan{Start: start, End: i})
+			start = -1
+		}
+		i += size
+	}
+	if start >= 0 {
+		words = append(words, TextSpan{Start: start, End: len(text)})
+	}
+	return words
+}
+
+// stemWord strips the common English inflections of a lowercase word, enough to match plurals and tenses
+// (e.g. "vote", "votes", "voted" and "voting" share "vot")
+func stemWord(word string) string {
+	for _, suffix := range []string{"ies", "ing", "ed", "s", "ly"} {
+		if !strings.HasSuffix(word, suffix) || len(word)-len(suffix) < 3 || (suffix == "s" && strings.HasSuffix(word, "ss")) {
+			continue
+		}
+		word = strings.TrimSuffix(word, suffix)
+		if suffix == "ies" {
+			word += "y"
+		}
+		break
+	}
+	if len(word) > 3 {
+		word = strings.TrimSuffix(word, "e")
+	}
+	return word
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaFieldError is a value of an LLM answer that doesn't match the JSON schema it was asked for
+type SchemaFieldError struct {
+	Field   string `json:"field"` // -- e.g., "sentimentScore" or "entities.locations[1]", empty for the whole answer
+	Message string `json:"message"`
+}
+
+func (fieldError SchemaFieldError) Error() string {
+	if fieldError.Field == "" {
+		return fieldError.Message
+	}
+	return fieldError.Field + ": " + fieldError.Message
+}
+
+// JSONSchema derives the JSON schema of the type of value from its fields and json tags. Every field is
+// required and no other property is allowed. The enum ("a,b,c"), minimum, maximum and description tags
+// narrow a field down, e.g. `json:"sentimentScore" enum:"Positive,Negative,Neutral"`.
+func JSONSchema(value interface{}) map[string]interface{} {
+	return jsonSchemaOf(reflect.TypeOf(value))
+}
+
+func jsonSchemaOf(valueType reflect.Type) map[string]interface{} {
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	switch valueType.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaOf(valueType.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaOf(valueType.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < valueType.NumField(); i++ {
+			field := valueType.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			schema := jsonSchemaOf(field.Type)
+			if enum := field.Tag.Get("enum"); enum != "" {
+				schema["enum"] = strings.Split(enum, ",")
+			}
+			if minimum, err := strconv.ParseFloat(field.Tag.Get("minimum"), 64); err == nil {
+				schema["minimum"] = minimum
+			}
+			if maximum, err := strconv.ParseFloat(field.Tag.Get("maximum"), 64); err == nil {
+				schema["maximum"] = maximum
+			}
+			if description := field.Tag.Get("description"); description != "" {
+				schema["description"] = description
+			}
+
+			properties[name] = schema
+			required = append(required, name)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		// interfaces take any value
+		return map[string]interface{}{}
+	}
+}
+
+// ValidateJSONSchema checks a decoded JSON value against a schema built by JSONSchema and returns every mismatch
+func ValidateJSONSchema(schema map[string]interface{}, value interface{}) []SchemaFieldError {
+	return validateJSONSchema(schema, value, "")
+}
+
+func validateJSONSchema(schema map[string]interface{}, value interface{}, field string) []SchemaFieldError {
+	invalid := func(format string, args ...interface{}) []SchemaFieldError {
+		return []SchemaFieldError{{Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	schemaType, _ := schema["type"].(string)
+	switch schemaType {
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return invalid("expected a string, got %s", jsonTypeName(value))
+		}
+		if enum, ok := schema["enum"].([]string); ok && !Includes(enum, text) {
+			return invalid("%q is not one of %s", text, strings.Join(enum, ", "))
+		}
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok {
+			return invalid("expected a number, got %s", jsonTypeName(value))
+		}
+		if schemaType == "integer" && number != math.Trunc(number) {
+			return invalid("expected an integer, got %v", number)
+		}
+		if minimum, ok := schema["minimum"].(float64); ok && number < minimum {
+			return invalid("%v is below the minimum of %v", number, minimum)
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && number > maximum {
+			return invalid("%v is above the maximum of %v", number, maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return invalid("expected a boolean, got %s", jsonTypeName(value))
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return invalid("expected an array, got %s", jsonTypeName(value))
+		}
+		itemSchema, _ := schema["items"].(map[string]interface{})
+		fieldErrors := []SchemaFieldError{}
+		for i, item := range items {
+			fieldErrors = append(fieldErrors, validateJSONSchema(itemSchema, item, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+		return fieldErrors
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid("expected an object, got %s", jsonTypeName(value))
+		}
+		return validateJSONObject(schema, object, field)
+	}
+
+	return nil
+}
+
+func validateJSONObject(schema map[string]interface{}, object map[string]interface{}, field string) []SchemaFieldError {
+	fieldErrors := []SchemaFieldError{}
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	required, _ := schema["required"].([]string)
+	for _, name := range required {
+		if _, ok := object[name]; !ok {
+			fieldErrors = append(fieldErrors, SchemaFieldError{Field: joinSchemaField(field, name), Message: "is missing"})
+		}
+	}
+
+	names := []string{}
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if propertySchema, ok := properties[name].(map[string]interface{}); ok {
+			fieldErrors = append(fieldErrors, validateJSONSchema(propertySchema, object[name], joinSchemaField(field, name))...)
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				fieldErrors = append(fieldErrors, SchemaFieldError{Field: joinSchemaField(field, name), Message: "is not an allowed property"})
+			}
+		case map[string]interface{}:
+			fieldErrors = append(fieldErrors, validateJSONSchema(additional, object[name], joinSchemaField(field, name))...)
+		}
+	}
+
+	return fieldErrors
+}
+
+func joinSchemaField(field string, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// DecodeJSONSchema decodes an LLM answer into target after checking it against the schema of target. The
+// top-level fields that don't match are left out and returned as field errors, the valid ones are decoded.
+// It fails only when the answer is not a JSON object.
+func DecodeJSONSchema(content string, schema map[string]interface{}, target interface{}) ([]SchemaFieldError, error) {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(trimCodeFence(content)), &object); err != nil || object == nil {
+		return nil, fmt.Errorf("answer is not a JSON object: %s", content)
+	}
+
+	fieldErrors := ValidateJSONSchema(schema, object)
+	for _, fieldError := range fieldErrors {
+		// "entities.locations[1]" invalidates "entities"
+		name := strings.SplitN(strings.SplitN(fieldError.Field, ".", 2)[0], "[", 2)[0]
+		delete(object, name)
+	}
+
+	if err := json.Unmarshal(ConvertToJson(object), target); err != nil {
+		return fieldErrors, err
+	}
+	return fieldErrors, nil
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scripts that identify a language on their own, checked in order
+var languageScripts = []struct {
+	language string
+	table    *unicode.RangeTable
+}{
+	{"hi", unicode.Devanagari},
+	{"bn", unicode.Bengali},
+	{"ta", unicode.Tamil},
+	{"te", unicode.Telugu},
+	{"gu", unicode.Gujarati},
+	{"pa", unicode.Gurmukhi},
+	{"ur", unicode.Arabic},
+	{"ru", unicode.Cyrillic},
+	{"zh", unicode.Han},
+}
+
+// frequent function words of the Latin script languages we receive
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "that", "for", "was", "with", "on", "said"},
+	"es": {"el", "la", "de", "que", "y", "en", "los", "las", "por", "una", "con", "para"},
+	"fr": {"le", "la", "les", "de", "et", "des", "est", "une", "du", "que", "pour", "dans"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "mit", "den", "von", "zu", "ein", "auf"},
+	"pt": {"o", "a", "de", "que", "e", "do", "da", "em", "um", "para", "com", "não"},
+	"it": {"il", "di", "che", "e", "la", "per", "un", "non", "con", "del", "della", "sono"},
+}
+
+// DetectLanguage guesses the ISO 639-1 code of a text from its script, or for Latin script
+// from its most frequent function words. It returns "" when there is nothing to go on.
+func DetectLanguage(text string) string {
+	if len(text) > 5000 {
+		text = text[:5000]
+	}
+
+	letters := 0
+	scriptCounts := map[string]int{}
+	for _, character := range text {
+		if !unicode.IsLetter(character) {
+			continue
+		}
+		letters++
+		for _, script := range languageScripts {
+			if unicode.Is(script.table, character) {
+				scriptCounts[script.language]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	for _, script := range languageScripts {
+		if scriptCounts[script.language]*3 >= letters {
+			return script.language
+		}
+	}
+
+	wordCounts := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(character rune) bool {
+		return !unicode.IsLetter(character)
+	}) {
+		wordCounts[word]++
+	}
+
+	bestLanguage, bestScore := "", 0
+	for _, language := range []string{"en", "es", "fr", "de", "pt", "it"} {
+		score := 0
+		for _, stopword := range languageStopwords[language] {
+			score += wordCounts[stopword]
+		}
+		if score > bestScore {
+			bestLanguage, bestScore = language, score
+		}
+	}
+
+	return bestLanguage
+}
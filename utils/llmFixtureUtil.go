@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	envUtil "service-news-app-backend/config"
+	"sync"
+)
+
+// LLMFixture is a recorded exchange with the LLM API, stored as <key>.json in the fixtures directory
+type LLMFixture struct {
+	Key          string            `json:"key"`
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`
+	RequestBody  string            `json:"requestBody"` // -- normalized, see NormalizeLLMRequestBody
+	StatusCode   int               `json:"statusCode"`
+	Header       map[string]string `json:"header"`
+	ResponseBody string            `json:"responseBody"` // -- raw, server-sent events included
+}
+
+// ErrUnmatchedLLMRequest is returned in replay mode for a request that has no fixture
+var ErrUnmatchedLLMRequest = errors.New("no recorded LLM fixture for request")
+
+// response headers worth replaying, the others (dates, request ids, rate limit counters) change on every call
+var llmFixtureHeaders = []string{"Content-Type"}
+
+var (
+	llmTransportMutex sync.RWMutex
+	llmTransport      http.RoundTripper
+)
+
+// GetLLMTransport returns the transport of the HTTP calls to the LLM API (completions and embeddings). LLM_HTTP_MODE
+// picks it unless SetLLMTransport replaced it: "record" saves the successful exchanges to LLM_FIXTURES_DIR, "replay" serves
+// them from there without network and fails on any request that wasn't recorded, anything else goes to the API.
+func GetLLMTransport() http.RoundTripper {
+	llmTransportMutex.RLock()
+	transport := llmTransport
+	llmTransportMutex.RUnlock()
+	if transport != nil {
+		return transport
+	}
+
+	fixturesDir := envUtil.GetEnvironmentVariableOrDefault("LLM_FIXTURES_DIR", "testdata/llm_fixtures")
+	switch envUtil.GetEnvironmentVariableOrDefault("LLM_HTTP_MODE", "") {
+	case "record":
+		return &RecordingLLMTransport{Transport: http.DefaultTransport, Dir: fixturesDir}
+	case "replay":
+		return &ReplayLLMTransport{Dir: fixturesDir}
+	default:
+		return http.DefaultTransport
+	}
+}
+
+// SetLLMTransport sends the HTTP calls to the LLM API through the given transport, nil goes back to LLM_HTTP_MODE.
+// Tests use it to replay fixtures: SetLLMTransport(&ReplayLLMTransport{Dir: "testdata/llm_fixtures"}).
+func SetLLMTransport(transport http.RoundTripper) {
+	llmTransportMutex.Lock()
+	llmTransport = transport
+	llmTransportMutex.Unlock()
+}
+
+// llmHTTPClient is the client of the calls to the LLM API
+func llmHTTPClient() *http.Client {
+	return &http.Client{Transport: GetLLMTransport()}
+}
+
+// NormalizeLLMRequestBody rewrites a JSON body with sorted keys and no insignificant whitespace, so the same
+// request always gets the same fixture key. Bodies that aren't JSON are kept as is.
+func NormalizeLLMRequestBody(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return normalized
+}
+
+// LLMFixtureKey identifies a request by its method, URL and normalized body. Headers, the API key among them,
+// are left out.
+func LLMFixtureKey(method string, url string, normalizedBody []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", method, url)
+	hash.Write(normalizedBody)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// readLLMRequest reads the body of the request, leaving it readable for the real transport, and returns its key
+func readLLMRequest(request *http.Request) (string, []byte, error) {
+	body := []byte{}
+	if request.Body != nil {
+		var err error
+		body, err = io.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return "", nil, err
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	normalized := NormalizeLLMRequestBody(body)
+	return LLMFixtureKey(request.Method, request.URL.String(), normalized), normalized, nil
+}
+
+// RecordingLLMTransport passes the requests to Transport and saves the successful exchanges as fixtures in Dir.
+// Rate limits and server errors are not recorded, a replay would otherwise keep failing on them.
+type RecordingLLMTransport struct {
+	Transport http.RoundTripper
+	Dir       string
+}
+
+func (transport *RecordingLLMTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	key, normalized, err := readLLMRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := transport.Transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response, nil
+	}
+
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := LLMFixture{
+		Key:          key,
+		Method:       request.Method,
+		URL:          request.URL.String(),
+		RequestBody:  string(normalized),
+		StatusCode:   response.StatusCode,
+		Header:       map[string]string{},
+		ResponseBody: string(body),
+	}
+	for _, name := range llmFixtureHeaders {
+		if value := response.Header.Get(name); value != "" {
+			fixture.Header[name] = value
+		}
+	}
+
+	// a fixture that can't be saved only costs a re-recording, the call itself went through
+	if err := writeLLMFixture(transport.Dir, fixture); err != nil {
+		log.Printf("Error saving LLM fixture %s: %v\n", key, err)
+	}
+
+	return response, nil
+}
+
+func writeLLMFixture(dir string, fixture LLMFixture) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, fixture.Key+".json"), data, 0o644)
+}
+
+// ReplayLLMTransport serves the fixtures saved in Dir and never touches the network. A request without a
+// fixture fails with ErrUnmatchedLLMRequest, naming the request so the fixture can be recorded.
+type ReplayLLMTransport struct {
+	Dir string
+}
+
+func (transport *ReplayLLMTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	key, normalized, err := readLLMRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(transport.Dir, key+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			preview := string(normalized)
+			if len(preview) > 300 {
+				preview = preview[:300] + "..."
+			}
+			log.Printf("Unmatched LLM request %s %s (key %s) in %s: %s\n", request.Method, request.URL, key, transport.Dir, preview)
+			return nil, fmt.Errorf("%w %s %s (key %s), record it with LLM_HTTP_MODE=record", ErrUnmatchedLLMRequest, request.Method, request.URL, key)
+		}
+		return nil, err
+	}
+
+	var fixture LLMFixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid LLM fixture %s: %v", key, err)
+	}
+
+	header := http.Header{}
+	for name, value := range fixture.Header {
+		header.Set(name, value)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.StatusCode, http.StatusText(fixture.StatusCode)),
+		StatusCode:    fixture.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(fixture.ResponseBody))),
+		ContentLength: int64(len(fixture.ResponseBody)),
+		Request:       request,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func postLLMRequest(t *testing.T, transport http.RoundTripper, url string, body string) (*http.Response, error) {
+	t.Helper()
+
+	request, err := http.NewRequest("POST", url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer secret-key")
+	return (&http.Client{Transport: transport}).Do(request)
+}
+
+func TestRecordingLLMTransportRecordsOnlySuccessfulResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"choices":[{"message":{"content":"hello"}}]}`))
+		case "/v1/rate_limited":
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder := &RecordingLLMTransport{Transport: http.DefaultTransport, Dir: dir}
+	for _, path := range []string{"/v1/chat/completions", "/v1/rate_limited", "/v1/unavailable"} {
+		response, err := postLLMRequest(t, recorder, server.URL+path, `{"model": "gpt-4o-mini"}`)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		response.Body.Close()
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("recorded %d fixtures, want only the successful one", len(files))
+	}
+	data, _ := os.ReadFile(dir + "/" + files[0].Name())
+	if strings.Contains(string(data), "secret-key") {
+		t.Error("the fixture holds the API key")
+	}
+
+	// keys are reordered and spaces dropped, the recorded request still matches
+	replay := &ReplayLLMTransport{Dir: dir}
+	response, err := postLLMRequest(t, replay, server.URL+"/v1/chat/completions", `{"model":"gpt-4o-mini"}`)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || string(body) != `{"choices":[{"message":{"content":"hello"}}]}` {
+		t.Errorf("replayed %d %s", response.StatusCode, body)
+	}
+	if response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("content type = %q", response.Header.Get("Content-Type"))
+	}
+
+	_, err = postLLMRequest(t, replay, server.URL+"/v1/rate_limited", `{"model":"gpt-4o-mini"}`)
+	if !errors.Is(err, ErrUnmatchedLLMRequest) {
+		t.Errorf("err = %v, want ErrUnmatchedLLMRequest for the rate limited request", err)
+	}
+}
+
+func TestNormalizeLLMRequestBody(t *testing.T) {
+	first := NormalizeLLMRequestBody([]byte(`{"temperature": 0.30, "model": "gpt-4o-mini", "messages": [{"role": "user"}]}`))
+	second := NormalizeLLMRequestBody([]byte(`{"messages":[{"role":"user"}],"model":"gpt-4o-mini","temperature":0.30}`))
+	if string(first) != string(second) {
+		t.Errorf("%s != %s", first, second)
+	}
+
+	if body := NormalizeLLMRequestBody([]byte("not json")); string(body) != "not json" {
+		t.Errorf("non JSON body rewritten to %s", body)
+	}
+}
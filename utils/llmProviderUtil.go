@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"context"
+	"log"
+	envUtil "service-news-app-backend/config"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// LLMRequest is a chat completion request. Prompt runs also carry the prompt name, version and the
+// variables the templates were rendered with.
+type LLMRequest struct {
+	Model         string
+	Temperature   float64
+	SystemPrompt  string
+	Messages      []map[string]interface{}
+	PromptName    string
+	PromptVersion string
+	Variables     map[string]interface{}
+	Tool          *LLMTool // -- set to constrain the answer to the JSON schema of the tool parameters
+}
+
+// LLMTool is a function the model is made to call. Its answer is then the JSON arguments of the call,
+// shaped by the JSON schema of the parameters.
+type LLMTool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// LLMProvider answers chat completion requests
+type LLMProvider interface {
+	Name() string
+	Complete(ctx context.Context, request LLMRequest) (string, error)
+	Stream(ctx context.Context, request LLMRequest, onDelta func(string) error) (string, error)
+}
+
+var (
+	llmProviderMutex sync.RWMutex
+	llmProvider      LLMProvider
+)
+
+// GetLLMProvider returns the provider the LLM calls go to, the one named by LLM_PROVIDER
+// ("openai", "local" or "fake", default "openai") unless SetLLMProvider replaced it. OpenAI calls get
+// retries, a circuit breaker and fallback models (see ResilientLLMProvider).
+func GetLLMProvider() LLMProvider {
+	llmProviderMutex.RLock()
+	provider := llmProvider
+	llmProviderMutex.RUnlock()
+	if provider != nil {
+		return provider
+	}
+
+	return newNamedLLMProvider(envUtil.GetEnvironmentVariableOrDefault("LLM_PROVIDER", "openai"))
+}
+
+// GetStepLLMProvider returns the provider of an enrichment step, named after its prompt (e.g. "metadata" or
+// "summary"): the one named by <STEP>_PROVIDER, e.g. SUMMARY_PROVIDER=local, or GetLLMProvider when it is
+// not set. A provider set with SetLLMProvider takes every step.
+func GetStepLLMProvider(step string) LLMProvider {
+	llmProviderMutex.RLock()
+	provider := llmProvider
+	llmProviderMutex.RUnlock()
+	if provider != nil {
+		return provider
+	}
+
+	name := envUtil.GetEnvironmentVariableOrDefault(strings.ToUpper(step)+"_PROVIDER", "")
+	if name == "" {
+		return GetLLMProvider()
+	}
+	return newNamedLLMProvider(name)
+}
+
+func newNamedLLMProvider(name string) LLMProvider {
+	switch name {
+	case "fake":
+		return FakeLLMProvider{}
+	case "local":
+		// a local server has no other model to fall back to
+		return &ResilientLLMProvider{Provider: NewLocalLLMProvider()}
+	case "openai":
+	default:
+		log.Printf("Unknown LLM provider %q, using openai\n", name)
+	}
+	return NewResilientLLMProvider(OpenAIProvider{})
+}
+
+// SetLLMProvider sends the LLM calls to the given provider, nil goes back to LLM_PROVIDER
+func SetLLMProvider(provider LLMProvider) {
+	llmProviderMutex.Lock()
+	llmProvider = provider
+	llmProviderMutex.Unlock()
+}
+
+// streamWhole turns a complete answer into a stream of a single delta
+func streamWhole(response string, err error) func(onDelta func(string) error) (string, error) {
+	return func(onDelta func(string) error) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		return response, onDelta(response)
+	}
+}
+
+// FakeLLMProvider answers the known prompts with deterministic heuristics instead of a model: keyword
+// categories and sentiment, capitalized phrases as entities and the lead sentences as summary.
+// It makes the pipeline runnable offline, in development and in evaluations.
+type FakeLLMProvider struct{}
+
+func (FakeLLMProvider) Name() string { return "fake" }
+
+func (FakeLLMProvider) Complete(ctx context.Context, request LLMRequest) (string, error) {
+	content, _ := request.Variables["Content"].(string)
+	title, _ := request.Variables["Title"].(string)
+
+	switch request.PromptName {
+	case "metadata":
+		categories := fakeCategories(content)
+		confidence := 80
+		if len(categories) == 1 && categories[0] == "General" {
+			confidence = 40
+		}
+		return string(ConvertToJson(map[string]interface{}{
+			"sentimentScore": fakeSentiment(content),
+			"categories":     categories,
+			"entities":       fakeEntities(content),
+			"confidence":     confidence,
+		})), nil
+	case "summary":
+		return leadSentences(content, 2), nil
+	case "importance":
+		return `{"importance": 50}`, nil
+	case "digest":
+		return `{"intro": "Here are today's top stories.", "blurbs": {}}`, nil
+	case "grounded_answer":
+		return InsufficientEvidenceAnswer, nil
+	default:
+		return title, nil
+	}
+}
+
+func (provider FakeLLMProvider) Stream(ctx context.Context, request LLMRequest, onDelta func(string) error) (string, error) {
+	return streamWhole(provider.Complete(ctx, request))(onDelta)
+}
+
+var fakeCategoryKeywords = map[string][]string{
+	"Politics":          {"election", "minister", "parliament", "government", "party", "vote", "president"},
+	"Economy":           {"economy", "market", "inflation", "bank", "stock", "trade", "gdp", "budget"},
+	"National Security": {"army", "military", "border", "attack", "defence", "defense", "terror"},
+	"Sports":            {"cricket", "football", "match", "tournament", "olympic", "championship"},
+	"Technology":        {"technology", "software", "startup", "artificial intelligence", "smartphone", "internet"},
+	"Health":            {"health", "hospital", "disease", "vaccine", "doctor", "virus"},
+}
+
+var fakeSentimentKeywords = map[string][]string{
+	"Positive": {"win", "growth", "success", "record", "improve", "celebrate", "gain"},
+	"Negative": {"death", "killed", "attack", "crisis", "loss", "decline", "protest", "crash"},
+}
+
+var fakeOrganizationWords = []string{"Ministry", "Bank", "Army", "Party", "Council", "Court", "Inc", "Ltd", "Corporation", "Association", "University"}
+
+func fakeCategories(content string) []string {
+	text := strings.ToLower(content)
+	categories := []string{}
+	for category, keywords := range fakeCategoryKeywords {
+		for _, keyword := range keywords {
+			if strings.Contains(text, keyword) {
+				categories = append(categories, category)
+				break
+			}
+		}
+	}
+	if len(categories) == 0 {
+		return []string{"General"}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+func fakeSentiment(content string) string {
+	text := strings.ToLower(content)
+	scores := map[string]int{}
+	for sentiment, keywords := range fakeSentimentKeywords {
+		for _, keyword := range keywords {
+			scores[sentiment] += strings.Count(text, keyword)
+		}
+	}
+
+	switch {
+	case scores["Positive"] > scores["Negative"]:
+		return "Positive"
+	case scores["Negative"] > scores["Positive"]:
+		return "Negative"
+	default:
+		return "Neutral"
+	}
+}
+
+// fakeEntities takes runs of capitalized words that don't start a sentence as entities: organizations when
+// they contain an organization word, people when they are two or three words long, places otherwise
+func fakeEntities(content string) Entities {
+	entities := Entities{Organizations: []string{}, Locations: []string{}, Individuals: []string{}}
+	seen := map[string]bool{}
+
+	addEntity := func(words []string) {
+		if len(words) == 0 {
+			return
+		}
+		name := strings.Join(words, " ")
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+
+		switch {
+		case containsAny(words, fakeOrganizationWords):
+			entities.Organizations = append(entities.Organizations, name)
+		case len(words) == 2 || len(words) == 3:
+			entities.Individuals = append(entities.Individuals, name)
+		default:
+			entities.Locations = append(entities.Locations, name)
+		}
+	}
+
+	run := []string{}
+	sentenceStart := true
+	for _, field := range strings.Fields(content) {
+		word := strings.TrimFunc(field, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		capitalized := word != "" && unicode.IsUpper([]rune(word)[0])
+
+		if capitalized && !sentenceStart {
+			run = append(run, word)
+		} else {
+			addEntity(run)
+			run = []string{}
+		}
+
+		sentenceStart = strings.HasSuffix(field, ".") || strings.HasSuffix(field, "!") || strings.HasSuffix(field, "?")
+		if word != field && !sentenceStart {
+			// punctuation ends the name
+			addEntity(run)
+			run = []string{}
+		}
+	}
+	addEntity(run)
+
+	return entities
+}
+
+func containsAny(words []string, candidates []string) bool {
+	for _, word := range words {
+		if Includes(candidates, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// leadSentences returns the first sentences of the text
+func leadSentences(text string, count int) string {
+	var lead strings.Builder
+	sentences := 0
+	for _, field := range strings.Fields(text) {
+		if lead.Len() > 0 {
+			lead.WriteString(" ")
+		}
+		lead.WriteString(field)
+
+		if strings.HasSuffix(field, ".") || strings.HasSuffix(field, "!") || strings.HasSuffix(field, "?") {
+			sentences++
+			if sentences == count {
+				break
+			}
+		}
+	}
+	return lead.String()
+}
@@ -0,0 +1,331 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	envUtil "service-news-app-backend/config"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kinds of LLM errors, match them with errors.Is
+var (
+	ErrLLMRateLimited           = errors.New("LLM rate limit exceeded")
+	ErrLLMContextLengthExceeded = errors.New("LLM context length exceeded")
+	ErrLLMContentFiltered       = errors.New("LLM content filtered")
+	ErrLLMMalformedResponse     = errors.New("malformed LLM response")
+	ErrLLMUnavailable           = errors.New("LLM unavailable")
+	ErrLLMCircuitOpen           = errors.New("LLM circuit breaker open")
+	ErrLLMRequestRejected       = errors.New("LLM request rejected")
+)
+
+// LLMError is a failed LLM call, Kind is one of the ErrLLM errors
+type LLMError struct {
+	Kind       error
+	Model      string
+	StatusCode int           // -- HTTP status, 0 when the call didn't get an answer
+	Message    string        // -- as given by the API
+	RetryAfter time.Duration // -- as asked by the API, 0 when it didn't say
+}
+
+func (llmError *LLMError) Error() string {
+	message := fmt.Sprintf("%v (model %s", llmError.Kind, llmError.Model)
+	if llmError.StatusCode != 0 {
+		message += fmt.Sprintf(", status %d", llmError.StatusCode)
+	}
+	message += ")"
+	if llmError.Message != "" {
+		message += ": " + llmError.Message
+	}
+	return message
+}
+
+func (llmError *LLMError) Unwrap() error {
+	return llmError.Kind
+}
+
+// newLLMResponseError classifies an error answer of the OpenAI API
+func newLLMResponseError(model string, response *http.Response, body []byte) *LLMError {
+	var responseBody struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &responseBody)
+
+	llmError := &LLMError{
+		Kind:       ErrLLMMalformedResponse,
+		Model:      model,
+		StatusCode: response.StatusCode,
+		Message:    responseBody.Error.Message,
+		RetryAfter: parseRetryAfter(response.Header.Get("Retry-After")),
+	}
+
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests:
+		llmError.Kind = ErrLLMRateLimited
+	case responseBody.Error.Code == "context_length_exceeded":
+		llmError.Kind = ErrLLMContextLengthExceeded
+	case responseBody.Error.Code == "content_filter" || responseBody.Error.Code == "content_policy_violation":
+		llmError.Kind = ErrLLMContentFiltered
+	case response.StatusCode >= 500:
+		llmError.Kind = ErrLLMUnavailable
+	case responseBody.Error.Message != "":
+		// any other rejection of the request, e.g. a bad API key, is not worth retrying
+		llmError.Kind = ErrLLMRequestRejected
+	}
+
+	return llmError
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// isTransientLLMError reports whether the call may work when retried: rate limits, outages, timeouts and
+// garbled answers. Those also count against the circuit breaker.
+func isTransientLLMError(err error) bool {
+	return errors.Is(err, ErrLLMRateLimited) || errors.Is(err, ErrLLMUnavailable) || errors.Is(err, ErrLLMMalformedResponse)
+}
+
+// llmCircuitBreaker stops the calls to a model after LLM_BREAKER_FAILURES transient failures in a row, for
+// LLM_BREAKER_COOLDOWN_SECONDS. A single call then goes through to probe the model: its success closes the breaker.
+type llmCircuitBreaker struct {
+	mutex     sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+var (
+	llmBreakersMutex sync.Mutex
+	llmBreakers      = map[string]*llmCircuitBreaker{}
+)
+
+// getLLMCircuitBreaker returns the breaker of a provider and model, e.g. "openai:gpt-4o-mini"
+func getLLMCircuitBreaker(key string) *llmCircuitBreaker {
+	llmBreakersMutex.Lock()
+	defer llmBreakersMutex.Unlock()
+
+	breaker, ok := llmBreakers[key]
+	if !ok {
+		breaker = &llmCircuitBreaker{}
+		llmBreakers[key] = breaker
+	}
+	return breaker
+}
+
+// allow reports whether a call may go through, and whether it is the probe of an open breaker. The probe
+// must end with record, or release when it has no outcome.
+func (breaker *llmCircuitBreaker) allow() (bool, bool) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	if breaker.failures < envUtil.GetIntEnvironmentVariable("LLM_BREAKER_FAILURES", 5) {
+		return true, false
+	}
+	if time.Now().Before(breaker.openUntil) || breaker.probing {
+		return false, false
+	}
+	breaker.probing = true
+	return true, true
+}
+
+// release lets another call probe the breaker, when the probe ended without telling anything about the model
+func (breaker *llmCircuitBreaker) release() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.probing = false
+}
+
+func (breaker *llmCircuitBreaker) record(err error) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.probing = false
+	if !isTransientLLMError(err) {
+		// the model answered, even if it refused this request
+		breaker.failures = 0
+		return
+	}
+
+	breaker.failures++
+	if breaker.failures >= envUtil.GetIntEnvironmentVariable("LLM_BREAKER_FAILURES", 5) {
+		breaker.openUntil = time.Now().Add(time.Duration(envUtil.GetIntEnvironmentVariable("LLM_BREAKER_COOLDOWN_SECONDS", 30)) * time.Second)
+	}
+}
+
+// maxLLMRetryWait is the longest wait before a retry, background enrichments included
+const maxLLMRetryWait = 30 * time.Second
+
+// callLLMWithRetries makes call, with a timeout per attempt, through the circuit breaker of the key. Transient
+// failures are retried LLM_MAX_RETRIES times (default 3) with exponential backoff, or after the delay the API
+// asked for, up to maxLLMRetryWait: a longer delay fails the call so the fallback models take over. canRetry,
+// when set, can veto a retry, e.g. once a stream has started.
+func callLLMWithRetries(ctx context.Context, key string, model string, timeout time.Duration, call func(ctx context.Context) error, canRetry func() bool) error {
+	breaker := getLLMCircuitBreaker(key)
+	maxRetries := envUtil.GetIntEnvironmentVariable("LLM_MAX_RETRIES", 3)
+
+	// a probe the caller gave up on must not keep the breaker open for good
+	probing := false
+	defer func() {
+		if probing {
+			breaker.release()
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		allowed, probe := breaker.allow()
+		if !allowed {
+			return &LLMError{Kind: ErrLLMCircuitOpen, Model: model, Message: "too many recent failures of " + key}
+		}
+		probing = probe
+
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := call(attemptCtx)
+		attemptTimedOut := attemptCtx.Err() == context.DeadlineExceeded
+		cancel()
+
+		if err != nil && ctx.Err() != nil {
+			// the caller gave up, not the model
+			return err
+		}
+		if err != nil && !errors.As(err, new(*LLMError)) && !errors.Is(err, ErrUnmatchedLLMRequest) {
+			message := err.Error()
+			if attemptTimedOut {
+				message = fmt.Sprintf("no answer within %v", timeout)
+			}
+			err = &LLMError{Kind: ErrLLMUnavailable, Model: model, Message: message}
+		}
+
+		breaker.record(err)
+		probing = false
+		if err == nil {
+			return nil
+		}
+		if !isTransientLLMError(err) || attempt >= maxRetries || (canRetry != nil && !canRetry()) {
+			return err
+		}
+
+		wait := time.Duration(1<<attempt) * time.Second
+		if wait > maxLLMRetryWait {
+			wait = maxLLMRetryWait
+		}
+		wait += time.Duration(rand.Int63n(int64(wait / 2)))
+		var llmError *LLMError
+		if errors.As(err, &llmError) && llmError.RetryAfter > 0 {
+			if llmError.RetryAfter > maxLLMRetryWait {
+				return err
+			}
+			wait = llmError.RetryAfter
+		}
+
+		log.Printf("LLM call to %s failed, retrying in %v: %v\n", key, wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ResilientLLMProvider adds timeouts, retries, a circuit breaker per model and fallback models to a provider.
+// When the model of a request keeps failing (outage, rate limit, breaker open, or a prompt too long for it)
+// the request goes to the fallback models in order.
+type ResilientLLMProvider struct {
+	Provider       LLMProvider
+	FallbackModels []string
+}
+
+// NewResilientLLMProvider wraps provider with the fallback models of LLM_FALLBACK_MODELS, e.g. "gpt-4o-mini,gpt-4o"
+func NewResilientLLMProvider(provider LLMProvider) *ResilientLLMProvider {
+	fallbackModels := []string{}
+	for _, model := range strings.Split(envUtil.GetEnvironmentVariableOrDefault("LLM_FALLBACK_MODELS", ""), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			fallbackModels = append(fallbackModels, model)
+		}
+	}
+	return &ResilientLLMProvider{Provider: provider, FallbackModels: fallbackModels}
+}
+
+func (provider *ResilientLLMProvider) Name() string { return provider.Provider.Name() }
+
+func (provider *ResilientLLMProvider) Complete(ctx context.Context, request LLMRequest) (string, error) {
+	timeout := time.Duration(envUtil.GetIntEnvironmentVariable("LLM_TIMEOUT_SECONDS", 60)) * time.Second
+
+	var content string
+	err := provider.withFallbacks(ctx, request, func(request LLMRequest) error {
+		return callLLMWithRetries(ctx, provider.Name()+":"+request.Model, request.Model, timeout, func(ctx context.Context) error {
+			var err error
+			content, err = provider.Provider.Complete(ctx, request)
+			return err
+		}, nil)
+	}, nil)
+	return content, err
+}
+
+func (provider *ResilientLLMProvider) Stream(ctx context.Context, request LLMRequest, onDelta func(string) error) (string, error) {
+	timeout := time.Duration(envUtil.GetIntEnvironmentVariable("LLM_STREAM_TIMEOUT_SECONDS", 300)) * time.Second
+
+	// once part of the answer went out, a retry would repeat it
+	started := false
+	var answer string
+	err := provider.withFallbacks(ctx, request, func(request LLMRequest) error {
+		return callLLMWithRetries(ctx, provider.Name()+":"+request.Model, request.Model, timeout, func(ctx context.Context) error {
+			var err error
+			answer, err = provider.Provider.Stream(ctx, request, func(delta string) error {
+				started = true
+				return onDelta(delta)
+			})
+			return err
+		}, func() bool { return !started })
+	}, func() bool { return !started })
+	return answer, err
+}
+
+func (provider *ResilientLLMProvider) withFallbacks(ctx context.Context, request LLMRequest, call func(request LLMRequest) error, canRetry func() bool) error {
+	models := []string{request.Model}
+	for _, model := range provider.FallbackModels {
+		if !Includes(models, model) {
+			models = append(models, model)
+		}
+	}
+
+	var err error
+	for i, model := range models {
+		request.Model = model
+		if err = call(request); err == nil {
+			return nil
+		}
+
+		canFallBack := isTransientLLMError(err) || errors.Is(err, ErrLLMCircuitOpen) || errors.Is(err, ErrLLMContextLengthExceeded)
+		if !canFallBack || ctx.Err() != nil || i == len(models)-1 || (canRetry != nil && !canRetry()) {
+			return err
+		}
+		log.Printf("LLM model %s failed, falling back to %s: %v\n", model, models[i+1], err)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCancelledProbeReleasesTheBreaker(t *testing.T) {
+	t.Setenv("LLM_BREAKER_FAILURES", "1")
+	t.Setenv("LLM_BREAKER_COOLDOWN_SECONDS", "0")
+	t.Setenv("LLM_MAX_RETRIES", "0")
+	key := "test:" + t.Name()
+
+	unavailable := func(ctx context.Context) error {
+		return &LLMError{Kind: ErrLLMUnavailable, Model: "model"}
+	}
+	if err := callLLMWithRetries(context.Background(), key, "model", time.Second, unavailable, nil); !errors.Is(err, ErrLLMUnavailable) {
+		t.Fatalf("err = %v", err)
+	}
+
+	// the probe of the open breaker is abandoned by its caller
+	ctx, cancel := context.WithCancel(context.Background())
+	err := callLLMWithRetries(ctx, key, "model", time.Second, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+
+	// the next call probes again, and closes the breaker
+	calls := 0
+	err = callLLMWithRetries(context.Background(), key, "model", time.Second, func(ctx context.Context) error {
+		calls++
+		return nil
+	}, nil)
+	if err != nil || calls != 1 {
+		t.Errorf("err = %v, calls = %d, want the breaker probed again", err, calls)
+	}
+}
+
+func TestLongRetryAfterIsNotWaitedFor(t *testing.T) {
+	t.Setenv("LLM_MAX_RETRIES", "3")
+
+	calls := 0
+	start := time.Now()
+	err := callLLMWithRetries(context.Background(), "test:"+t.Name(), "model", time.Second, func(ctx context.Context) error {
+		calls++
+		return &LLMError{Kind: ErrLLMRateLimited, Model: "model", RetryAfter: time.Hour}
+	}, nil)
+
+	if !errors.Is(err, ErrLLMRateLimited) || calls != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("err = %v after %d calls and %v, want the rate limit returned at once", err, calls, time.Since(start))
+	}
+}
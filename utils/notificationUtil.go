@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	envUtil "service-news-app-backend/config"
+	"strings"
+	"time"
+)
+
+// Notification is a message delivered to a user through one of the notification channels
+type Notification struct {
+	Title     string                 `json:"title"`
+	Body      string                 `json:"body"`
+	Url       string                 `json:"url"`
+	ArticleId string                 `json:"articleId"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// NotificationTarget holds the addresses of a user on every channel
+type NotificationTarget struct {
+	WebhookUrl string
+	FcmToken   string
+	Email      string
+}
+
+// NotificationChannel delivers notifications over one transport
+type NotificationChannel interface {
+	Name() string
+	Send(ctx context.Context, target NotificationTarget, notification Notification) error
+}
+
+var notificationChannels = map[string]NotificationChannel{}
+
+// RegisterNotificationChannel makes a channel available to GetNotificationChannel, replacing any channel with the same name
+func RegisterNotificationChannel(channel NotificationChannel) {
+	notificationChannels[channel.Name()] = channel
+}
+
+// GetNotificationChannel returns the registered channel with the given name
+func GetNotificationChannel(name string) (NotificationChannel, bool) {
+	channel, ok := notificationChannels[name]
+	return channel, ok
+}
+
+func init() {
+	RegisterNotificationChannel(WebhookChannel{})
+	RegisterNotificationChannel(FcmChannel{})
+	RegisterNotificationChannel(EmailChannel{})
+}
+
+var notificationHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// user supplied webhook urls only reach public addresses, without redirects
+var webhookNotificationHTTPClient = NewPublicHTTPClient(10 * time.Second)
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(ConvertToJson(payload)))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return nil
+}
+
+// WebhookChannel posts the notification as JSON to the user's webhook url
+type WebhookChannel struct {
+	Client *http.Client // -- public addresses only when nil, see NewPublicHTTPClient
+}
+
+func (WebhookChannel) Name() string { return "webhook" }
+
+func (channel WebhookChannel) Send(ctx context.Context, target NotificationTarget, notification Notification) error {
+	if target.WebhookUrl == "" {
+		return fmt.Errorf("no webhook url")
+	}
+
+	client := channel.Client
+	if client == nil {
+		client = webhookNotificationHTTPClient
+	}
+	return postJSON(ctx, client, target.WebhookUrl, nil, notification)
+}
+
+// FcmChannel sends a push notification through an FCM compatible HTTP endpoint (FCM_ENDPOINT, FCM_SERVER_KEY)
+type FcmChannel struct{}
+
+func (FcmChannel) Name() string { return "fcm" }
+
+func (FcmChannel) Send(ctx context.Context, target NotificationTarget, notification Notification) error {
+	if target.FcmToken == "" {
+		return fmt.Errorf("no fcm token")
+	}
+
+	endpoint := envUtil.GetEnvironmentVariableOrDefault("FCM_ENDPOINT", "https://fcm.googleapis.com/fcm/send")
+	headers := map[string]string{
+		"Authorization": "key=" + envUtil.GetEnvironmentVariable("FCM_SERVER_KEY"),
+	}
+
+	data := map[string]interface{}{
+		"articleId": notification.ArticleId,
+		"url":       notification.Url,
+	}
+	for key, value := range notification.Data {
+		data[key] = value
+	}
+
+	payload := map[string]interface{}{
+		"to": target.FcmToken,
+		"notification": map[string]interface{}{
+			"title": notification.Title,
+			"body":  notification.Body,
+		},
+		"data": data,
+	}
+
+	return postJSON(ctx, notificationHTTPClient, endpoint, headers, payload)
+}
+
+// EmailChannel sends the notification as a plain text email through SMTP_HOST:SMTP_PORT
+type EmailChannel struct{}
+
+func (EmailChannel) Name() string { return "email" }
+
+func (EmailChannel) Send(ctx context.Context, target NotificationTarget, notification Notification) error {
+	if target.Email == "" || !IsValidEmail(target.Email) {
+		return fmt.Errorf("no valid email address")
+	}
+
+	body := notification.Body
+	if notification.Url != "" {
+		body += "\r\n\r\n" + notification.Url
+	}
+
+	return SendEmail(target.Email, notification.Title, body, "")
+}
+
+// SendEmail sends a plain text email, with an optional HTML alternative, through the configured SMTP server
+func SendEmail(to string, subject string, textBody string, htmlBody string) error {
+	host := envUtil.GetEnvironmentVariable("SMTP_HOST")
+	if host == "" {
+		return fmt.Errorf("SMTP_HOST is not configured")
+	}
+	address := net.JoinHostPort(host, envUtil.GetEnvironmentVariableOrDefault("SMTP_PORT", "587"))
+	from := envUtil.GetEnvironmentVariable("SMTP_FROM")
+
+	var auth smtp.Auth
+	if username := envUtil.GetEnvironmentVariable("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, envUtil.GetEnvironmentVariable("SMTP_PASSWORD"), host)
+	}
+
+	var message strings.Builder
+	message.WriteString("From: " + from + "\r\n")
+	message.WriteString("To: " + to + "\r\n")
+	message.WriteString("Subject: " + strings.NewReplacer("\r", " ", "\n", " ").Replace(subject) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+
+	if htmlBody == "" {
+		message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		message.WriteString(textBody)
+	} else {
+		boundary := fmt.Sprintf("boundary-%d", time.Now().UnixNano())
+		message.WriteString("Content-Type: multipart/alternative; boundary=" + boundary + "\r\n\r\n")
+		message.WriteString("--" + boundary + "\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n" + textBody + "\r\n")
+		message.WriteString("--" + boundary + "\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n" + htmlBody + "\r\n")
+		message.WriteString("--" + boundary + "--\r\n")
+	}
+
+	return smtp.SendMail(address, auth, from, []string{to}, []byte(message.String()))
+}
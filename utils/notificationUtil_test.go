@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// startStubSMTPServer accepts plain SMTP sessions on a local port and sends every received message on the channel
+func startStubSMTPServer(t *testing.T) (string, string, <-chan string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveStubSMTPSession(connection, messages)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return host, port, messages
+}
+
+func serveStubSMTPSession(connection net.Conn, messages chan<- string) {
+	defer connection.Close()
+
+	reader := bufio.NewReader(connection)
+	reply := func(line string) { connection.Write([]byte(line + "\r\n")) }
+
+	reply("220 stub ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 stub")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 end with <CRLF>.<CRLF>")
+			var message strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				message.WriteString(dataLine)
+			}
+			messages <- message.String()
+			reply("250 queued")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestWebhookChannelPostsNotification(t *testing.T) {
+	var received Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type = %q", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	notification := Notification{Title: "Rates rise", Body: "The central bank raised rates.", Url: "https://news.example/rates", ArticleId: "a1"}
+	err := WebhookChannel{Client: server.Client()}.Send(context.Background(), NotificationTarget{WebhookUrl: server.URL}, notification)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if received.Title != notification.Title || received.ArticleId != "a1" || received.Url != notification.Url {
+		t.Errorf("received %+v", received)
+	}
+}
+
+func TestWebhookChannelFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := WebhookChannel{Client: server.Client()}.Send(context.Background(), NotificationTarget{WebhookUrl: server.URL}, Notification{Title: "t"})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("err = %v, want the 503 status", err)
+	}
+
+	if err := (WebhookChannel{}).Send(context.Background(), NotificationTarget{}, Notification{Title: "t"}); err == nil {
+		t.Error("sent without a webhook url")
+	}
+}
+
+func TestWebhookChannelRefusesPrivateAddresses(t *testing.T) {
+	received := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer server.Close()
+
+	err := WebhookChannel{}.Send(context.Background(), NotificationTarget{WebhookUrl: server.URL}, Notification{Title: "t"})
+	if !errors.Is(err, ErrPrivateDestination) || received {
+		t.Errorf("err = %v, received = %t, want the loopback address refused", err, received)
+	}
+}
+
+func TestFcmChannelSendsPush(t *testing.T) {
+	var authorization string
+	var payload struct {
+		To           string                 `json:"to"`
+		Notification map[string]string      `json:"notification"`
+		Data         map[string]interface{} `json:"data"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	t.Setenv("FCM_ENDPOINT", server.URL)
+	t.Setenv("FCM_SERVER_KEY", "server-key")
+
+	notification := Notification{
+		Title:     "Rates rise",
+		Body:      "The central bank raised rates.",
+		Url:       "https://news.example/rates",
+		ArticleId: "a1",
+		Data:      map[string]interface{}{"storyId": "s1"},
+	}
+	if err := (FcmChannel{}).Send(context.Background(), NotificationTarget{FcmToken: "device-token"}, notification); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if authorization != "key=server-key" {
+		t.Errorf("authorization = %q", authorization)
+	}
+	if payload.To != "device-token" || payload.Notification["title"] != "Rates rise" || payload.Notification["body"] != notification.Body {
+		t.Errorf("payload = %+v", payload)
+	}
+	if payload.Data["articleId"] != "a1" || payload.Data["url"] != notification.Url || payload.Data["storyId"] != "s1" {
+		t.Errorf("data = %+v", payload.Data)
+	}
+}
+
+func TestFcmChannelFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	t.Setenv("FCM_ENDPOINT", server.URL)
+
+	if err := (FcmChannel{}).Send(context.Background(), NotificationTarget{FcmToken: "device-token"}, Notification{Title: "t"}); err == nil {
+		t.Error("no error for a 401 response")
+	}
+	if err := (FcmChannel{}).Send(context.Background(), NotificationTarget{}, Notification{Title: "t"}); err == nil {
+		t.Error("sent without a token")
+	}
+}
+
+func TestEmailChannelSendsThroughSMTP(t *testing.T) {
+	host, port, messages := startStubSMTPServer(t)
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("SMTP_FROM", "alerts@news.example")
+	t.Setenv("SMTP_USERNAME", "")
+
+	notification := Notification{Title: "Rates rise\rBcc: victim@example.com", Body: "The central bank raised rates.", Url: "https://news.example/rates"}
+	if err := (EmailChannel{}).Send(context.Background(), NotificationTarget{Email: "reader@example.com"}, notification); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	message := <-messages
+	for _, expected := range []string{
+		"From: alerts@news.example",
+		"To: reader@example.com",
+		"Subject: Rates rise Bcc: victim@example.com\r\n",
+		"The central bank raised rates.",
+		"https://news.example/rates",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("message is missing %q:\n%s", expected, message)
+		}
+	}
+}
+
+func TestEmailChannelRejectsInvalidAddress(t *testing.T) {
+	host, port, _ := startStubSMTPServer(t)
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+
+	for _, email := range []string{"", "not-an-email"} {
+		if err := (EmailChannel{}).Send(context.Background(), NotificationTarget{Email: email}, Notification{Title: "t"}); err == nil {
+			t.Errorf("sent to %q", email)
+		}
+	}
+}
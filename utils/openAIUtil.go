@@ -1,136 +1,411 @@
 package utils
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	envUtil "service-news-app-backend/config"
 	"strings"
+	"time"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
-func CallLLM(systemPrompt string, message []map[string]interface{}) (map[string]interface{}, error) {
+// CallLLM sends the messages to the LLM with the default model settings. The call is bounded by ctx and by
+// LLM_TIMEOUT_SECONDS per attempt, failures are typed LLMErrors (see ResilientLLMProvider).
+func CallLLM(ctx context.Context, systemPrompt string, message []map[string]interface{}) (map[string]interface{}, error) {
+	content, err := GetLLMProvider().Complete(ctx, LLMRequest{
+		Model:        DefaultLLMModel,
+		Temperature:  DefaultLLMTemperature,
+		SystemPrompt: systemPrompt,
+		Messages:     message,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"role":    "assistant",
+		"content": content,
+	}, nil
+}
+
+// OpenAIProvider calls the OpenAI chat completions API with OPENAI_API_KEY, or any server with the same
+// API (e.g. a local model server, see NewLocalLLMProvider)
+type OpenAIProvider struct {
+	Label   string // -- name of the provider, "openai" when empty
+	BaseURL string // -- e.g., "http://localhost:11434/v1", the OpenAI API when empty
+	APIKey  string // -- OPENAI_API_KEY when empty
+	Model   string // -- replaces the model of every request when set, other servers don't serve OpenAI's models
+}
+
+// NewLocalLLMProvider returns a provider for an OpenAI-compatible server run locally (e.g. Ollama, vLLM or
+// llama.cpp) at LOCAL_LLM_BASE_URL (default http://localhost:11434/v1), answering every request with
+// LOCAL_LLM_MODEL (default llama3.1). Token budgets still follow the models of the prompts, cap them with
+// LLM_MAX_INPUT_TOKENS for a smaller local model.
+func NewLocalLLMProvider() OpenAIProvider {
+	return OpenAIProvider{
+		Label:   "local",
+		BaseURL: envUtil.GetEnvironmentVariableOrDefault("LOCAL_LLM_BASE_URL", "http://localhost:11434/v1"),
+		APIKey:  envUtil.GetEnvironmentVariableOrDefault("LOCAL_LLM_API_KEY", "local"),
+		Model:   envUtil.GetEnvironmentVariableOrDefault("LOCAL_LLM_MODEL", "llama3.1"),
+	}
+}
 
-	fmt.Println("systemPrompt: ", systemPrompt)
+func (provider OpenAIProvider) Name() string {
+	if provider.Label == "" {
+		return "openai"
+	}
+	return provider.Label
+}
+
+// endpoint returns the URL of an API path, e.g. "/chat/completions"
+func (provider OpenAIProvider) endpoint(path string) string {
+	baseURL := provider.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+func (provider OpenAIProvider) apiKey() string {
+	if provider.APIKey == "" {
+		return envUtil.GetEnvironmentVariable("OPENAI_API_KEY")
+	}
+	return provider.APIKey
+}
+
+func (provider OpenAIProvider) Complete(ctx context.Context, request LLMRequest) (string, error) {
+
+	if provider.Model != "" {
+		request.Model = provider.Model
+	}
 
 	messages := []map[string]interface{}{}
 	systemRole := map[string]interface{}{
 		"role":    "system",
-		"content": systemPrompt,
+		"content": request.SystemPrompt,
 	}
 
 	messages = append(messages, systemRole)
 
-	messages = append(messages, message...)
+	messages = append(messages, request.Messages...)
 
 	// creating input
 	llmCompletionCreate := map[string]interface{}{
-		"model":       "gpt-3.5-turbo-0125",
-		"temperature": 0.3,
+		"model":       request.Model,
+		"temperature": request.Temperature,
 		"messages":    messages,
 	}
+	if request.Tool != nil {
+		llmCompletionCreate["tools"] = []map[string]interface{}{{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        request.Tool.Name,
+				"description": request.Tool.Description,
+				"parameters":  request.Tool.Parameters,
+			},
+		}}
+		llmCompletionCreate["tool_choice"] = map[string]interface{}{
+			"type":     "function",
+			"function": map[string]interface{}{"name": request.Tool.Name},
+		}
+	}
 
 	// make an API call to openAI
-	apiKey := envUtil.GetEnvironmentVariable("OPENAI_API_KEY")
-	url := "https://api.openai.com/v1/chat/completions"
+	apiKey := provider.apiKey()
+	url := provider.endpoint("/chat/completions")
 
-	req, err := http.NewRequest("POST", url, bytes.NewReader(ConvertToJson(llmCompletionCreate)))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(ConvertToJson(llmCompletionCreate)))
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := llmHTTPClient().Do(req)
 
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	defer resp.Body.Close()
 
-	responseBody := map[string]interface{}{}
-	err = json.NewDecoder(resp.Body).Decode(&responseBody)
-
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	if resp.StatusCode == 200 {
+	if resp.StatusCode != http.StatusOK {
+		return "", newLLMResponseError(request.Model, resp, body)
+	}
 
-		answer := responseBody["choices"].([]interface{})[0].(map[string]interface{})["message"].(map[string]interface{})
+	var completion struct {
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+			Message      struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return "", &LLMError{Kind: ErrLLMMalformedResponse, Model: request.Model, StatusCode: resp.StatusCode, Message: err.Error()}
+	}
+	if len(completion.Choices) == 0 {
+		return "", &LLMError{Kind: ErrLLMMalformedResponse, Model: request.Model, StatusCode: resp.StatusCode, Message: "no choices in the answer"}
+	}
 
-		return answer, nil
-	} else {
-		err := responseBody["error"].(map[string]interface{})["message"].(string)
-		return nil, errors.New("can't get response-> " + err)
+	answer := completion.Choices[0]
+	if answer.FinishReason == "content_filter" {
+		return "", &LLMError{Kind: ErrLLMContentFiltered, Model: request.Model, StatusCode: resp.StatusCode}
 	}
 
+	// a forced function call answers with the arguments of the call
+	if request.Tool != nil && len(answer.Message.ToolCalls) > 0 {
+		return answer.Message.ToolCalls[0].Function.Arguments, nil
+	}
+	if answer.Message.Content == "" {
+		return "", &LLMError{Kind: ErrLLMMalformedResponse, Model: request.Model, StatusCode: resp.StatusCode, Message: "empty answer"}
+	}
+	return answer.Message.Content, nil
 }
 
-func GenerateVectorEmebeddings(input string) ([]float32, error) {
+// StreamLLM is CallLLM with a streamed completion, onDelta is called with every piece of the answer
+// as it arrives and the full answer is returned at the end
+func StreamLLM(ctx context.Context, systemPrompt string, message []map[string]interface{}, onDelta func(string) error) (string, error) {
+	return GetLLMProvider().Stream(ctx, LLMRequest{
+		Model:        DefaultLLMModel,
+		Temperature:  DefaultLLMTemperature,
+		SystemPrompt: systemPrompt,
+		Messages:     message,
+	}, onDelta)
+}
 
-	apiEndPoint := "https://api.openai.com/v1/embeddings"
+func (provider OpenAIProvider) Stream(ctx context.Context, request LLMRequest, onDelta func(string) error) (string, error) {
 
-	type apiRequest struct {
-		Input string `json:"input"`
-		Model string `json:"model"`
+	if provider.Model != "" {
+		request.Model = provider.Model
 	}
 
-	data := &apiRequest{
-		Input: input,
-		Model: "text-embedding-ada-002",
+	messages := []map[string]interface{}{
+		{
+			"role":    "system",
+			"content": request.SystemPrompt,
+		},
 	}
+	messages = append(messages, request.Messages...)
 
-	b, err := json.Marshal(data)
+	// creating input
+	llmCompletionCreate := map[string]interface{}{
+		"model":       request.Model,
+		"temperature": request.Temperature,
+		"messages":    messages,
+		"stream":      true,
+	}
+
+	// make an API call to openAI
+	apiKey := provider.apiKey()
+	url := provider.endpoint("/chat/completions")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(ConvertToJson(llmCompletionCreate)))
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	req, err := http.NewRequest("POST", apiEndPoint, bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+
+	resp, err := llmHTTPClient().Do(req)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", newLLMResponseError(request.Model, resp, body)
 	}
 
-	apiKey := envUtil.GetEnvironmentVariable("OPENAI_API_KEY")
+	// the completion arrives as server-sent events, one "data: {...}" line per chunk
+	var answer strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				FinishReason string `json:"finish_reason"`
+				Delta        struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil || len(chunk.Choices) == 0 {
+			continue
+		}
+		if chunk.Choices[0].FinishReason == "content_filter" {
+			return answer.String(), &LLMError{Kind: ErrLLMContentFiltered, Model: request.Model, StatusCode: resp.StatusCode}
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		if delta == "" {
+			continue
+		}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-	req.Header.Add("Content-Type", "application/json")
+		answer.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return answer.String(), err
+		}
+	}
+
+	return answer.String(), scanner.Err()
+}
+
+// EmbeddingModel is the OpenAI model of the stored embeddings, vectors of different models can't be compared
+// so switching EMBEDDING_PROVIDER means re-embedding the articles
+const EmbeddingModel = "text-embedding-ada-002"
+
+// EmbeddingDimensions is the size of the stored vectors, shorter vectors of local models are padded with zeros
+const EmbeddingDimensions = 1536
+
+// GetEmbeddingProvider returns the provider of the embeddings and its model, from EMBEDDING_PROVIDER: "openai"
+// (default) or "local", the server of NewLocalLLMProvider with LOCAL_EMBEDDING_MODEL (default nomic-embed-text)
+func GetEmbeddingProvider() (OpenAIProvider, string) {
+	if envUtil.GetEnvironmentVariableOrDefault("EMBEDDING_PROVIDER", "openai") == "local" {
+		return NewLocalLLMProvider(), envUtil.GetEnvironmentVariableOrDefault("LOCAL_EMBEDDING_MODEL", "nomic-embed-text")
+	}
+	return OpenAIProvider{}, EmbeddingModel
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func GenerateVectorEmebeddings(ctx context.Context, input string) ([]float32, error) {
+	embeddings, err := GenerateVectorEmbeddingsBatch(ctx, []string{input})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return embeddings[0], nil
+}
+
+// GenerateVectorEmbeddingsBatch embeds several inputs, in as few calls as possible. Inputs longer than the
+// model takes are truncated.
+func GenerateVectorEmbeddingsBatch(ctx context.Context, inputs []string) ([][]float32, error) {
+	_, model := GetEmbeddingProvider()
+
+	embeddings := make([][]float32, 0, len(inputs))
+	for start := 0; start < len(inputs); start += 64 {
+		end := start + 64
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+
+		batch := []string{}
+		for _, input := range inputs[start:end] {
+			batch = append(batch, TruncateToTokens(model, input, ModelContextTokens(model)))
+		}
+
+		batchEmbeddings, err := requestEmbeddings(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, batchEmbeddings...)
+	}
+	return embeddings, nil
+}
+
+func requestEmbeddings(ctx context.Context, inputs []string) ([][]float32, error) {
+
+	provider, model := GetEmbeddingProvider()
+	apiEndPoint := provider.endpoint("/embeddings")
+
+	type apiRequest struct {
+		Input []string `json:"input"`
+		Model string   `json:"model"`
+	}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	data := &apiRequest{
+		Input: inputs,
+		Model: model,
+	}
+
+	b, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf(result["error"].(map[string]interface{})["message"].(string))
-	} else {
+	// embeddings get the retries and circuit breaker of the completions, but no fallback model
+	timeout := time.Duration(envUtil.GetIntEnvironmentVariable("LLM_TIMEOUT_SECONDS", 60)) * time.Second
+	var embeddings [][]float32
+	err = callLLMWithRetries(ctx, provider.Name()+":"+data.Model, data.Model, timeout, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "POST", apiEndPoint, bytes.NewBuffer(b))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", provider.apiKey()))
+		req.Header.Add("Content-Type", "application/json")
+
+		resp, err := llmHTTPClient().Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
 
-		resultEmbeddings := result["data"].([]interface{})[0].(map[string]interface{})["embedding"].([]interface{})
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return newLLMResponseError(data.Model, resp, body)
+		}
 
-		var embeddings []float32
-		for _, v := range resultEmbeddings {
-			embeddings = append(embeddings, float32(v.(float64)))
+		var result struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil || len(result.Data) != len(inputs) {
+			return &LLMError{Kind: ErrLLMMalformedResponse, Model: data.Model, StatusCode: resp.StatusCode, Message: "missing embeddings in the answer"}
 		}
 
-		return embeddings, nil
+		embeddings = make([][]float32, len(inputs))
+		for _, item := range result.Data {
+			if item.Index < 0 || item.Index >= len(inputs) {
+				return &LLMError{Kind: ErrLLMMalformedResponse, Model: data.Model, StatusCode: resp.StatusCode, Message: "unexpected embedding index"}
+			}
+			if len(item.Embedding) > EmbeddingDimensions {
+				return &LLMError{Kind: ErrLLMRequestRejected, Model: data.Model, Message: fmt.Sprintf("embeddings of %d dimensions don't fit the stored %d", len(item.Embedding), EmbeddingDimensions)}
+			}
+			// zeros leave the cosine similarities between the vectors of the model unchanged
+			embeddings[item.Index] = append(item.Embedding, make([]float32, EmbeddingDimensions-len(item.Embedding))...)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
 	}
 
+	return embeddings, nil
 }
 
 type Entities struct {
@@ -139,89 +414,208 @@ type Entities struct {
 	Individuals   []string `json:"individuals"`
 }
 
-// TaggingData represents the structure of the response payload
+// TaggingData represents the structure of the response payload. The LLM answer is constrained to the JSON
+// schema derived from it (see JSONSchema).
 type MetaData struct {
-	SentimentScore string   `json:"sentimentScore"`
+	SentimentScore string   `json:"sentimentScore" enum:"Positive,Negative,Neutral"`
 	Entities       Entities `json:"entities"`
-	Categories     []string `json:"categories"`
+	Categories     []string `json:"categories" description:"one to three broad news categories"`
+	Confidence     int      `json:"confidence" minimum:"0" maximum:"100"` // -- 0 to 100, how sure the model is of the extracted metadata
+
+	FieldErrors []SchemaFieldError `json:"-"` // -- fields of the answer that were invalid, and left empty, even after repair
 }
 
-// getCategories calls the OpenAI API to get categories based on the prompt
-func GetResponseFromChatGPT(ctx context.Context, content string) (*MetaData, error) {
+// GetResponseFromChatGPT extracts the sentiment, categories and entities of an article with the "metadata" prompt.
+// The subject (the article id) keeps an article on the same prompt version. It returns the prompt version used.
+// Fields the LLM keeps getting wrong are left empty and listed in FieldErrors rather than failing the whole call.
+// Content too long for the model follows the "metadata" chunk strategy.
+func GetResponseFromChatGPT(ctx context.Context, subject string, content string) (*MetaData, string, error) {
 
-	var err error
+	model, budget, err := promptInputBudget("metadata", subject)
+	if err != nil {
+		return nil, "", err
+	}
+	if CountTokens(model, content) <= budget {
+		return extractMetadata(ctx, subject, content)
+	}
 
-	// Create the prompt for OpenAI
-	// prompt := "Extract entities from the following news article and categorize them into organizations, locations, and individuals:\n\n" + content
+	if GetChunkStrategy("metadata") != ChunkStrategyMapReduce {
+		return extractMetadata(ctx, subject, TruncateToTokens(model, content, budget))
+	}
 
-	// req := openai.CompletionRequest{
-	// 	Model:       openai.GPT4o,
-	// 	Prompt:      prompt,
-	// 	MaxTokens:   50,
-	// 	Temperature: 0.3,
-	// }
+	results := []MetaData{}
+	var promptVersion string
+	for _, chunk := range limitChunks(ChunkText(model, content, budget, 0)) {
+		resultData, chunkPromptVersion, err := extractMetadata(ctx, subject, chunk.Text)
+		if err != nil {
+			return nil, chunkPromptVersion, err
+		}
+		results = append(results, *resultData)
+		promptVersion = chunkPromptVersion
+	}
 
-	// // Initialize OpenAI client
-	// var openAIClient *openai.Client
+	resultData := mergeMetaData(results)
+	return &resultData, promptVersion, nil
+}
 
-	// resp, err := openAIClient.CreateCompletion(ctx, req)
-	// if err != nil {
-	// 	return nil, err
-	// }
+func extractMetadata(ctx context.Context, subject string, content string) (*MetaData, string, error) {
 
-	// if len(resp.Choices) == 0 {
-	// 	return nil, errors.New("no choices returned from OpenAI")
-	// }
+	var resultData MetaData
+	promptVersion, fieldErrors, err := RunStructuredPrompt(ctx, "metadata", subject, map[string]interface{}{
+		"Content": content,
+	}, &resultData)
+	if err != nil {
+		return nil, promptVersion, fmt.Errorf("unexpected metadata response: %w", err)
+	}
+
+	resultData.FieldErrors = fieldErrors
+	return &resultData, promptVersion, nil
+}
+
+// GenerateSummary writes a short neutral summary of an article with the "summary" prompt, and returns the prompt version used.
+// Content too long for the model follows the "summary" chunk strategy: with map_reduce every chunk is summarized and
+// the summaries are summarized in turn until they fit. When onDelta is set the final summary is streamed through it.
+func GenerateSummary(ctx context.Context, subject string, content string, onDelta func(string) error) (string, string, error) {
 
-	// responseFromOpenAI := strings.TrimSpace(resp.Choices[0].Text)
+	model, budget, err := promptInputBudget("summary", subject)
+	if err != nil {
+		return "", "", err
+	}
 
-	responseFromOpenAI := `{
-		"sentimentScore": "Neutral",
-		"categories": ["National Security", "Conflict"],
-		"entities": {
-			"organizations": ["Example Publisher"],
-			"locations": [],
-			"individuals": []
+	if GetChunkStrategy("summary") == ChunkStrategyMapReduce {
+		for round := 0; round < 3 && CountTokens(model, content) > budget; round++ {
+			summaries := []string{}
+			for _, chunk := range limitChunks(ChunkText(model, content, budget, 0)) {
+				summary, promptVersion, err := RunPrompt(ctx, "summary", subject, map[string]interface{}{
+					"Content": chunk.Text,
+				})
+				if err != nil {
+					return "", promptVersion, err
+				}
+				summaries = append(summaries, summary)
+			}
+			content = strings.Join(summaries, "\n\n")
 		}
-	}`
+	}
 
-	var resultData *MetaData
+	variables := map[string]interface{}{
+		"Content": TruncateToTokens(model, content, budget),
+	}
 
-	// Unmarshal the JSON into the MetaData struct
-	err = json.Unmarshal([]byte(responseFromOpenAI), &resultData)
+	if onDelta != nil {
+		summary, promptVersion, err := StreamPrompt(ctx, "summary", subject, variables, onDelta)
+		return strings.TrimSpace(summary), promptVersion, err
+	}
+
+	return RunPrompt(ctx, "summary", subject, variables)
+}
+
+// trimCodeFence removes the markdown code fence models sometimes wrap JSON answers in
+func trimCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimPrefix(content, "json")
+	return strings.TrimSpace(strings.TrimSuffix(content, "```"))
+}
+
+// GenerateImportanceScore asks the LLM how important the news is, from 0 (trivia) to 100 (major breaking news),
+// with the "importance" prompt. It returns the prompt version used.
+func GenerateImportanceScore(ctx context.Context, subject string, title string, summary string) (int, string, error) {
+
+	var result struct {
+		Importance int `json:"importance"`
+	}
+	promptVersion, fieldErrors, err := RunStructuredPrompt(ctx, "importance", subject, map[string]interface{}{
+		"Title":   title,
+		"Summary": summary,
+	}, &result)
 	if err != nil {
-		log.Fatalf("Error unmarshaling JSON: %v", err)
+		return 0, promptVersion, fmt.Errorf("unexpected importance response: %v", err)
+	}
+	if len(fieldErrors) > 0 {
+		return 0, promptVersion, fmt.Errorf("unexpected importance response: %v", fieldErrors[0])
 	}
 
-	return resultData, nil
+	if result.Importance < 0 {
+		result.Importance = 0
+	}
+	if result.Importance > 100 {
+		result.Importance = 100
+	}
+
+	return result.Importance, promptVersion, nil
 }
 
-func GenerateSummary(ctx context.Context, content string) (string, error) {
+// DigestStory is a story handed to the LLM when writing a digest
+type DigestStory struct {
+	Id       string `json:"id"`
+	Category string `json:"category"`
+	Title    string `json:"title"`
+	Summary  string `json:"summary"`
+}
 
-	// Create the prompt for OpenAI
-	prompt := "Extract entities from the following news article and categorize them into organizations, locations, and individuals:\n\n" + content
-	// req := openai.CompletionRequest{
-	// 	Model:       openai.GPT4o,
-	// 	Prompt:      prompt,
-	// 	MaxTokens:   50,
-	// 	Temperature: 0.3,
-	// }
+// GenerateDigestCopy writes the editorial intro of a digest and a short blurb per story, keyed by story id,
+// with the "digest" prompt constrained to its JSON schema. It only works from the given summaries so the
+// digest says nothing the articles don't.
+func GenerateDigestCopy(ctx context.Context, title string, stories []DigestStory) (string, map[string]string, error) {
 
-	// // Initialize OpenAI client
-	// var openAIClient *openai.Client
+	storiesJSON, err := json.Marshal(stories)
+	if err != nil {
+		return "", nil, err
+	}
 
-	// resp, err := openAIClient.CreateCompletion(ctx, req)
-	// if err != nil {
-	// 	return "", err
-	// }
+	var result struct {
+		Intro  string            `json:"intro" description:"two or three sentence editorial intro"`
+		Blurbs map[string]string `json:"blurbs" description:"one or two sentence blurb per story, keyed by story id"`
+	}
+	_, fieldErrors, err := RunStructuredPrompt(ctx, "digest", "", map[string]interface{}{
+		"Title":   title,
+		"Stories": string(storiesJSON),
+	}, &result)
+	if err != nil {
+		return "", nil, fmt.Errorf("unexpected digest response: %v", err)
+	}
+	// invalid fields are left empty, the digest falls back to its default intro and the summaries
+	for _, fieldError := range fieldErrors {
+		log.Printf("Invalid digest copy of %q: %v\n", title, fieldError)
+	}
+
+	return result.Intro, result.Blurbs, nil
+}
 
-	// if len(resp.Choices) == 0 {
-	// 	return "", errors.New("no choices returned from OpenAI")
-	// }
+// InsufficientEvidenceAnswer is what the LLM replies when the sources don't answer the question
+const InsufficientEvidenceAnswer = "INSUFFICIENT_EVIDENCE"
+
+// AnswerSource is a numbered article the answer may cite as [n]
+type AnswerSource struct {
+	Index           int
+	Title           string
+	Publisher       string
+	PublicationDate string
+	Text            string
+}
 
-	// responseFromOpenAI := strings.TrimSpace(resp.Choices[0].Text)
+// GenerateGroundedAnswer answers the question from the sources only, citing them inline as [n].
+// When onDelta is set the answer is streamed through it as it is generated.
+func GenerateGroundedAnswer(ctx context.Context, question string, sources []AnswerSource, onDelta func(string) error) (string, error) {
+
+	variables := map[string]interface{}{
+		"Question":             question,
+		"Sources":              sources,
+		"InsufficientEvidence": InsufficientEvidenceAnswer,
+	}
+
+	if onDelta != nil {
+		answer, _, err := StreamPrompt(ctx, "grounded_answer", "", variables, onDelta)
+		return answer, err
+	}
 
-	return prompt, nil
+	answer, _, err := RunPrompt(ctx, "grounded_answer", "", variables)
+	return answer, err
 }
 
 // parseCategories attempts to parse the categories as JSON
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateDestination is returned for outbound requests to loopback, private, link-local or metadata addresses
+var ErrPrivateDestination = errors.New("destination address is not public")
+
+// carrier-grade NAT range, used by some clouds for their metadata services
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP reports whether the address can be reached by requests made on behalf of users
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip) ||
+		ip.Equal(net.IPv4bcast))
+}
+
+// ValidatePublicURL checks that the URL is http(s) and that every address its host resolves to is public
+func ValidatePublicURL(ctx context.Context, rawURL string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("url must be http or https")
+	}
+
+	host := parsedURL.Hostname()
+	if host == "" {
+		return fmt.Errorf("url has no host")
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %v", host, err)
+	}
+	for _, address := range addresses {
+		if !IsPublicIP(address.IP) {
+			return fmt.Errorf("%s resolves to %s: %w", host, address.IP, ErrPrivateDestination)
+		}
+	}
+
+	return nil
+}
+
+// NewPublicHTTPClient returns a client for requests to user supplied URLs. The address is checked again when
+// connecting, so a host re-resolving to a private address is refused, and redirects are not followed.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return fmt.Errorf("connecting to %s: %w", host, ErrPrivateDestination)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
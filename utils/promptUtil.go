@@ -0,0 +1,350 @@
+package utils
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"path"
+	envUtil "service-news-app-backend/config"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// PromptVersion is one version of a named prompt: system and user message templates (text/template,
+// filled with the variables of the call) and the model settings they were tuned for.
+// Versions of a prompt share its traffic in proportion to their weight.
+type PromptVersion struct {
+	Name          string  `json:"name"`
+	Version       string  `json:"version"`
+	System        string  `json:"system"`
+	User          string  `json:"user"`
+	Model         string  `json:"model"`
+	Temperature   float64 `json:"temperature"`
+	TrafficWeight int     `json:"trafficWeight"`
+	Source        string  `json:"source"` // -- "embedded" (shipped in utils/prompts) or "stored" (added through the API)
+
+	systemTemplate *template.Template
+	userTemplate   *template.Template
+}
+
+// default model settings of the LLM calls
+const (
+	DefaultLLMModel       = "gpt-3.5-turbo-0125"
+	DefaultLLMTemperature = 0.3
+)
+
+//go:embed prompts/*.json
+var embeddedPromptFiles embed.FS
+
+var (
+	promptRegistryMutex sync.RWMutex
+	embeddedPrompts     = map[string][]PromptVersion{}
+	storedPrompts       = map[string][]PromptVersion{}
+	pinnedPrompts       = map[string]string{}
+)
+
+func init() {
+	entries, err := embeddedPromptFiles.ReadDir("prompts")
+	if err != nil {
+		log.Fatalf("Error reading embedded prompts: %v", err)
+	}
+
+	for _, entry := range entries {
+		data, err := embeddedPromptFiles.ReadFile(path.Join("prompts", entry.Name()))
+		if err != nil {
+			log.Fatalf("Error reading embedded prompt %s: %v", entry.Name(), err)
+		}
+
+		var prompt PromptVersion
+		if err := json.Unmarshal(data, &prompt); err != nil {
+			log.Fatalf("Error parsing embedded prompt %s: %v", entry.Name(), err)
+		}
+		prompt.Source = "embedded"
+		if err := prompt.compile(); err != nil {
+			log.Fatalf("Error parsing embedded prompt %s: %v", entry.Name(), err)
+		}
+
+		embeddedPrompts[prompt.Name] = append(embeddedPrompts[prompt.Name], prompt)
+	}
+}
+
+// compile parses the templates of the prompt and fills in the default model settings
+func (prompt *PromptVersion) compile() error {
+	if prompt.Model == "" {
+		prompt.Model = DefaultLLMModel
+	}
+
+	var err error
+	prompt.systemTemplate, err = template.New(prompt.Name + ".system").Option("missingkey=error").Parse(prompt.System)
+	if err != nil {
+		return fmt.Errorf("invalid system template: %v", err)
+	}
+	prompt.userTemplate, err = template.New(prompt.Name + ".user").Option("missingkey=error").Parse(prompt.User)
+	if err != nil {
+		return fmt.Errorf("invalid user template: %v", err)
+	}
+
+	return nil
+}
+
+// ValidatePromptTemplates reports whether the system and user templates parse
+func ValidatePromptTemplates(system string, user string) error {
+	prompt := PromptVersion{System: system, User: user}
+	return prompt.compile()
+}
+
+// Render fills the templates of the prompt with the variables
+func (prompt PromptVersion) Render(variables map[string]interface{}) (string, string, error) {
+	var system, user strings.Builder
+
+	if err := prompt.systemTemplate.Execute(&system, variables); err != nil {
+		return "", "", fmt.Errorf("error rendering prompt %s %s: %v", prompt.Name, prompt.Version, err)
+	}
+	if err := prompt.userTemplate.Execute(&user, variables); err != nil {
+		return "", "", fmt.Errorf("error rendering prompt %s %s: %v", prompt.Name, prompt.Version, err)
+	}
+
+	return system.String(), user.String(), nil
+}
+
+// SetStoredPromptVersions replaces the prompt versions added through the API. A stored version with the id
+// of an embedded one takes its place, which is how embedded versions get their traffic adjusted.
+// Versions whose templates don't parse are left out.
+func SetStoredPromptVersions(prompts []PromptVersion) {
+	stored := map[string][]PromptVersion{}
+	for _, prompt := range prompts {
+		prompt.Source = "stored"
+		if err := prompt.compile(); err != nil {
+			log.Printf("Error loading prompt %s %s: %v\n", prompt.Name, prompt.Version, err)
+			continue
+		}
+		stored[prompt.Name] = append(stored[prompt.Name], prompt)
+	}
+
+	promptRegistryMutex.Lock()
+	storedPrompts = stored
+	promptRegistryMutex.Unlock()
+}
+
+// PinPromptVersions makes every call of the given prompts use the given version regardless of the traffic
+// weights, e.g. {"metadata": "v2"} to evaluate a version. nil removes the pins.
+func PinPromptVersions(versions map[string]string) {
+	pinned := map[string]string{}
+	for name, version := range versions {
+		pinned[name] = version
+	}
+
+	promptRegistryMutex.Lock()
+	pinnedPrompts = pinned
+	promptRegistryMutex.Unlock()
+}
+
+// GetPromptNames lists the names of the registered prompts
+func GetPromptNames() []string {
+	names := []string{}
+	for name := range embeddedPrompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetPromptVersions lists the versions of a prompt, sorted by version id
+func GetPromptVersions(name string) []PromptVersion {
+	promptRegistryMutex.RLock()
+	defer promptRegistryMutex.RUnlock()
+
+	versions := []PromptVersion{}
+	for _, prompt := range embeddedPrompts[name] {
+		if !hasPromptVersion(storedPrompts[name], prompt.Version) {
+			versions = append(versions, prompt)
+		}
+	}
+	versions = append(versions, storedPrompts[name]...)
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions
+}
+
+// GetPromptVersion returns a version of a prompt
+func GetPromptVersion(name string, version string) (PromptVersion, bool) {
+	for _, prompt := range GetPromptVersions(name) {
+		if prompt.Version == version {
+			return prompt, true
+		}
+	}
+	return PromptVersion{}, false
+}
+
+func hasPromptVersion(prompts []PromptVersion, version string) bool {
+	for _, prompt := range prompts {
+		if prompt.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectPromptVersion picks the version of the prompt serving a call, at random in proportion to the traffic
+// weights. Calls with the same subject (e.g. an article id) always get the same version while the weights don't change.
+func SelectPromptVersion(name string, subject string) (PromptVersion, error) {
+	promptRegistryMutex.RLock()
+	pinnedVersion, pinned := pinnedPrompts[name]
+	promptRegistryMutex.RUnlock()
+	if pinned {
+		prompt, ok := GetPromptVersion(name, pinnedVersion)
+		if !ok {
+			return PromptVersion{}, fmt.Errorf("pinned version %s of prompt %s does not exist", pinnedVersion, name)
+		}
+		return prompt, nil
+	}
+
+	versions := GetPromptVersions(name)
+
+	totalWeight := 0
+	for _, prompt := range versions {
+		if prompt.TrafficWeight > 0 {
+			totalWeight += prompt.TrafficWeight
+		}
+	}
+	if totalWeight == 0 {
+		return PromptVersion{}, fmt.Errorf("prompt %s has no version receiving traffic", name)
+	}
+
+	var pick int
+	if subject == "" {
+		pick = rand.Intn(totalWeight)
+	} else {
+		hash := fnv.New32a()
+		hash.Write([]byte(name + ":" + subject))
+		pick = int(hash.Sum32() % uint32(totalWeight))
+	}
+
+	for _, prompt := range versions {
+		if prompt.TrafficWeight <= 0 {
+			continue
+		}
+		if pick < prompt.TrafficWeight {
+			return prompt, nil
+		}
+		pick -= prompt.TrafficWeight
+	}
+
+	return versions[len(versions)-1], nil
+}
+
+// RunPrompt renders the version of the prompt selected for the subject and sends it to the LLM with the
+// model settings of that version. It returns the answer and the version id.
+func RunPrompt(ctx context.Context, name string, subject string, variables map[string]interface{}) (string, string, error) {
+	prompt, system, user, err := preparePrompt(name, subject, variables)
+	if err != nil {
+		return "", "", err
+	}
+
+	content, err := GetStepLLMProvider(name).Complete(ctx, prompt.request(system, user, variables))
+	if err != nil {
+		return "", prompt.Version, err
+	}
+
+	return strings.TrimSpace(content), prompt.Version, nil
+}
+
+// RunStructuredPrompt is RunPrompt with the answer constrained to the JSON schema of target, through a forced
+// function call, and decoded into target. An answer that doesn't match the schema is sent back with its errors
+// for repair, LLM_REPAIR_ATTEMPTS times (default 1). If it still doesn't match, the valid fields are decoded and
+// the invalid ones returned as field errors. It fails when no JSON object comes back at all.
+func RunStructuredPrompt(ctx context.Context, name string, subject string, variables map[string]interface{}, target interface{}) (string, []SchemaFieldError, error) {
+	prompt, system, user, err := preparePrompt(name, subject, variables)
+	if err != nil {
+		return "", nil, err
+	}
+
+	schema := JSONSchema(target)
+	request := prompt.request(system, user, variables)
+	request.Tool = &LLMTool{Name: name, Description: "Records the " + name + " of the input", Parameters: schema}
+
+	repairAttempts := envUtil.GetIntEnvironmentVariable("LLM_REPAIR_ATTEMPTS", 1)
+	for attempt := 0; ; attempt++ {
+		content, err := GetStepLLMProvider(name).Complete(ctx, request)
+		if err != nil {
+			return prompt.Version, nil, err
+		}
+
+		fieldErrors, decodeErr := checkStructuredAnswer(content, schema)
+		if (decodeErr == nil && len(fieldErrors) == 0) || attempt >= repairAttempts {
+			if decodeErr != nil {
+				return prompt.Version, nil, decodeErr
+			}
+			fieldErrors, err := DecodeJSONSchema(content, schema, target)
+			return prompt.Version, fieldErrors, err
+		}
+
+		problems := []string{}
+		if decodeErr != nil {
+			problems = append(problems, decodeErr.Error())
+		}
+		for _, fieldError := range fieldErrors {
+			problems = append(problems, fieldError.Error())
+		}
+		log.Printf("Repairing %s %s answer for %s: %s\n", prompt.Name, prompt.Version, subject, strings.Join(problems, "; "))
+
+		request.Messages = append(request.Messages,
+			map[string]interface{}{"role": "assistant", "content": content},
+			map[string]interface{}{"role": "user", "content": "Your answer does not match the required JSON schema:\n- " +
+				strings.Join(problems, "\n- ") + "\nReply again with the complete corrected JSON object."},
+		)
+	}
+}
+
+// checkStructuredAnswer validates an answer against the schema without decoding it
+func checkStructuredAnswer(content string, schema map[string]interface{}) ([]SchemaFieldError, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(trimCodeFence(content)), &value); err != nil {
+		return nil, fmt.Errorf("answer is not valid JSON: %v", err)
+	}
+	return ValidateJSONSchema(schema, value), nil
+}
+
+// StreamPrompt is RunPrompt with a streamed completion, onDelta is called with every piece of the answer
+func StreamPrompt(ctx context.Context, name string, subject string, variables map[string]interface{}, onDelta func(string) error) (string, string, error) {
+	prompt, system, user, err := preparePrompt(name, subject, variables)
+	if err != nil {
+		return "", "", err
+	}
+
+	answer, err := GetStepLLMProvider(name).Stream(ctx, prompt.request(system, user, variables), onDelta)
+	return answer, prompt.Version, err
+}
+
+func preparePrompt(name string, subject string, variables map[string]interface{}) (PromptVersion, string, string, error) {
+	prompt, err := SelectPromptVersion(name, subject)
+	if err != nil {
+		return PromptVersion{}, "", "", err
+	}
+
+	system, user, err := prompt.Render(variables)
+	if err != nil {
+		return PromptVersion{}, "", "", err
+	}
+
+	return prompt, system, user, nil
+}
+
+// request builds the LLM request of the rendered prompt
+func (prompt PromptVersion) request(system string, user string, variables map[string]interface{}) LLMRequest {
+	return LLMRequest{
+		Model:         prompt.Model,
+		Temperature:   prompt.Temperature,
+		SystemPrompt:  system,
+		Messages:      []map[string]interface{}{{"role": "user", "content": user}},
+		PromptName:    prompt.Name,
+		PromptVersion: prompt.Version,
+		Variables:     variables,
+	}
+}
@@ -0,0 +1,13 @@
+package utils
+
+// ReciprocalRankFusion merges rankings of ids into one score per id, sum(1 / (k + rank)) with ranks
+// starting at 1. k dampens the weight of the top ranks, 60 is the usual choice.
+func ReciprocalRankFusion(k int, rankings ...[]string) map[string]float64 {
+	scores := map[string]float64{}
+	for _, ranking := range rankings {
+		for rank, id := range ranking {
+			scores[id] += 1 / float64(k+rank+1)
+		}
+	}
+	return scores
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	envUtil "service-news-app-backend/config"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// cache namespace of the related articles lists, bumped whenever an article is published or removed
+const RelatedArticlesCacheNamespace = "related"
+
+var (
+	redisClient     *redis.Client
+	redisClientOnce sync.Once
+)
+
+// GetRedisClient returns the shared redis client built from REDIS_URL, nil when redis is not configured
+func GetRedisClient() *redis.Client {
+	redisClientOnce.Do(func() {
+		redisURL := envUtil.GetEnvironmentVariable("REDIS_URL")
+		if redisURL == "" {
+			return
+		}
+
+		options, err := redis.ParseURL(redisURL)
+		if err != nil {
+			log.Println("Invalid REDIS_URL, caching disabled: ", err)
+			return
+		}
+		redisClient = redis.NewClient(options)
+	})
+	return redisClient
+}
+
+// GetCachedJSON loads the cached value of key into dest, it returns false on a miss or when redis is unavailable
+func GetCachedJSON(ctx context.Context, key string, dest interface{}) bool {
+	client := GetRedisClient()
+	if client == nil {
+		return false
+	}
+
+	cached, err := client.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Println("Error reading cache: ", err)
+		}
+		return false
+	}
+
+	return json.Unmarshal(cached, dest) == nil
+}
+
+// SetCachedJSON caches the value under key for the given duration, failures only disable caching
+func SetCachedJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	client := GetRedisClient()
+	if client == nil {
+		return
+	}
+
+	if err := client.Set(ctx, key, ConvertToJson(value), ttl).Err(); err != nil {
+		log.Println("Error writing cache: ", err)
+	}
+}
+
+// DeleteCachedKeys removes the given keys from the cache
+func DeleteCachedKeys(ctx context.Context, keys ...string) {
+	client := GetRedisClient()
+	if client == nil || len(keys) == 0 {
+		return
+	}
+
+	if err := client.Del(ctx, keys...).Err(); err != nil {
+		log.Println("Error deleting cache keys: ", err)
+	}
+}
+
+// GetCacheGeneration returns the current generation of a cache namespace. Keys built with it
+// become unreachable (and expire) once the generation is bumped with BumpCacheGeneration.
+func GetCacheGeneration(ctx context.Context, namespace string) int64 {
+	client := GetRedisClient()
+	if client == nil {
+		return 0
+	}
+
+	generation, err := client.Get(ctx, "generation:"+namespace).Int64()
+	if err != nil && err != redis.Nil {
+		log.Println("Error reading cache generation: ", err)
+	}
+	return generation
+}
+
+// BumpCacheGeneration invalidates every key of the namespace
+func BumpCacheGeneration(ctx context.Context, namespace string) {
+	client := GetRedisClient()
+	if client == nil {
+		return
+	}
+
+	if err := client.Incr(ctx, "generation:"+namespace).Err(); err != nil {
+		log.Println("Error bumping cache generation: ", err)
+	}
+}
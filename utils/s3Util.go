@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	envUtil "service-news-app-backend/config"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var (
+	s3Client     *s3.S3
+	s3ClientOnce sync.Once
+)
+
+// GetS3Client returns the shared S3 client configured from AWS_REGION and the default credential chain
+func GetS3Client() *s3.S3 {
+	s3ClientOnce.Do(func() {
+		awsSession := session.Must(session.NewSession(&aws.Config{
+			Region: aws.String(envUtil.GetEnvironmentVariable("AWS_REGION")),
+		}))
+		s3Client = s3.New(awsSession)
+	})
+	return s3Client
+}
+
+// ParseS3Path splits "s3://bucket/key" into bucket and key, a bare key uses the S3_BUCKET_NAME bucket
+func ParseS3Path(s3Path string) (string, string, error) {
+	if strings.HasPrefix(s3Path, "s3://") {
+		bucket, key, found := strings.Cut(strings.TrimPrefix(s3Path, "s3://"), "/")
+		if !found || bucket == "" || key == "" {
+			return "", "", fmt.Errorf("invalid s3 path: %s", s3Path)
+		}
+		return bucket, key, nil
+	}
+
+	bucket := envUtil.GetEnvironmentVariable("S3_BUCKET_NAME")
+	if bucket == "" {
+		return "", "", fmt.Errorf("no bucket for s3 path: %s", s3Path)
+	}
+
+	return bucket, strings.TrimPrefix(s3Path, "/"), nil
+}
+
+// DeleteS3Object removes the object stored at the given S3 path
+func DeleteS3Object(ctx context.Context, s3Path string) error {
+	bucket, key, err := ParseS3Path(s3Path)
+	if err != nil {
+		return err
+	}
+
+	_, err = GetS3Client().DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting s3 object %s: %v", s3Path, err)
+	}
+
+	return nil
+}
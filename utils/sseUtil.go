@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SSEWriter writes server-sent events and flushes each one to the client right away
+type SSEWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// NewSSEWriter sets the event stream headers, it returns an error when the connection can't stream
+func NewSSEWriter(w http.ResponseWriter) (*SSEWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming is not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &SSEWriter{w: w, flusher: flusher}, nil
+}
+
+// Send writes an event with its data encoded as JSON
+func (writer *SSEWriter) Send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(writer.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	writer.flusher.Flush()
+	return nil
+}
+
+// Comment writes a comment line, used as a keep-alive that clients ignore
+func (writer *SSEWriter) Comment(text string) error {
+	if _, err := fmt.Fprintf(writer.w, ": %s\n\n", text); err != nil {
+		return err
+	}
+	writer.flusher.Flush()
+	return nil
+}
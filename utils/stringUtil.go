@@ -181,17 +181,3 @@ func BuildUserName(email string) string {
 func ReplaceSpacesWithUnderscores(filename string) string {
 	return strings.ReplaceAll(filename, " ", "_")
 }
-
-// fixMalformedJSON tries to correct common JSON formatting issues in the input string.
-func FixMalformedJSON(input string) string {
-	if strings.Contains(input, `":false`) {
-		input = strings.Replace(input, `:false`, ``, 1)
-	}
-	if strings.Contains(input, `":true`) {
-		input = strings.Replace(input, `:true`, ``, 1)
-	}
-	if strings.Contains(input, `":null`) {
-		input = strings.Replace(input, `:null`, ``, 1)
-	}
-	return input
-}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// llmModelInfo is the context window of a model and the tokenizer family it uses
+type llmModelInfo struct {
+	contextTokens int
+	encoding      string // -- "cl100k_base" or "o200k_base"
+}
+
+// known models by name prefix, the longest matching prefix wins
+var llmModels = map[string]llmModelInfo{
+	"gpt-3.5-turbo":          {contextTokens: 16385, encoding: "cl100k_base"},
+	"gpt-4":                  {contextTokens: 8192, encoding: "cl100k_base"},
+	"gpt-4-turbo":            {contextTokens: 128000, encoding: "cl100k_base"},
+	"gpt-4o":                 {contextTokens: 128000, encoding: "o200k_base"},
+	"gpt-4.1":                {contextTokens: 1047576, encoding: "o200k_base"},
+	"text-embedding-ada-002": {contextTokens: 8191, encoding: "cl100k_base"},
+	"text-embedding-3":       {contextTokens: 8191, encoding: "cl100k_base"},
+}
+
+// unknown models (e.g. local ones) get a conservative window
+var defaultLLMModelInfo = llmModelInfo{contextTokens: 8192, encoding: "cl100k_base"}
+
+func getLLMModelInfo(model string) llmModelInfo {
+	info, matched := defaultLLMModelInfo, ""
+	for prefix, prefixInfo := range llmModels {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			info, matched = prefixInfo, prefix
+		}
+	}
+	return info
+}
+
+// ModelContextTokens returns the context window of the model, prompt and answer included
+func ModelContextTokens(model string) int {
+	return getLLMModelInfo(model).contextTokens
+}
+
+// the BPE ranks are embedded in the binary, nothing is downloaded at run time
+func init() {
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+var (
+	tokenEncodings      = map[string]*tiktoken.Tiktoken{}
+	tokenEncodingsMutex sync.Mutex
+)
+
+// getTokenEncoding loads the BPE tokenizer of the encoding once, nil when it can't be loaded
+func getTokenEncoding(encoding string) *tiktoken.Tiktoken {
+	tokenEncodingsMutex.Lock()
+	defer tokenEncodingsMutex.Unlock()
+
+	tokenizer, loaded := tokenEncodings[encoding]
+	if !loaded {
+		var err error
+		tokenizer, err = tiktoken.GetEncoding(encoding)
+		if err != nil {
+			log.Printf("Error loading the %s tokenizer, token counts are estimated: %v", encoding, err)
+			tokenizer = nil
+		}
+		tokenEncodings[encoding] = tokenizer
+	}
+	return tokenizer
+}
+
+// CountTokens returns the number of tokens of the text with the BPE encoding (cl100k_base or o200k_base)
+// of the model. Special tokens in the text are counted as plain text.
+func CountTokens(model string, text string) int {
+	encoding := getLLMModelInfo(model).encoding
+	if tokenizer := getTokenEncoding(encoding); tokenizer != nil {
+		return len(tokenizer.EncodeOrdinary(text))
+	}
+	return estimateTokens(text, encoding)
+}
+
+// estimateTokens is the fallback when the tokenizer can't be loaded. It splits the text the way the BPE
+// tokenizers pre-tokenize it (words with their leading space, digit groups, punctuation) and prices each
+// piece from its length, then adds a margin so budgets computed from it hold.
+func estimateTokens(text string, encoding string) int {
+	tokens := 0
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+
+		switch {
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			end, ascii := size, r < utf8.RuneSelf
+			for end < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[end:])
+				if !unicode.IsLetter(next) && !unicode.IsMark(next) {
+					break
+				}
+				ascii = ascii && next < utf8.RuneSelf
+				end += nextSize
+			}
+			tokens += wordTokens(text[:end], ascii, encoding)
+			text = text[end:]
+		case unicode.IsDigit(r):
+			end := size
+			for end < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[end:])
+				if !unicode.IsDigit(next) {
+					break
+				}
+				end += nextSize
+			}
+			// digits are grouped by three
+			tokens += (utf8.RuneCountInString(text[:end]) + 2) / 3
+			text = text[end:]
+		case r == '\n':
+			end := size
+			for end < len(text) && (text[end] == '\n' || text[end] == '\r') {
+				end++
+			}
+			tokens++
+			text = text[end:]
+		case unicode.IsSpace(r):
+			// a single space belongs to the next word
+			end := size
+			for end < len(text) && (text[end] == ' ' || text[end] == '\t') {
+				end++
+			}
+			if end > 1 {
+				tokens++
+			}
+			text = text[end:]
+		default:
+			tokens++
+			text = text[size:]
+		}
+	}
+
+	return tokens + tokens/10
+}
+
+// wordTokens prices a word: common English words are a single token and longer ones split every few
+// letters, while scripts outside ASCII (e.g. Devanagari) take about a token per letter, fewer with o200k_base
+func wordTokens(word string, ascii bool, encoding string) int {
+	if ascii {
+		return 1 + len(word)/8
+	}
+
+	runes := utf8.RuneCountInString(word)
+	if encoding == "o200k_base" {
+		return (runes + 1) / 2
+	}
+	return runes
+}
+
+// CountMessageTokens counts the tokens of a chat request: the system prompt and messages, with the few
+// tokens of framing every message takes
+func CountMessageTokens(model string, systemPrompt string, messages []map[string]interface{}) int {
+	tokens := 3 + 4 + CountTokens(model, systemPrompt)
+	for _, message := range messages {
+		content, _ := message["content"].(string)
+		tokens += 4 + CountTokens(model, content)
+	}
+	return tokens
+}
@@ -0,0 +1,67 @@
+package utils
+
+import "math"
+
+// CosineSimilarity returns the cosine similarity of two vectors, 0 when either is empty or their sizes differ
+func CosineSimilarity(a []float32, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// WeightedAverageVector averages the vectors using the given weights, skipping empty vectors
+func WeightedAverageVector(vectors [][]float32, weights []float64) []float32 {
+	var sum []float64
+	totalWeight := 0.0
+
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			continue
+		}
+		if sum == nil {
+			sum = make([]float64, len(vector))
+		}
+		if len(vector) != len(sum) {
+			continue
+		}
+
+		for j, value := range vector {
+			sum[j] += float64(value) * weights[i]
+		}
+		totalWeight += weights[i]
+	}
+
+	if sum == nil || totalWeight == 0 {
+		return nil
+	}
+
+	average := make([]float32, len(sum))
+	for j := range sum {
+		average[j] = float32(sum[j] / totalWeight)
+	}
+	return average
+}
+
+// FreshnessScore decays from 1 to 0 as the age grows, halving every halfLifeHours.
+// A half-life of zero or less means no decay.
+func FreshnessScore(ageHours float64, halfLifeHours float64) float64 {
+	if halfLifeHours <= 0 || math.IsNaN(halfLifeHours) {
+		return 1
+	}
+	if ageHours < 0 {
+		ageHours = 0
+	}
+	return math.Pow(0.5, ageHours/halfLifeHours)
+}
@@ -0,0 +1,185 @@
+package workers
+
+import (
+	"context"
+	"log"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AssignStory links the article to the story of the closest recent article covering the same event,
+// or starts a new story with the article itself
+func AssignStory(ctx context.Context, article *schemas.ArticleSchema) error {
+	storyId := article.ArticleId
+	article.StoryId = &storyId
+
+	if len(article.Embedding) == 0 {
+		return nil
+	}
+
+	nearest, err := schemas.GetNearestArticles(ctx, PostgresInstance.GetPostgresInstance(), article.Embedding, article.ArticleId, 1)
+	if err != nil || len(nearest) == 0 {
+		return err
+	}
+
+	threshold := float64(config.GetIntEnvironmentVariable("FEED_CLUSTER_SIMILARITY_PERCENT", 88)) / 100
+	storyWindow := time.Duration(config.GetIntEnvironmentVariable("STORY_WINDOW_HOURS", 48)) * time.Hour
+
+	candidate := nearest[0]
+	publicationGap := article.PublicationDate.Sub(candidate.PublicationDate)
+	if publicationGap < 0 {
+		publicationGap = -publicationGap
+	}
+
+	if publicationGap <= storyWindow && utils.CosineSimilarity(article.Embedding, candidate.Embedding) >= threshold {
+		if candidate.StoryId != nil {
+			article.StoryId = candidate.StoryId
+		} else {
+			article.StoryId = &candidate.ArticleId
+		}
+	}
+
+	return nil
+}
+
+// NotificationLog records the stories users are notified about, so every story is sent once
+type NotificationLog interface {
+	Claim(ctx context.Context, notification schemas.NotificationLogSchema) (bool, error)
+	UpdateStatus(ctx context.Context, userId string, storyId string, status string, details string) error
+}
+
+// postgresNotificationLog is the NotificationLog of the notification log table
+type postgresNotificationLog struct {
+	pool *pgxpool.Pool
+}
+
+func (notificationLog postgresNotificationLog) Claim(ctx context.Context, notification schemas.NotificationLogSchema) (bool, error) {
+	return schemas.ClaimStoryNotification(ctx, notificationLog.pool, notification)
+}
+
+func (notificationLog postgresNotificationLog) UpdateStatus(ctx context.Context, userId string, storyId string, status string, details string) error {
+	return schemas.UpdateNotificationStatus(ctx, notificationLog.pool, userId, storyId, status, details)
+}
+
+// DispatchAlerts notifies the users whose alert rules match a newly published article.
+// Every user hears about a story once, and nothing is sent during a rule's quiet hours.
+func DispatchAlerts(ctx context.Context, article schemas.ArticleSchema) {
+	pool := PostgresInstance.GetPostgresInstance()
+
+	rules, err := schemas.GetAlertRulesMatchingArticle(ctx, pool, article)
+	if err != nil {
+		log.Printf("Error fetching alert rules for article %s: %v\n", article.ArticleId, err)
+		return
+	}
+
+	NotifyAlertRules(ctx, postgresNotificationLog{pool: pool}, article, rules, time.Now())
+}
+
+// NotifyAlertRules sends the article through the channels of the matching rules, claiming the story in the
+// notification log first and recording the outcome of every notification
+func NotifyAlertRules(ctx context.Context, notificationLog NotificationLog, article schemas.ArticleSchema, rules []schemas.AlertRuleSchema, now time.Time) {
+	storyId := article.ArticleId
+	if article.StoryId != nil {
+		storyId = *article.StoryId
+	}
+
+	notification := utils.Notification{
+		Title:     article.Title,
+		Body:      truncateText(article.Summary, 200),
+		Url:       article.Url,
+		ArticleId: article.ArticleId,
+		Data: map[string]interface{}{
+			"storyId":         storyId,
+			"importanceScore": article.ImportanceScore,
+		},
+	}
+
+	for _, rule := range rules {
+		claimed, err := notificationLog.Claim(ctx, schemas.NotificationLogSchema{
+			UserId:    rule.UserId,
+			StoryId:   storyId,
+			ArticleId: article.ArticleId,
+			RuleId:    rule.RuleId,
+			Status:    "pending",
+		})
+		if err != nil {
+			log.Printf("Error claiming notification for user %s: %v\n", rule.UserId, err)
+			continue
+		}
+		if !claimed {
+			continue // already notified about this story, possibly through another rule
+		}
+
+		if isInQuietHours(rule, now) {
+			if err := notificationLog.UpdateStatus(ctx, rule.UserId, storyId, "suppressed", "quiet hours"); err != nil {
+				log.Printf("Error recording notification for user %s: %v\n", rule.UserId, err)
+			}
+			continue
+		}
+
+		target := utils.NotificationTarget{
+			WebhookUrl: rule.WebhookUrl,
+			FcmToken:   rule.FcmToken,
+			Email:      rule.Email,
+		}
+
+		sent := false
+		failures := []string{}
+		for _, channelName := range rule.Channels {
+			channel, ok := utils.GetNotificationChannel(channelName)
+			if !ok {
+				failures = append(failures, channelName+": unknown channel")
+				continue
+			}
+
+			if err := channel.Send(ctx, target, notification); err != nil {
+				failures = append(failures, channelName+": "+err.Error())
+				continue
+			}
+			sent = true
+		}
+
+		status := "failed"
+		if sent {
+			status = "sent"
+		}
+		if err := notificationLog.UpdateStatus(ctx, rule.UserId, storyId, status, strings.Join(failures, "; ")); err != nil {
+			log.Printf("Error recording notification for user %s: %v\n", rule.UserId, err)
+		}
+	}
+}
+
+// isInQuietHours reports whether now falls in the quiet hours of the rule, in the rule's timezone.
+// Quiet hours may wrap around midnight (e.g. 22 to 7).
+func isInQuietHours(rule schemas.AlertRuleSchema, now time.Time) bool {
+	if rule.QuietHoursStart == nil || rule.QuietHoursEnd == nil || *rule.QuietHoursStart == *rule.QuietHoursEnd {
+		return false
+	}
+
+	location, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	hour := now.In(location).Hour()
+	start, end := *rule.QuietHoursStart, *rule.QuietHoursEnd
+
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
+
+func truncateText(text string, maxRunes int) string {
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:maxRunes])) + "…"
+}
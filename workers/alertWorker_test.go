@@ -0,0 +1,190 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/utils"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryNotificationLog is the NotificationLog of the tests, keyed like the table by user and story
+type memoryNotificationLog struct {
+	mutex   sync.Mutex
+	entries map[string]schemas.NotificationLogSchema
+}
+
+func newMemoryNotificationLog() *memoryNotificationLog {
+	return &memoryNotificationLog{entries: map[string]schemas.NotificationLogSchema{}}
+}
+
+func (notificationLog *memoryNotificationLog) Claim(ctx context.Context, notification schemas.NotificationLogSchema) (bool, error) {
+	notificationLog.mutex.Lock()
+	defer notificationLog.mutex.Unlock()
+
+	key := notification.UserId + "|" + notification.StoryId
+	if _, exists := notificationLog.entries[key]; exists {
+		return false, nil
+	}
+	notificationLog.entries[key] = notification
+	return true, nil
+}
+
+func (notificationLog *memoryNotificationLog) UpdateStatus(ctx context.Context, userId string, storyId string, status string, details string) error {
+	notificationLog.mutex.Lock()
+	defer notificationLog.mutex.Unlock()
+
+	key := userId + "|" + storyId
+	entry := notificationLog.entries[key]
+	entry.Status, entry.Details = status, details
+	notificationLog.entries[key] = entry
+	return nil
+}
+
+func (notificationLog *memoryNotificationLog) get(userId string, storyId string) (schemas.NotificationLogSchema, bool) {
+	notificationLog.mutex.Lock()
+	defer notificationLog.mutex.Unlock()
+
+	entry, ok := notificationLog.entries[userId+"|"+storyId]
+	return entry, ok
+}
+
+// startNotificationReceiver counts the webhook notifications received per article
+func startNotificationReceiver(t *testing.T, status int) (*httptest.Server, func() map[string]int) {
+	t.Helper()
+
+	// the registered webhook channel refuses loopback addresses, the receiver runs on one
+	utils.RegisterNotificationChannel(utils.WebhookChannel{Client: http.DefaultClient})
+	t.Cleanup(func() { utils.RegisterNotificationChannel(utils.WebhookChannel{}) })
+
+	var mutex sync.Mutex
+	received := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification utils.Notification
+		json.NewDecoder(r.Body).Decode(&notification)
+
+		mutex.Lock()
+		received[notification.ArticleId]++
+		mutex.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() map[string]int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		counts := map[string]int{}
+		for articleId, count := range received {
+			counts[articleId] = count
+		}
+		return counts
+	}
+}
+
+func alertArticle(articleId string, storyId string) schemas.ArticleSchema {
+	return schemas.ArticleSchema{
+		ArticleId: articleId,
+		StoryId:   &storyId,
+		Title:     "Central bank raises rates",
+		Summary:   "The central bank raised its policy rate by a quarter point.",
+		Url:       "https://news.example/" + articleId,
+	}
+}
+
+func TestNotifyAlertRulesSendsEveryStoryOnce(t *testing.T) {
+	server, received := startNotificationReceiver(t, http.StatusOK)
+	notificationLog := newMemoryNotificationLog()
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+
+	rules := []schemas.AlertRuleSchema{
+		{RuleId: 1, UserId: "reader", Channels: []string{"webhook"}, WebhookUrl: server.URL},
+		{RuleId: 2, UserId: "reader", Channels: []string{"webhook"}, WebhookUrl: server.URL},
+		{RuleId: 3, UserId: "other-reader", Channels: []string{"webhook"}, WebhookUrl: server.URL},
+	}
+
+	NotifyAlertRules(context.Background(), notificationLog, alertArticle("a1", "story-1"), rules, now)
+	// a second article of the same story is not sent again
+	NotifyAlertRules(context.Background(), notificationLog, alertArticle("a2", "story-1"), rules, now)
+
+	if counts := received(); counts["a1"] != 2 || counts["a2"] != 0 {
+		t.Errorf("received %v, want a1 once per user and a2 never", counts)
+	}
+
+	for _, userId := range []string{"reader", "other-reader"} {
+		entry, ok := notificationLog.get(userId, "story-1")
+		if !ok || entry.Status != "sent" || entry.ArticleId != "a1" {
+			t.Errorf("log of %s = %+v, %t", userId, entry, ok)
+		}
+	}
+	if entry, _ := notificationLog.get("reader", "story-1"); entry.RuleId != 1 {
+		t.Errorf("claimed by rule %d, want the first matching rule", entry.RuleId)
+	}
+}
+
+func TestNotifyAlertRulesSuppressesQuietHours(t *testing.T) {
+	server, received := startNotificationReceiver(t, http.StatusOK)
+	notificationLog := newMemoryNotificationLog()
+
+	start, end := 22, 7
+	rule := schemas.AlertRuleSchema{
+		RuleId:          1,
+		UserId:          "reader",
+		Channels:        []string{"webhook"},
+		WebhookUrl:      server.URL,
+		QuietHoursStart: &start,
+		QuietHoursEnd:   &end,
+		Timezone:        "UTC",
+	}
+
+	NotifyAlertRules(context.Background(), notificationLog, alertArticle("a1", "story-1"), []schemas.AlertRuleSchema{rule},
+		time.Date(2024, 5, 6, 23, 30, 0, 0, time.UTC))
+
+	if counts := received(); counts["a1"] != 0 {
+		t.Errorf("received %v during quiet hours", counts)
+	}
+	if entry, _ := notificationLog.get("reader", "story-1"); entry.Status != "suppressed" {
+		t.Errorf("status = %q, want suppressed", entry.Status)
+	}
+
+	// the story stays claimed, it is not sent once the quiet hours are over
+	NotifyAlertRules(context.Background(), notificationLog, alertArticle("a2", "story-1"), []schemas.AlertRuleSchema{rule},
+		time.Date(2024, 5, 7, 9, 0, 0, 0, time.UTC))
+	if counts := received(); counts["a2"] != 0 {
+		t.Errorf("received %v after the quiet hours", counts)
+	}
+}
+
+func TestNotifyAlertRulesRecordsChannelFailures(t *testing.T) {
+	failingServer, _ := startNotificationReceiver(t, http.StatusInternalServerError)
+	workingServer, received := startNotificationReceiver(t, http.StatusOK)
+	notificationLog := newMemoryNotificationLog()
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+
+	rules := []schemas.AlertRuleSchema{
+		// one channel failing is recorded, the notification still counts as sent
+		{RuleId: 1, UserId: "reader", Channels: []string{"fcm", "webhook"}, WebhookUrl: workingServer.URL},
+		{RuleId: 2, UserId: "other-reader", Channels: []string{"webhook", "pigeon"}, WebhookUrl: failingServer.URL},
+	}
+
+	NotifyAlertRules(context.Background(), notificationLog, alertArticle("a1", "story-1"), rules, now)
+
+	if counts := received(); counts["a1"] != 1 {
+		t.Errorf("received %v, want a1 once", counts)
+	}
+
+	sent, _ := notificationLog.get("reader", "story-1")
+	if sent.Status != "sent" || sent.Details != "fcm: no fcm token" {
+		t.Errorf("log of reader = %+v", sent)
+	}
+
+	failed, _ := notificationLog.get("other-reader", "story-1")
+	if failed.Status != "failed" || failed.Details == "" {
+		t.Errorf("log of other-reader = %+v", failed)
+	}
+}
@@ -0,0 +1,36 @@
+package workers
+
+import (
+	"context"
+	"service-news-app-backend/utils"
+)
+
+// enrichment progress events of an article, in the order they happen
+const (
+	ArticleEventQueued       = "queued"        // -- waiting in the enrichment queue
+	ArticleEventMetadata     = "metadata"      // -- categories, entities and sentiment extracted
+	ArticleEventSummaryDelta = "summary_delta" // -- a piece of the summary as it is generated, not replayed
+	ArticleEventSummary      = "summary"       // -- the whole summary
+	ArticleEventEmbedding    = "embedding"     // -- embeddings generated, or failed without stopping the enrichment
+	ArticleEventPublished    = "published"     // -- stored and visible, the last event
+	ArticleEventFailed       = "failed"        // -- the enrichment stopped, the last event
+)
+
+// ArticleEventsTopic is the event bus topic of the enrichment progress of an article
+func ArticleEventsTopic(articleId string) string {
+	return "article:" + articleId
+}
+
+// PublishArticleEvent publishes an enrichment progress event of the article to its subscribers on every instance
+func PublishArticleEvent(ctx context.Context, articleId string, eventType string, data interface{}) {
+	if eventType == ArticleEventSummaryDelta {
+		utils.PublishTransientEvent(ctx, ArticleEventsTopic(articleId), eventType, data)
+		return
+	}
+	utils.PublishEvent(ctx, ArticleEventsTopic(articleId), eventType, data)
+}
+
+// StartArticleEventRelay relays the article events published by the other instances through redis
+func StartArticleEventRelay(ctx context.Context) {
+	utils.StartEventRelay(ctx)
+}
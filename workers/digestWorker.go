@@ -0,0 +1,247 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	htmlTemplate "html/template"
+	"log"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"sort"
+	textTemplate "text/template"
+	"time"
+)
+
+// DefaultDigestEdition is the edition generated by the schedule
+const DefaultDigestEdition = "daily"
+
+const digestHtmlTemplate = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family: Georgia, serif; max-width: 640px; margin: auto;">
+  <h1>{{.Title}}</h1>
+  <p style="color: #666;">{{.PeriodEnd.Format "Monday, January 2, 2006"}}</p>
+  <p>{{.Intro}}</p>
+  {{range .Sections}}
+  <h2>{{.Category}}</h2>
+  {{range .Stories}}
+  <h3><a href="{{.Url}}">{{.Title}}</a></h3>
+  <p>{{.Blurb}} <em>{{.Publisher}}</em></p>
+  {{end}}
+  {{end}}
+</body>
+</html>
+`
+
+const digestTextTemplate = `{{.Title}}
+{{.PeriodEnd.Format "Monday, January 2, 2006"}}
+
+{{.Intro}}
+{{range .Sections}}
+== {{.Category}} ==
+{{range .Stories}}
+* {{.Title}} ({{.Publisher}})
+  {{.Blurb}}
+  {{.Url}}
+{{end}}{{end}}`
+
+var (
+	digestHtml = htmlTemplate.Must(htmlTemplate.New("digest").Parse(digestHtmlTemplate))
+	digestText = textTemplate.Must(textTemplate.New("digest").Parse(digestTextTemplate))
+)
+
+// GenerateDigest selects the top stories per category of the period ending at periodEnd, has the LLM
+// write the intro and blurbs, renders the HTML and plain-text versions and stores the digest
+func GenerateDigest(ctx context.Context, edition string, periodEnd time.Time) (*schemas.DigestSchema, error) {
+	pool := PostgresInstance.GetPostgresInstance()
+	periodHours := config.GetIntEnvironmentVariable("DIGEST_PERIOD_HOURS", 24)
+	storiesPerCategory := config.GetIntEnvironmentVariable("DIGEST_STORIES_PER_CATEGORY", 3)
+	maxCategories := config.GetIntEnvironmentVariable("DIGEST_MAX_CATEGORIES", 6)
+
+	digest := schemas.DigestSchema{
+		Edition:     edition,
+		Title:       config.GetEnvironmentVariableOrDefault("DIGEST_TITLE", "Morning Briefing"),
+		PeriodStart: periodEnd.Add(-time.Duration(periodHours) * time.Hour).UTC(),
+		PeriodEnd:   periodEnd.UTC(),
+	}
+
+	articles, err := schemas.GetTopArticlesInPeriod(ctx, pool, digest.PeriodStart, digest.PeriodEnd, 500)
+	if err != nil {
+		return nil, err
+	}
+
+	digest.Sections = selectDigestSections(articles, storiesPerCategory, maxCategories)
+
+	// the LLM writes from the summaries, falling back to them when it can't
+	stories := []utils.DigestStory{}
+	summaries := map[string]string{}
+	for _, article := range articles {
+		summaries[article.ArticleId] = article.Summary
+	}
+	for _, section := range digest.Sections {
+		for _, story := range section.Stories {
+			stories = append(stories, utils.DigestStory{
+				Id:       story.ArticleId,
+				Category: section.Category,
+				Title:    story.Title,
+				Summary:  summaries[story.ArticleId],
+			})
+		}
+	}
+
+	intro, blurbs := "", map[string]string{}
+	if len(stories) > 0 {
+		intro, blurbs, err = utils.GenerateDigestCopy(ctx, digest.Title, stories)
+		if err != nil {
+			log.Println("Error generating digest copy, using summaries: ", err)
+		}
+	}
+	if intro == "" {
+		intro = "Here are the stories that mattered most."
+		if len(stories) == 0 {
+			intro = "It was a quiet period, no major stories to report."
+		}
+	}
+	digest.Intro = intro
+
+	for i := range digest.Sections {
+		for j := range digest.Sections[i].Stories {
+			story := &digest.Sections[i].Stories[j]
+			story.Blurb = blurbs[story.ArticleId]
+			if story.Blurb == "" {
+				story.Blurb = truncateText(summaries[story.ArticleId], 280)
+			}
+		}
+	}
+
+	digest.Html, digest.Text, err = renderDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return schemas.InsertDigest(ctx, pool, digest)
+}
+
+// renderDigest renders the HTML and plain-text versions of a digest
+func renderDigest(digest schemas.DigestSchema) (string, string, error) {
+	var html, text bytes.Buffer
+	if err := digestHtml.Execute(&html, digest); err != nil {
+		return "", "", err
+	}
+	if err := digestText.Execute(&text, digest); err != nil {
+		return "", "", err
+	}
+	return html.String(), text.String(), nil
+}
+
+// selectDigestSections groups the articles (most important first) by their first category,
+// keeping one article per story, and orders the categories by their top story
+func selectDigestSections(articles []schemas.ArticleSchema, storiesPerCategory int, maxCategories int) []schemas.DigestSectionSchema {
+	sections := []schemas.DigestSectionSchema{}
+	sectionIndex := map[string]int{}
+	seenStories := map[string]bool{}
+
+	for _, article := range articles {
+		storyId := article.ArticleId
+		if article.StoryId != nil {
+			storyId = *article.StoryId
+		}
+		if seenStories[storyId] {
+			continue
+		}
+
+		category := "Top Stories"
+		if len(article.Categories) > 0 {
+			category = article.Categories[0]
+		}
+
+		index, ok := sectionIndex[category]
+		if !ok {
+			if len(sections) >= maxCategories {
+				continue
+			}
+			index = len(sections)
+			sectionIndex[category] = index
+			sections = append(sections, schemas.DigestSectionSchema{Category: category})
+		}
+		if len(sections[index].Stories) >= storiesPerCategory {
+			continue
+		}
+
+		seenStories[storyId] = true
+		sections[index].Stories = append(sections[index].Stories, schemas.DigestStorySchema{
+			ArticleId:       article.ArticleId,
+			StoryId:         storyId,
+			Title:           article.Title,
+			Url:             article.Url,
+			Publisher:       article.Publisher,
+			PublicationDate: article.PublicationDate,
+			ImportanceScore: article.ImportanceScore,
+		})
+	}
+
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Stories[0].ImportanceScore > sections[j].Stories[0].ImportanceScore
+	})
+
+	return sections
+}
+
+// StartDigestWorker generates the daily digest on the cron schedule in DIGEST_SCHEDULE
+// (e.g. "0 6 * * *"), evaluated in DIGEST_TIMEZONE
+func StartDigestWorker(ctx context.Context) {
+	schedule, err := utils.ParseCronSchedule(config.GetEnvironmentVariableOrDefault("DIGEST_SCHEDULE", "0 6 * * *"))
+	if err != nil {
+		log.Println("Digest worker not started: ", err)
+		return
+	}
+
+	location, err := time.LoadLocation(config.GetEnvironmentVariableOrDefault("DIGEST_TIMEZONE", "UTC"))
+	if err != nil {
+		log.Println("Digest worker not started: ", err)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		var lastRun time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				minute := now.In(location).Truncate(time.Minute)
+				if minute.Equal(lastRun) || !schedule.Matches(minute) {
+					continue
+				}
+				lastRun = minute
+
+				RunDigestJob(ctx, minute)
+			}
+		}
+	}()
+}
+
+// RunDigestJob generates the scheduled digest unless another instance already did for this run
+func RunDigestJob(ctx context.Context, scheduledAt time.Time) {
+	latest, err := schemas.GetLatestDigest(ctx, PostgresInstance.GetPostgresInstance(), DefaultDigestEdition)
+	if err != nil {
+		log.Println("Error fetching latest digest: ", err)
+		return
+	}
+	if latest != nil && !latest.PeriodEnd.Before(scheduledAt.UTC()) {
+		return
+	}
+
+	digest, err := GenerateDigest(ctx, DefaultDigestEdition, scheduledAt)
+	if err != nil {
+		log.Println("Error generating digest: ", err)
+		return
+	}
+
+	log.Printf("Generated %s digest %d with %d sections\n", digest.Edition, digest.DigestId, len(digest.Sections))
+}
@@ -0,0 +1,278 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"strings"
+)
+
+var enrichmentQueue chan string
+
+// EnrichmentProgress receives the progress events of an enrichment, see the ArticleEvent types
+type EnrichmentProgress func(eventType string, data interface{})
+
+// EnrichArticle fills the generated fields (summary, entities, sentiment, categories, embedding,
+// importance and story) of the article, publishing its progress as article events
+func EnrichArticle(ctx context.Context, article *schemas.ArticleSchema) error {
+	progress := func(eventType string, data interface{}) {
+		PublishArticleEvent(ctx, article.ArticleId, eventType, data)
+	}
+
+	if err := GenerateArticleMetadata(ctx, article, progress); err != nil {
+		return err
+	}
+
+	// embeddings only feed ranking and passage search, the article is still stored without one
+	embedding, chunks, err := utils.GenerateArticleEmbeddings(ctx, article.Title, article.Content)
+	if err != nil {
+		log.Printf("Error generating embedding for article %s: %v\n", article.ArticleId, err)
+		progress(ArticleEventEmbedding, map[string]interface{}{"error": err.Error()})
+	} else {
+		article.Embedding = embedding
+		article.Chunks = articleChunks(chunks)
+		progress(ArticleEventEmbedding, map[string]interface{}{"chunks": len(chunks)})
+	}
+
+	// importance drives breaking-news alerts, a failure only means no alert for this article
+	importanceScore, promptVersion, err := utils.GenerateImportanceScore(ctx, article.ArticleId, article.Title, article.Summary)
+	if err != nil {
+		log.Printf("Error scoring importance of article %s: %v\n", article.ArticleId, err)
+	} else {
+		article.ImportanceScore = importanceScore
+		article.PromptVersions["importance"] = promptVersion
+	}
+
+	if err := AssignStory(ctx, article); err != nil {
+		log.Printf("Error assigning story to article %s: %v\n", article.ArticleId, err)
+	}
+
+	return nil
+}
+
+// articleChunks converts the embedded passages of an article into chunk rows
+func articleChunks(chunks []utils.EmbeddedChunk) []schemas.ArticleChunkSchema {
+	articleChunks := []schemas.ArticleChunkSchema{}
+	for _, chunk := range chunks {
+		articleChunks = append(articleChunks, schemas.ArticleChunkSchema{
+			ChunkIndex:  chunk.Index,
+			Content:     chunk.Text,
+			StartOffset: chunk.Start,
+			EndOffset:   chunk.End,
+			TokenCount:  chunk.Tokens,
+			Embedding:   chunk.Embedding,
+		})
+	}
+	return articleChunks
+}
+
+// StoreArticleChunks replaces the stored passages of an enriched article. Chunks only feed passage search,
+// a failure leaves the previous ones in place.
+func StoreArticleChunks(ctx context.Context, article schemas.ArticleSchema) {
+	if article.Chunks == nil {
+		return
+	}
+	if err := schemas.ReplaceArticleChunks(ctx, PostgresInstance.GetPostgresInstance(), article.ArticleId, article.Chunks); err != nil {
+		log.Printf("Error storing chunks of article %s: %v\n", article.ArticleId, err)
+	}
+}
+
+// GenerateArticleMetadata fills the fields of the article generated from its text alone: summary, entities,
+// sentiment, categories, language, enrichment confidence and review status. It touches neither the
+// database nor the embeddings, so evaluations can run it offline. progress, when set, receives the metadata
+// and the summary as it streams.
+func GenerateArticleMetadata(ctx context.Context, article *schemas.ArticleSchema, progress EnrichmentProgress) error {
+
+	// prompt versions are picked per article, recorded to compare their output
+	promptVersions := map[string]string{}
+
+	// categories can come from the offline classifier instead (CATEGORIES_PROVIDER=classifier), it runs first
+	// so the article is still tagged when the metadata call fails
+	classifier, err := utils.GetCategoryClassifier()
+	if err != nil {
+		return err
+	}
+	var classifierCategories []string
+	var classifierConfidence int
+	if classifier != nil {
+		classifierCategories, classifierConfidence = classifier.Classify(article.Title + "\n\n" + article.Content)
+		promptVersions["categories"] = "classifier-" + classifier.TrainedAt.Format("20060102T150405")
+	}
+
+	// Call OpenAI API to get categories
+	responseFromOpenAI, promptVersion, err := utils.GetResponseFromChatGPT(ctx, article.ArticleId, article.Content)
+	if err != nil {
+		if classifier == nil {
+			return err
+		}
+		// without entities and sentiment the low confidence sends the article to review
+		log.Printf("Error extracting metadata of article %s, keeping the classifier categories: %v\n", article.ArticleId, err)
+		responseFromOpenAI = &utils.MetaData{
+			Entities:   utils.Entities{Organizations: []string{}, Locations: []string{}, Individuals: []string{}},
+			Confidence: classifierConfidence,
+		}
+	} else {
+		promptVersions["metadata"] = promptVersion
+	}
+	for _, fieldError := range responseFromOpenAI.FieldErrors {
+		log.Printf("Invalid metadata of article %s: %v\n", article.ArticleId, fieldError)
+	}
+
+	if classifier != nil {
+		responseFromOpenAI.Categories = classifierCategories
+		if classifierConfidence < responseFromOpenAI.Confidence {
+			responseFromOpenAI.Confidence = classifierConfidence
+		}
+	}
+
+	var onSummaryDelta func(string) error
+	if progress != nil {
+		progress(ArticleEventMetadata, map[string]interface{}{
+			"categories":     responseFromOpenAI.Categories,
+			"entities":       responseFromOpenAI.Entities,
+			"sentimentScore": responseFromOpenAI.SentimentScore,
+		})
+		onSummaryDelta = func(delta string) error {
+			progress(ArticleEventSummaryDelta, map[string]string{"text": delta})
+			return nil
+		}
+	}
+
+	// generating summary
+	summary, promptVersion, err := utils.GenerateSummary(ctx, article.ArticleId, article.Content, onSummaryDelta)
+	if err != nil {
+		return err
+	}
+	promptVersions["summary"] = promptVersion
+	if progress != nil {
+		progress(ArticleEventSummary, map[string]string{"summary": summary})
+	}
+
+	article.Summary = summary
+	article.Entities = responseFromOpenAI.Entities
+	article.SentimentScore = responseFromOpenAI.SentimentScore
+	article.Categories = responseFromOpenAI.Categories
+
+	// low confidence enrichments wait in the review queue for an editor
+	article.EnrichmentConfidence = enrichmentConfidence(*responseFromOpenAI, summary)
+	if article.EnrichmentConfidence < config.GetIntEnvironmentVariable("REVIEW_CONFIDENCE_THRESHOLD", 60) {
+		article.ReviewStatus = "pending"
+	} else if article.ReviewStatus == "pending" {
+		article.ReviewStatus = ""
+	}
+
+	// publishers don't always tell us the language
+	if article.Language == "" {
+		article.Language = utils.DetectLanguage(article.Title + "\n" + article.Content)
+	}
+
+	article.PromptVersions = promptVersions
+
+	return nil
+}
+
+// enrichmentConfidence is the confidence reported by the LLM, capped when the output itself looks off
+// (invalid fields, no categories, a sentiment outside the known ones or no summary)
+func enrichmentConfidence(metaData utils.MetaData, summary string) int {
+	confidence := metaData.Confidence
+	if confidence <= 0 || confidence > 100 {
+		confidence = 100
+	}
+
+	if len(metaData.FieldErrors) > 0 && confidence > 20 {
+		confidence = 20
+	}
+
+	if (len(metaData.Categories) == 0 || strings.TrimSpace(summary) == "") && confidence > 20 {
+		confidence = 20
+	}
+	if !utils.Includes([]string{"Positive", "Negative", "Neutral"}, metaData.SentimentScore) && confidence > 40 {
+		confidence = 40
+	}
+
+	return confidence
+}
+
+// StartEnrichmentWorkers starts the background workers consuming the enrichment queue
+// and re-queues the articles left pending by a previous run
+func StartEnrichmentWorkers(ctx context.Context) {
+	workerCount := config.GetIntEnvironmentVariable("ENRICHMENT_WORKER_COUNT", 4)
+	queueSize := config.GetIntEnvironmentVariable("ENRICHMENT_QUEUE_SIZE", 10000)
+
+	enrichmentQueue = make(chan string, queueSize)
+
+	for i := 0; i < workerCount; i++ {
+		go runEnrichmentWorker(ctx)
+	}
+
+	pendingArticleIds, err := schemas.GetArticleIDsByStatus(ctx, PostgresInstance.GetPostgresInstance(), "pending")
+	if err != nil {
+		log.Println("Error fetching pending articles: ", err)
+		return
+	}
+
+	go func() {
+		for _, articleId := range pendingArticleIds {
+			enrichmentQueue <- articleId
+		}
+	}()
+}
+
+// EnqueueEnrichment schedules the article for background enrichment.
+// It returns false when the queue is full; the article stays pending and is picked up on the next start.
+func EnqueueEnrichment(articleId string) bool {
+	select {
+	case enrichmentQueue <- articleId:
+		PublishArticleEvent(context.Background(), articleId, ArticleEventQueued, map[string]string{"articleId": articleId})
+		return true
+	default:
+		return false
+	}
+}
+
+func runEnrichmentWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case articleId := <-enrichmentQueue:
+			if err := enrichArticleByID(ctx, articleId); err != nil {
+				log.Printf("Error enriching article %s: %v\n", articleId, err)
+				PublishArticleEvent(ctx, articleId, ArticleEventFailed, map[string]string{"error": err.Error()})
+			}
+		}
+	}
+}
+
+func enrichArticleByID(ctx context.Context, articleId string) error {
+	pool := PostgresInstance.GetPostgresInstance()
+
+	article, err := schemas.GetArticleByID(ctx, pool, articleId)
+	if err != nil {
+		return err
+	}
+	if article == nil {
+		return fmt.Errorf("article not found")
+	}
+
+	if err := EnrichArticle(ctx, article); err != nil {
+		return err
+	}
+
+	article.Status = "published"
+	if err := schemas.UpdateArticleByID(ctx, pool, *article); err != nil {
+		return err
+	}
+	StoreArticleChunks(ctx, *article)
+	PublishArticleEvent(ctx, articleId, ArticleEventPublished, map[string]interface{}{"status": article.Status, "version": article.Version})
+
+	utils.BumpCacheGeneration(ctx, utils.RelatedArticlesCacheNamespace)
+	DispatchAlerts(ctx, *article)
+	EmitWebhookEvent(ctx, "article.published", *article)
+	PercolateSavedSearches(ctx, *article)
+	return nil
+}
@@ -0,0 +1,58 @@
+package workers
+
+import (
+	"context"
+	"log"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"time"
+)
+
+// LoadPromptRegistry loads the stored prompt versions into the prompt registry
+func LoadPromptRegistry(ctx context.Context) error {
+	storedVersions, err := schemas.GetPromptVersions(ctx, PostgresInstance.GetPostgresInstance())
+	if err != nil {
+		return err
+	}
+
+	prompts := make([]utils.PromptVersion, 0, len(storedVersions))
+	for _, storedVersion := range storedVersions {
+		prompts = append(prompts, utils.PromptVersion{
+			Name:          storedVersion.Name,
+			Version:       storedVersion.Version,
+			System:        storedVersion.System,
+			User:          storedVersion.User,
+			Model:         storedVersion.Model,
+			Temperature:   storedVersion.Temperature,
+			TrafficWeight: storedVersion.TrafficWeight,
+		})
+	}
+
+	utils.SetStoredPromptVersions(prompts)
+	return nil
+}
+
+// StartPromptRegistryWorker periodically reloads the stored prompt versions, so versions and traffic
+// changes made through another instance are picked up
+func StartPromptRegistryWorker(ctx context.Context) {
+	interval := time.Duration(config.GetIntEnvironmentVariable("PROMPT_REFRESH_SECONDS", 60)) * time.Second
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := LoadPromptRegistry(ctx); err != nil {
+				log.Println("Error loading prompt versions: ", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,166 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"time"
+)
+
+// actor recorded in the purge audit for deletions made by the retention job
+const retentionJobActor = "retention-job"
+
+// PurgeArticle permanently removes an article with everything stored about it (see schemas.HardDeleteArticle),
+// its stored S3 content and cache entries, and records the purge in the audit trail.
+// The S3 content goes first: when it can't be deleted nothing is, and the purge can be retried.
+// It returns nil when the article did not exist.
+func PurgeArticle(ctx context.Context, articleId string, actor string, reason string) (*schemas.ArticleSchema, error) {
+	pool := PostgresInstance.GetPostgresInstance()
+
+	contentS3Path, found, err := schemas.GetArticleContentS3Path(ctx, pool, articleId)
+	if err != nil || !found {
+		return nil, err
+	}
+	if contentS3Path != "" {
+		if err := utils.DeleteS3Object(ctx, contentS3Path); err != nil {
+			return nil, fmt.Errorf("error deleting content of article %s: %v", articleId, err)
+		}
+	}
+
+	article, err := schemas.HardDeleteArticle(ctx, pool, articleId, schemas.PurgeAuditSchema{
+		Action: "hard_purge",
+		Actor:  actor,
+		Reason: reason,
+	}, renderDigest)
+	if err != nil || article == nil {
+		return nil, err
+	}
+
+	// cached related lists may still reference the article
+	utils.BumpCacheGeneration(ctx, utils.RelatedArticlesCacheNamespace)
+
+	return article, nil
+}
+
+// PurgeArticleContent drops the full content of an article from S3 and the database,
+// keeping its summary and metadata, and records it in the audit trail
+func PurgeArticleContent(ctx context.Context, article schemas.ArticleSchema, actor string, reason string) error {
+	pool := PostgresInstance.GetPostgresInstance()
+
+	if article.ContentS3Path != "" {
+		if err := utils.DeleteS3Object(ctx, article.ContentS3Path); err != nil {
+			return fmt.Errorf("error deleting content of article %s: %v", article.ArticleId, err)
+		}
+	}
+
+	return schemas.PurgeArticleContent(ctx, pool, article.ArticleId, schemas.PurgeAuditSchema{
+		Publisher: article.Publisher,
+		Action:    "content_purge",
+		Actor:     actor,
+		Reason:    reason,
+	})
+}
+
+// StartRetentionWorker periodically applies the retention policies
+func StartRetentionWorker(ctx context.Context) {
+	interval := time.Duration(config.GetIntEnvironmentVariable("RETENTION_JOB_INTERVAL_MINUTES", 60)) * time.Minute
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			RunRetentionJob(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunRetentionJob applies every retention policy once and purges soft deleted articles past their grace period
+func RunRetentionJob(ctx context.Context) {
+	pool := PostgresInstance.GetPostgresInstance()
+	batchSize := config.GetIntEnvironmentVariable("RETENTION_JOB_BATCH_SIZE", 500)
+
+	policies, err := schemas.GetRetentionPolicies(ctx, pool)
+	if err != nil {
+		log.Println("Error fetching retention policies: ", err)
+		return
+	}
+
+	// publishers with their own policy are skipped by the default one
+	explicitPublishers := []string{}
+	for _, policy := range policies {
+		if policy.Publisher != schemas.DefaultRetentionPublisher {
+			explicitPublishers = append(explicitPublishers, policy.Publisher)
+		}
+	}
+
+	for _, policy := range policies {
+		filter := schemas.RetentionCandidateFilter{Publisher: policy.Publisher, Limit: batchSize}
+		if policy.Publisher == schemas.DefaultRetentionPublisher {
+			filter.Publisher = ""
+			filter.ExcludePublishers = explicitPublishers
+		}
+
+		if policy.ArticleRetentionDays != nil {
+			filter.OlderThan = daysAgo(*policy.ArticleRetentionDays)
+			filter.ContentOnly = false
+
+			articles, err := schemas.GetRetentionCandidates(ctx, pool, filter)
+			if err != nil {
+				log.Println("Error fetching articles to purge: ", err)
+			}
+			for _, article := range articles {
+				_, err := PurgeArticle(ctx, article.ArticleId, retentionJobActor, "article retention policy for "+policy.Publisher)
+				if err != nil {
+					log.Printf("Error purging article %s: %v\n", article.ArticleId, err)
+				}
+			}
+		}
+
+		if policy.ContentRetentionDays != nil {
+			filter.OlderThan = daysAgo(*policy.ContentRetentionDays)
+			filter.ContentOnly = true
+
+			articles, err := schemas.GetRetentionCandidates(ctx, pool, filter)
+			if err != nil {
+				log.Println("Error fetching articles to purge content: ", err)
+			}
+			for _, article := range articles {
+				err := PurgeArticleContent(ctx, article, retentionJobActor, "content retention policy for "+policy.Publisher)
+				if err != nil {
+					log.Printf("Error purging content of article %s: %v\n", article.ArticleId, err)
+				}
+			}
+		}
+	}
+
+	// soft deleted articles are kept for a grace period before being purged
+	deletedArticles, err := schemas.GetRetentionCandidates(ctx, pool, schemas.RetentionCandidateFilter{
+		OlderThan:   daysAgo(config.GetIntEnvironmentVariable("SOFT_DELETE_RETENTION_DAYS", 30)),
+		DeletedOnly: true,
+		Limit:       batchSize,
+	})
+	if err != nil {
+		log.Println("Error fetching deleted articles to purge: ", err)
+	}
+	for _, article := range deletedArticles {
+		_, err := PurgeArticle(ctx, article.ArticleId, retentionJobActor, "soft delete grace period expired")
+		if err != nil {
+			log.Printf("Error purging article %s: %v\n", article.ArticleId, err)
+		}
+	}
+}
+
+func daysAgo(days int) time.Time {
+	return time.Now().AddDate(0, 0, -days)
+}
@@ -0,0 +1,51 @@
+package workers
+
+import (
+	"context"
+	"log"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/utils"
+)
+
+// PercolateSavedSearches matches a freshly stored article against every saved search and pushes
+// it to the searches that ask for webhook or email delivery
+func PercolateSavedSearches(ctx context.Context, article schemas.ArticleSchema) {
+	searches, err := schemas.PercolateArticle(ctx, PostgresInstance.GetPostgresInstance(), article.ArticleId)
+	if err != nil {
+		log.Printf("Error matching article %s against saved searches: %v\n", article.ArticleId, err)
+		return
+	}
+
+	for _, search := range searches {
+		if len(search.Channels) == 0 {
+			continue
+		}
+
+		notification := utils.Notification{
+			Title:     "New match for \"" + search.Name + "\": " + article.Title,
+			Body:      truncateText(article.Summary, 200),
+			Url:       article.Url,
+			ArticleId: article.ArticleId,
+			Data: map[string]interface{}{
+				"searchId":  search.SearchId,
+				"query":     search.Query,
+				"publisher": article.Publisher,
+			},
+		}
+		target := utils.NotificationTarget{
+			WebhookUrl: search.WebhookUrl,
+			Email:      search.Email,
+		}
+
+		for _, channelName := range search.Channels {
+			channel, ok := utils.GetNotificationChannel(channelName)
+			if !ok {
+				continue
+			}
+			if err := channel.Send(ctx, target, notification); err != nil {
+				log.Printf("Error delivering saved search %d match over %s: %v\n", search.SearchId, channelName, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RetrievedArticle is an article found by hybrid retrieval with the signals it was ranked on
+type RetrievedArticle struct {
+	Article    schemas.ArticleSchema `json:"article"`
+	Score      float64               `json:"score"`      // -- reciprocal rank fusion of both rankings, times the freshness boost
+	TextRank   int                   `json:"textRank"`   // -- 1-based, 0 when full-text search didn't match
+	VectorRank int                   `json:"vectorRank"` // -- 1-based, 0 when not among the nearest embeddings
+	Similarity float64               `json:"similarity"` // -- cosine similarity to the query embedding
+}
+
+type HybridSearchOptions struct {
+	Filter          schemas.ArticleSearchFilter
+	Candidates      int     // -- articles taken from each ranking before fusion
+	FreshnessWeight float64 // -- 0 disables the boost, 1 doubles the score of an article published right now
+}
+
+// HybridSearch runs the full-text and the embedding nearest neighbour searches in parallel and fuses
+// their rankings with reciprocal rank fusion, boosting recent articles when asked to. Without
+// embeddings it degrades to full-text search only. Results are ordered best first.
+func HybridSearch(ctx context.Context, query string, options HybridSearchOptions) ([]RetrievedArticle, error) {
+	pool := PostgresInstance.GetPostgresInstance()
+
+	var waitGroup sync.WaitGroup
+	var textMatches, vectorMatches []schemas.ArticleSchema
+	var textErr, vectorErr error
+	var queryEmbedding []float32
+
+	waitGroup.Add(2)
+	go func() {
+		defer waitGroup.Done()
+		textMatches, textErr = schemas.SearchArticlesText(ctx, pool, query, options.Filter, options.Candidates)
+	}()
+	go func() {
+		defer waitGroup.Done()
+		embedding, err := utils.GenerateVectorEmebeddings(ctx, query)
+		if err != nil {
+			log.Println("Error embedding query, using full-text search only: ", err)
+			return
+		}
+		queryEmbedding = embedding
+		vectorMatches, vectorErr = schemas.GetNearestArticlesFiltered(ctx, pool, embedding, options.Filter, options.Candidates)
+	}()
+	waitGroup.Wait()
+
+	if textErr != nil {
+		return nil, textErr
+	}
+	if vectorErr != nil {
+		return nil, vectorErr
+	}
+
+	articles := map[string]schemas.ArticleSchema{}
+	textRanking, vectorRanking := []string{}, []string{}
+	textRanks, vectorRanks := map[string]int{}, map[string]int{}
+	for rank, article := range textMatches {
+		articles[article.ArticleId] = article
+		textRanking = append(textRanking, article.ArticleId)
+		textRanks[article.ArticleId] = rank + 1
+	}
+	for rank, article := range vectorMatches {
+		articles[article.ArticleId] = article
+		vectorRanking = append(vectorRanking, article.ArticleId)
+		vectorRanks[article.ArticleId] = rank + 1
+	}
+
+	scores := utils.ReciprocalRankFusion(config.GetIntEnvironmentVariable("SEARCH_RRF_K", 60), textRanking, vectorRanking)
+	halfLifeHours := float64(config.GetPositiveIntEnvironmentVariable("SEARCH_FRESHNESS_HALF_LIFE_HOURS", 72))
+	now := time.Now()
+
+	results := []RetrievedArticle{}
+	for articleId, score := range scores {
+		article := articles[articleId]
+
+		if options.FreshnessWeight > 0 {
+			ageHours := now.Sub(article.PublicationDate).Hours()
+			score *= 1 + options.FreshnessWeight*utils.FreshnessScore(ageHours, halfLifeHours)
+		}
+
+		result := RetrievedArticle{
+			Article:    article,
+			Score:      score,
+			TextRank:   textRanks[articleId],
+			VectorRank: vectorRanks[articleId],
+		}
+		if len(queryEmbedding) > 0 && len(article.Embedding) > 0 {
+			result.Similarity = utils.CosineSimilarity(queryEmbedding, article.Embedding)
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Article.ArticleId < results[j].Article.ArticleId
+	})
+
+	return results, nil
+}
+
+// RetrieveArticles returns the limit best hybrid search matches among the articles published after since
+func RetrieveArticles(ctx context.Context, query string, since time.Time, limit int) ([]RetrievedArticle, error) {
+	results, err := HybridSearch(ctx, query, HybridSearchOptions{
+		Filter:     schemas.ArticleSearchFilter{From: &since},
+		Candidates: limit * 3,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// RetrievedPassage is an article passage found by passage search with the signals it was ranked on
+type RetrievedPassage struct {
+	Passage     schemas.PassageMatch `json:"passage"`
+	Score       float64              `json:"score"`       // -- reciprocal rank fusion of both rankings
+	TextRank    int                  `json:"textRank"`    // -- 1-based, 0 when full-text search didn't match
+	VectorRank  int                  `json:"vectorRank"`  // -- 1-based, 0 when not among the nearest embeddings
+	Similarity  float64              `json:"similarity"`  // -- cosine similarity to the query embedding
+	Highlights  []utils.TextSpan     `json:"highlights"`  // -- query term matches, as byte offsets into the article content
+	Highlighted string               `json:"highlighted"` // -- the passage as HTML with the matches in <mark>
+}
+
+type PassageSearchOptions struct {
+	Filter        schemas.ArticleSearchFilter
+	Candidates    int // -- passages taken from each ranking before fusion
+	MaxPerArticle int // -- 0 keeps every passage of an article
+}
+
+// PassageSearch finds the passages best matching the query the way HybridSearch finds articles, fusing
+// full-text and embedding rankings of the article chunks. Results are ordered best first and carry the
+// matches of the query terms, located in the article content through the passage offsets.
+func PassageSearch(ctx context.Context, query string, options PassageSearchOptions) ([]RetrievedPassage, error) {
+	pool := PostgresInstance.GetPostgresInstance()
+
+	var waitGroup sync.WaitGroup
+	var textMatches, vectorMatches []schemas.PassageMatch
+	var textErr, vectorErr error
+	var queryEmbedding []float32
+
+	waitGroup.Add(2)
+	go func() {
+		defer waitGroup.Done()
+		textMatches, textErr = schemas.SearchPassagesText(ctx, pool, query, options.Filter, options.Candidates)
+	}()
+	go func() {
+		defer waitGroup.Done()
+		embedding, err := utils.GenerateVectorEmebeddings(ctx, query)
+		if err != nil {
+			log.Println("Error embedding query, using full-text passage search only: ", err)
+			return
+		}
+		queryEmbedding = embedding
+		vectorMatches, vectorErr = schemas.GetNearestPassages(ctx, pool, embedding, options.Filter, options.Candidates)
+	}()
+	waitGroup.Wait()
+
+	if textErr != nil {
+		return nil, textErr
+	}
+	if vectorErr != nil {
+		return nil, vectorErr
+	}
+
+	passageKey := func(passage schemas.PassageMatch) string {
+		return fmt.Sprintf("%s:%d", passage.ArticleId, passage.ChunkIndex)
+	}
+
+	passages := map[string]schemas.PassageMatch{}
+	textRanking, vectorRanking := []string{}, []string{}
+	textRanks, vectorRanks := map[string]int{}, map[string]int{}
+	for rank, passage := range textMatches {
+		key := passageKey(passage)
+		passages[key] = passage
+		textRanking = append(textRanking, key)
+		textRanks[key] = rank + 1
+	}
+	for rank, passage := range vectorMatches {
+		key := passageKey(passage)
+		passages[key] = passage
+		vectorRanking = append(vectorRanking, key)
+		vectorRanks[key] = rank + 1
+	}
+
+	scores := utils.ReciprocalRankFusion(config.GetIntEnvironmentVariable("SEARCH_RRF_K", 60), textRanking, vectorRanking)
+
+	results := []RetrievedPassage{}
+	for key, score := range scores {
+		passage := passages[key]
+
+		spans := utils.HighlightSpans(passage.Content, query)
+		highlights := []utils.TextSpan{}
+		for _, span := range spans {
+			highlights = append(highlights, utils.TextSpan{Start: passage.StartOffset + span.Start, End: passage.StartOffset + span.End})
+		}
+
+		result := RetrievedPassage{
+			Passage:     passage,
+			Score:       score,
+			TextRank:    textRanks[key],
+			VectorRank:  vectorRanks[key],
+			Highlights:  highlights,
+			Highlighted: utils.HighlightHTML(passage.Content, spans),
+		}
+		if len(queryEmbedding) > 0 && len(passage.Embedding) > 0 {
+			result.Similarity = utils.CosineSimilarity(queryEmbedding, passage.Embedding)
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return passageKey(results[i].Passage) < passageKey(results[j].Passage)
+	})
+
+	if options.MaxPerArticle > 0 {
+		perArticle := map[string]int{}
+		kept := []RetrievedPassage{}
+		for _, result := range results {
+			if perArticle[result.Passage.ArticleId] < options.MaxPerArticle {
+				perArticle[result.Passage.ArticleId]++
+				kept = append(kept, result)
+			}
+		}
+		results = kept
+	}
+
+	return results, nil
+}
+
+// RetrieveArticlePassages returns up to perArticle best passages of each of the articles for the query,
+// by article id and best first. Articles without stored passages are left out.
+func RetrieveArticlePassages(ctx context.Context, query string, articleIds []string, perArticle int) (map[string][]RetrievedPassage, error) {
+	passagesByArticle := map[string][]RetrievedPassage{}
+	if len(articleIds) == 0 {
+		return passagesByArticle, nil
+	}
+
+	results, err := PassageSearch(ctx, query, PassageSearchOptions{
+		Filter:        schemas.ArticleSearchFilter{ArticleIds: articleIds},
+		Candidates:    len(articleIds) * perArticle * 4,
+		MaxPerArticle: perArticle,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		articleId := result.Passage.ArticleId
+		passagesByArticle[articleId] = append(passagesByArticle[articleId], result)
+	}
+	return passagesByArticle, nil
+}
@@ -0,0 +1,160 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"math"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"sort"
+	"time"
+)
+
+// TrendingWindows are the sliding windows trending is computed over
+var TrendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"24h": 24 * time.Hour,
+}
+
+// TrendingTermTypes are the kinds of terms trending is computed for
+var TrendingTermTypes = []string{"entity", "category", "tag"}
+
+// ComputeTrending measures, for every term of the given type, the mentions inside the current window
+// against the same-sized windows of the baseline period and scores bursts with a z-score.
+// Terms are returned by descending z-score.
+func ComputeTrending(ctx context.Context, windowName string, termType string, now time.Time) ([]schemas.TrendingSnapshotSchema, error) {
+	window := TrendingWindows[windowName]
+	baselineDays := config.GetIntEnvironmentVariable("TRENDING_BASELINE_DAYS", 7)
+	minMentions := config.GetIntEnvironmentVariable("TRENDING_MIN_MENTIONS", 3)
+	minZScore := float64(config.GetIntEnvironmentVariable("TRENDING_MIN_ZSCORE", 2))
+
+	baselinePeriod := time.Duration(baselineDays) * 24 * time.Hour
+	baselineBuckets := int(baselinePeriod / window)
+	if baselineBuckets < 1 {
+		baselineBuckets = 1
+	}
+
+	counts, err := schemas.GetTermBucketCounts(ctx, PostgresInstance.GetPostgresInstance(), termType, now, window,
+		now.Add(-window*time.Duration(baselineBuckets+1)))
+	if err != nil {
+		return nil, err
+	}
+
+	// bucket 0 is the current window, buckets 1..baselineBuckets the baseline
+	termBuckets := map[string][]int{}
+	for _, count := range counts {
+		if count.Bucket < 0 || count.Bucket > baselineBuckets {
+			continue
+		}
+		if termBuckets[count.Term] == nil {
+			termBuckets[count.Term] = make([]int, baselineBuckets+1)
+		}
+		termBuckets[count.Term][count.Bucket] += count.Count
+	}
+
+	snapshots := []schemas.TrendingSnapshotSchema{}
+	for term, buckets := range termBuckets {
+		mentions := buckets[0]
+		if mentions == 0 {
+			continue
+		}
+
+		mean, stdDev := meanAndStdDev(buckets[1:])
+
+		// sparse terms have a near zero deviation, the poisson deviation of the mean keeps them from exploding
+		deviation := math.Max(stdDev, math.Max(math.Sqrt(mean), 1))
+		zScore := (float64(mentions) - mean) / deviation
+
+		snapshots = append(snapshots, schemas.TrendingSnapshotSchema{
+			ComputedAt:     now,
+			Window:         windowName,
+			TermType:       termType,
+			Term:           term,
+			Mentions:       mentions,
+			BaselineMean:   mean,
+			BaselineStdDev: stdDev,
+			Velocity:       float64(mentions) / window.Hours(),
+			ZScore:         zScore,
+			IsBurst:        mentions >= minMentions && zScore >= minZScore,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].ZScore != snapshots[j].ZScore {
+			return snapshots[i].ZScore > snapshots[j].ZScore
+		}
+		return snapshots[i].Mentions > snapshots[j].Mentions
+	})
+
+	return snapshots, nil
+}
+
+// StartTrendingWorker periodically stores trending snapshots for every window and term type
+func StartTrendingWorker(ctx context.Context) {
+	interval := time.Duration(config.GetIntEnvironmentVariable("TRENDING_JOB_INTERVAL_MINUTES", 15)) * time.Minute
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			RunTrendingJob(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunTrendingJob computes and stores one snapshot of every trending list and drops expired snapshots
+func RunTrendingJob(ctx context.Context) {
+	pool := PostgresInstance.GetPostgresInstance()
+	snapshotSize := config.GetIntEnvironmentVariable("TRENDING_SNAPSHOT_SIZE", 50)
+	now := time.Now()
+
+	for windowName := range TrendingWindows {
+		for _, termType := range TrendingTermTypes {
+			snapshots, err := ComputeTrending(ctx, windowName, termType, now)
+			if err != nil {
+				log.Printf("Error computing %s trending %s: %v\n", windowName, termType, err)
+				continue
+			}
+
+			if len(snapshots) > snapshotSize {
+				snapshots = snapshots[:snapshotSize]
+			}
+			if err := schemas.InsertTrendingSnapshots(ctx, pool, snapshots); err != nil {
+				log.Printf("Error storing %s trending %s: %v\n", windowName, termType, err)
+			}
+		}
+	}
+
+	retentionDays := config.GetIntEnvironmentVariable("TRENDING_SNAPSHOT_RETENTION_DAYS", 90)
+	if err := schemas.DeleteTrendingSnapshotsBefore(ctx, pool, daysAgo(retentionDays)); err != nil {
+		log.Println("Error deleting old trending snapshots: ", err)
+	}
+}
+
+func meanAndStdDev(values []int) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	sum := 0.0
+	for _, value := range values {
+		sum += float64(value)
+	}
+	mean := sum / float64(len(values))
+
+	variance := 0.0
+	for _, value := range values {
+		variance += (float64(value) - mean) * (float64(value) - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
@@ -0,0 +1,292 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	PostgresInstance "service-news-app-backend/Postgres_Instance"
+	schemas "service-news-app-backend/Schemas"
+	"service-news-app-backend/config"
+	"service-news-app-backend/utils"
+	"strconv"
+	"time"
+)
+
+// WebhookSignatureHeader carries "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">"
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+// ArticleFilterDocument exposes the fields of an article that webhook filters can test
+func ArticleFilterDocument(article schemas.ArticleSchema) map[string]interface{} {
+	storyId := article.ArticleId
+	if article.StoryId != nil {
+		storyId = *article.StoryId
+	}
+
+	return map[string]interface{}{
+		"title":      article.Title,
+		"publisher":  article.Publisher,
+		"url":        article.Url,
+		"category":   article.Categories,
+		"tag":        article.Tags,
+		"entity":     article.EntityNames(),
+		"sentiment":  article.SentimentScore,
+		"importance": article.ImportanceScore,
+		"status":     article.Status,
+		"story":      storyId,
+	}
+}
+
+// SignWebhookPayload computes the signature partners verify with their webhook secret
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookArticlePayload is the article as sent to partners
+type webhookArticlePayload struct {
+	ArticleId       string    `json:"articleId"`
+	Title           string    `json:"title"`
+	Publisher       string    `json:"publisher"`
+	PublicationDate time.Time `json:"publicationDate"`
+	Url             string    `json:"url"`
+	Summary         string    `json:"summary"`
+	Tags            []string  `json:"tags"`
+	Categories      []string  `json:"categories"`
+	Entities        any       `json:"entities"`
+	SentimentScore  string    `json:"sentimentScore"`
+	ImportanceScore int       `json:"importanceScore"`
+	Language        string    `json:"language"`
+	StoryId         *string   `json:"storyId,omitempty"`
+	Status          string    `json:"status"`
+	Version         int       `json:"version"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func newWebhookArticlePayload(article schemas.ArticleSchema) webhookArticlePayload {
+	return webhookArticlePayload{
+		ArticleId:       article.ArticleId,
+		Title:           article.Title,
+		Publisher:       article.Publisher,
+		PublicationDate: article.PublicationDate,
+		Url:             article.Url,
+		Summary:         article.Summary,
+		Tags:            article.Tags,
+		Categories:      article.Categories,
+		Entities:        article.Entities,
+		SentimentScore:  article.SentimentScore,
+		ImportanceScore: article.ImportanceScore,
+		Language:        article.Language,
+		StoryId:         article.StoryId,
+		Status:          article.Status,
+		Version:         article.Version,
+		UpdatedAt:       article.UpdatedAt,
+	}
+}
+
+// EmitWebhookEvent queues a delivery for every enabled webhook subscribed to the event
+// whose filter matches the article. Deliveries are sent by the webhook worker.
+func EmitWebhookEvent(ctx context.Context, eventType string, article schemas.ArticleSchema) {
+	pool := PostgresInstance.GetPostgresInstance()
+
+	webhooks, err := schemas.GetEnabledWebhooksForEvent(ctx, pool, eventType)
+	if err != nil {
+		log.Printf("Error fetching webhooks for %s of article %s: %v\n", eventType, article.ArticleId, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	// partners get the public metadata, the full text and the editorial fields stay behind the API.
+	// Deletion notices only identify the article, they must not keep a copy of a taken down one.
+	var articlePayload interface{} = map[string]string{"articleId": article.ArticleId}
+	if eventType != "article.deleted" {
+		articlePayload = newWebhookArticlePayload(article)
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":      eventType,
+		"occurredAt": time.Now().UTC(),
+		"article":    articlePayload,
+	})
+	if err != nil {
+		log.Printf("Error encoding webhook payload of article %s: %v\n", article.ArticleId, err)
+		return
+	}
+
+	document := ArticleFilterDocument(article)
+	for _, webhook := range webhooks {
+		filter, err := utils.ParseFilterExpression(webhook.Filter)
+		if err != nil {
+			log.Printf("Skipping webhook %d with invalid filter: %v\n", webhook.WebhookId, err)
+			continue
+		}
+		if !filter.Evaluate(document) {
+			continue
+		}
+
+		_, err = schemas.InsertWebhookDelivery(ctx, pool, schemas.WebhookDeliverySchema{
+			WebhookId: webhook.WebhookId,
+			EventType: eventType,
+			ArticleId: article.ArticleId,
+			Payload:   payload,
+		})
+		if err != nil {
+			log.Printf("Error queueing webhook %d delivery: %v\n", webhook.WebhookId, err)
+		}
+	}
+}
+
+// StartWebhookWorker periodically sends the webhook deliveries that are due
+func StartWebhookWorker(ctx context.Context) {
+	interval := time.Duration(config.GetIntEnvironmentVariable("WEBHOOK_POLL_INTERVAL_SECONDS", 5)) * time.Second
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			RunWebhookDeliveries(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunWebhookDeliveries sends one batch of due webhook deliveries
+func RunWebhookDeliveries(ctx context.Context) {
+	pool := PostgresInstance.GetPostgresInstance()
+	batchSize := config.GetIntEnvironmentVariable("WEBHOOK_BATCH_SIZE", 50)
+	timeout := time.Duration(config.GetIntEnvironmentVariable("WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second
+
+	// the lease outlives the batch, so a crashed worker's deliveries are retried later
+	deliveries, err := schemas.ClaimDueWebhookDeliveries(ctx, pool, batchSize, time.Duration(batchSize+1)*timeout)
+	if err != nil {
+		log.Println("Error claiming webhook deliveries: ", err)
+		return
+	}
+
+	// partner urls are user supplied, private addresses and redirects are refused
+	client := utils.NewPublicHTTPClient(timeout)
+	webhooks := map[int64]*schemas.WebhookSchema{}
+
+	for _, delivery := range deliveries {
+		webhook, ok := webhooks[delivery.WebhookId]
+		if !ok {
+			webhook, err = schemas.GetWebhookByID(ctx, pool, delivery.WebhookId)
+			if err != nil {
+				log.Printf("Error fetching webhook %d: %v\n", delivery.WebhookId, err)
+				continue
+			}
+			webhooks[delivery.WebhookId] = webhook
+		}
+
+		if webhook == nil || !webhook.Enabled {
+			err = schemas.RecordWebhookDeliveryAttempt(ctx, pool, delivery.DeliveryId, "failed", 0, "webhook is disabled or deleted", nil)
+			if err != nil {
+				log.Println(err)
+			}
+			continue
+		}
+
+		if disabled := deliverWebhook(ctx, client, *webhook, delivery); disabled {
+			webhook.Enabled = false
+		}
+	}
+}
+
+// deliverWebhook makes one attempt at a delivery and schedules the next one on failure.
+// It returns true when the failure got the webhook disabled.
+func deliverWebhook(ctx context.Context, client *http.Client, webhook schemas.WebhookSchema, delivery schemas.WebhookDeliverySchema) bool {
+	pool := PostgresInstance.GetPostgresInstance()
+
+	statusCode, err := postWebhook(ctx, client, webhook, delivery)
+	if err == nil {
+		if err := schemas.RecordWebhookDeliveryAttempt(ctx, pool, delivery.DeliveryId, "succeeded", statusCode, "", nil); err != nil {
+			log.Println(err)
+		}
+		if err := schemas.RecordWebhookSuccess(ctx, pool, webhook.WebhookId); err != nil {
+			log.Printf("Error resetting failures of webhook %d: %v\n", webhook.WebhookId, err)
+		}
+		return false
+	}
+
+	status := "pending"
+	nextAttemptAt := time.Now().UTC().Add(webhookBackoff(delivery.Attempts + 1))
+	if delivery.Attempts+1 >= config.GetIntEnvironmentVariable("WEBHOOK_MAX_ATTEMPTS", 8) {
+		status = "failed"
+	}
+
+	if err := schemas.RecordWebhookDeliveryAttempt(ctx, pool, delivery.DeliveryId, status, statusCode, err.Error(), &nextAttemptAt); err != nil {
+		log.Println(err)
+	}
+
+	disabled, err := schemas.RecordWebhookFailure(ctx, pool, webhook.WebhookId, config.GetIntEnvironmentVariable("WEBHOOK_DISABLE_AFTER_FAILURES", 25))
+	if err != nil {
+		log.Println(err)
+	}
+	if disabled {
+		log.Printf("Webhook %d disabled after sustained delivery failures\n", webhook.WebhookId)
+	}
+
+	return disabled
+}
+
+// postWebhook sends the delivery and returns the status code, the response body is not kept
+func postWebhook(ctx context.Context, client *http.Client, webhook schemas.WebhookSchema, delivery schemas.WebhookDeliverySchema) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Webhook-Event", delivery.EventType)
+	request.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.DeliveryId, 10))
+	request.Header.Set(WebhookSignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp, SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload)))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1024))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("endpoint responded with status %d", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+// webhookBackoff doubles the wait after every failed attempt, up to a ceiling, with some jitter
+// so deliveries to a recovering endpoint don't all arrive at once
+func webhookBackoff(attempt int) time.Duration {
+	base := time.Duration(config.GetIntEnvironmentVariable("WEBHOOK_BACKOFF_BASE_SECONDS", 30)) * time.Second
+	ceiling := time.Duration(config.GetIntEnvironmentVariable("WEBHOOK_BACKOFF_MAX_MINUTES", 360)) * time.Minute
+
+	backoff := base
+	for i := 1; i < attempt && backoff < ceiling; i++ {
+		backoff *= 2
+	}
+	if backoff > ceiling {
+		backoff = ceiling
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(backoff)/5 + 1))
+	return backoff - backoff/10 + jitter
+}